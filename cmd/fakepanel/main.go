@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"x-revalidate-bot/pkg/xserver"
+	"x-revalidate-bot/pkg/xserver/xservertest"
+)
+
+type serverFlags []string
+
+func (s *serverFlags) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *serverFlags) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+func main() {
+	var servers serverFlags
+	addr := flag.String("addr", ":8081", "Address to listen on")
+	sessionID := flag.String("session", "fake-session", "Accepted X2SESSID cookie")
+	deviceKey := flag.String("device-key", "fake-devicekey", "Accepted XSERVER_DEVICEKEY cookie")
+	failure := flag.String("failure", "", "Failure mode: "+joinModes())
+	window := flag.Duration("window", xserver.DefaultRenewalWindow, "How long before expiry a server can be renewed")
+	extension := flag.Duration("extension", 48*time.Hour, "Time added to the expiry on renewal")
+	flag.Var(&servers, "vps", "Server as ID=EXPIRY, where EXPIRY is a duration from now or RFC3339 (repeatable)")
+	flag.Parse()
+
+	panel := xservertest.NewPanel(xservertest.PanelOptions{
+		SessionID:     *sessionID,
+		DeviceKey:     *deviceKey,
+		RenewalWindow: *window,
+		Extension:     *extension,
+	})
+	for _, value := range servers {
+		id, expiry, err := parseServer(value, time.Now())
+		if err != nil {
+			log.Fatal(err)
+		}
+		panel.AddServer(id, expiry)
+	}
+	mode, err := parseFailureMode(*failure)
+	if err != nil {
+		log.Fatal(err)
+	}
+	panel.SetFailure(mode)
+
+	mux := http.NewServeMux()
+	mux.Handle("/", panel)
+	mux.HandleFunc("/_fakepanel/servers", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(panel.Servers()); err != nil {
+			log.Println("Failed to encode servers:", err)
+		}
+	})
+	mux.HandleFunc("/_fakepanel/failure", func(w http.ResponseWriter, r *http.Request) {
+		mode, err := parseFailureMode(r.URL.Query().Get("mode"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		panel.SetFailure(mode)
+		fmt.Fprintf(w, "failure mode set to %q\n", mode)
+	})
+
+	fmt.Println("Fake XServer panel starting on", *addr)
+	fmt.Println("Point the updater at it with --base-url", baseURL(*addr))
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+// baseURL is the URL the updater reaches a panel listening on addr with.
+func baseURL(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "http://" + addr
+	}
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
+
+func parseServer(value string, now time.Time) (xserver.VPSID, time.Time, error) {
+	id, expiry, ok := strings.Cut(value, "=")
+	if !ok || id == "" {
+		return "", time.Time{}, fmt.Errorf("invalid -vps %q: want ID=EXPIRY", value)
+	}
+	if d, err := time.ParseDuration(expiry); err == nil {
+		return xserver.VPSID(id), now.Add(d), nil
+	}
+	t, err := time.Parse(time.RFC3339, expiry)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("invalid -vps %q: expiry must be a duration or RFC3339 time", value)
+	}
+	return xserver.VPSID(id), t, nil
+}
+
+func parseFailureMode(value string) (xservertest.FailureMode, error) {
+	mode := xservertest.FailureMode(value)
+	if mode == xservertest.FailureNone || slices.Contains(xservertest.FailureModes, mode) {
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown failure mode %q: want one of %s", value, joinModes())
+}
+
+func joinModes() string {
+	modes := make([]string, 0, len(xservertest.FailureModes))
+	for _, mode := range xservertest.FailureModes {
+		modes = append(modes, string(mode))
+	}
+	return strings.Join(modes, ", ")
+}
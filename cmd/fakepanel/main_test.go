@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+	"time"
+	"x-revalidate-bot/pkg/xserver"
+)
+
+func TestParseServer(t *testing.T) {
+	now := time.Date(2025, 7, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value   string
+		id      xserver.VPSID
+		expiry  time.Time
+		wantErr bool
+	}{
+		{value: "12345=6h", id: "12345", expiry: now.Add(6 * time.Hour)},
+		{value: "12345=2025-07-11T00:00:00Z", id: "12345", expiry: time.Date(2025, 7, 11, 0, 0, 0, 0, time.UTC)},
+		{value: "12345", wantErr: true},
+		{value: "=6h", wantErr: true},
+		{value: "12345=tomorrow", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			id, expiry, err := parseServer(tt.value, now)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if id != tt.id || !expiry.Equal(tt.expiry) {
+				t.Errorf("expected %s=%v, got %s=%v", tt.id, tt.expiry, id, expiry)
+			}
+		})
+	}
+}
+
+func TestParseFailureMode(t *testing.T) {
+	if _, err := parseFailureMode("maintenance"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := parseFailureMode(""); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := parseFailureMode("meteor"); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
+
+func TestBaseURL(t *testing.T) {
+	tests := []struct {
+		addr     string
+		expected string
+	}{
+		{addr: ":8081", expected: "http://localhost:8081"},
+		{addr: "0.0.0.0:8081", expected: "http://localhost:8081"},
+		{addr: "[::]:8081", expected: "http://localhost:8081"},
+		{addr: "127.0.0.1:8081", expected: "http://127.0.0.1:8081"},
+		{addr: "[::1]:8081", expected: "http://[::1]:8081"},
+		{addr: "panel.test:8081", expected: "http://panel.test:8081"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := baseURL(tt.addr); got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
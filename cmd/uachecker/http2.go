@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// HTTP2Record describes what a client sends on an HTTP/2 connection before
+// its first request.
+type HTTP2Record struct {
+	// Settings are the SETTINGS of the client as id:value, in order.
+	Settings []string `json:"settings"`
+	// WindowUpdate is the increment of the connection WINDOW_UPDATE, 0 if none.
+	WindowUpdate uint32 `json:"window_update"`
+	// Priorities are the PRIORITY frames as stream:exclusive:dependency:weight.
+	Priorities []string `json:"priorities,omitempty"`
+	// HeadersPriority is the priority of the first HEADERS frame as
+	// exclusive:dependency:weight, empty if it had none.
+	HeadersPriority   string   `json:"headers_priority,omitempty"`
+	PseudoHeaderOrder []string `json:"pseudo_header_order"`
+	// Fingerprint is the Akamai fingerprint:
+	// SETTINGS|WINDOW_UPDATE|PRIORITY|pseudo-header order.
+	Fingerprint string `json:"fingerprint"`
+}
+
+// maxPrefaceBytes bounds what is buffered looking for the first HEADERS frame.
+const maxPrefaceBytes = 64 << 10
+
+// serveHTTP2 is a TLSNextProto handler for h2 that records the HTTP/2
+// fingerprint of conn before handing it to the HTTP/2 server.
+func (h *helloRecorder) serveHTTP2(srv *http.Server, conn *tls.Conn, handler http.Handler) {
+	remoteAddr := conn.RemoteAddr().String()
+	recording := &recordingConn{Conn: conn, record: func(record *HTTP2Record) {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.frames[remoteAddr] = record
+	}}
+	(&http2.Server{}).ServeConn(recording, &http2.ServeConnOpts{BaseConfig: srv, Handler: handler})
+}
+
+// recordingConn keeps what the client sends until its HTTP/2 fingerprint is
+// known. It embeds the *tls.Conn so the server still sees the connection state.
+type recordingConn struct {
+	*tls.Conn
+	buf    []byte
+	done   bool
+	record func(*HTTP2Record)
+}
+
+// Read is only called by the connection's read loop, so it needs no lock.
+func (c *recordingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if c.done || n == 0 {
+		return n, err
+	}
+	c.buf = append(c.buf, p[:n]...)
+	record, perr := parseHTTP2Fingerprint(c.buf)
+	if record != nil {
+		c.record(record)
+	}
+	if record != nil || perr != nil || len(c.buf) > maxPrefaceBytes {
+		c.done, c.buf = true, nil
+	}
+	return n, err
+}
+
+// parseHTTP2Fingerprint reads the client preface and the frames up to the
+// first HEADERS frame from data. It returns nil without an error while data
+// is incomplete.
+func parseHTTP2Fingerprint(data []byte) (*HTTP2Record, error) {
+	preface := []byte(http2.ClientPreface)
+	if len(data) < len(preface) {
+		return nil, nil
+	}
+	if !bytes.HasPrefix(data, preface) {
+		return nil, errors.New("missing the HTTP/2 client preface")
+	}
+	framer := http2.NewFramer(io.Discard, bytes.NewReader(data[len(preface):]))
+	framer.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+
+	record := &HTTP2Record{}
+	for {
+		frame, err := framer.ReadFrame()
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch f := frame.(type) {
+		case *http2.SettingsFrame:
+			if f.IsAck() {
+				continue
+			}
+			f.ForeachSetting(func(s http2.Setting) error {
+				record.Settings = append(record.Settings, fmt.Sprintf("%d:%d", s.ID, s.Val))
+				return nil
+			})
+		case *http2.WindowUpdateFrame:
+			if f.StreamID == 0 {
+				record.WindowUpdate = f.Increment
+			}
+		case *http2.PriorityFrame:
+			record.Priorities = append(record.Priorities, fmt.Sprintf("%d:%s", f.StreamID, formatPriority(f.PriorityParam)))
+		case *http2.MetaHeadersFrame:
+			if f.HasPriority() {
+				record.HeadersPriority = formatPriority(f.Priority)
+			}
+			var order []string
+			for _, field := range f.PseudoFields() {
+				record.PseudoHeaderOrder = append(record.PseudoHeaderOrder, field.Name)
+				order = append(order, field.Name[1:2])
+			}
+			record.Fingerprint = strings.Join([]string{
+				strings.Join(record.Settings, ";"),
+				strconv.FormatUint(uint64(record.WindowUpdate), 10),
+				cmp.Or(strings.Join(record.Priorities, ","), "0"),
+				strings.Join(order, ","),
+			}, "|")
+			return record, nil
+		}
+	}
+}
+
+// formatPriority formats p as exclusive:dependency:weight, with the weight
+// from 1 to 256, one more than on the wire.
+func formatPriority(p http2.PriorityParam) string {
+	exclusive := 0
+	if p.Exclusive {
+		exclusive = 1
+	}
+	return fmt.Sprintf("%d:%d:%d", exclusive, p.StreamDep, int(p.Weight)+1)
+}
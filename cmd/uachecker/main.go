@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -14,9 +15,16 @@ type UserAgentRecord struct {
 	Timestamp  time.Time         `json:"timestamp"`
 	Method     string            `json:"method"`
 	RequestURI string            `json:"request_uri"`
+	Protocol   string            `json:"protocol"`
+	TLS        *TLSRecord        `json:"tls,omitempty"`
+	HTTP2      *HTTP2Record      `json:"http2,omitempty"`
 }
 
 func main() {
+	tlsAddr := flag.String("tls-addr", "", "Also serve HTTPS and HTTP/2 on this address, recording TLS and HTTP/2 fingerprints (e.g. :8443)")
+	certOut := flag.String("tls-cert-out", "uachecker.pem", "Where to write the self-signed certificate for clients to trust")
+	flag.Parse()
+
 	server := NewServer()
 
 	err := server.LoadTemplate("cmd/uachecker/templates/index.html")
@@ -28,6 +36,12 @@ func main() {
 	http.HandleFunc("/api/ua", server.HandleUA)
 	http.HandleFunc("/headers", server.HandleHeaders)
 
+	if *tlsAddr != "" {
+		go func() {
+			log.Fatal(server.ListenAndServeTLS(*tlsAddr, *certOut))
+		}()
+	}
+
 	fmt.Println("UA Checker Server starting on :8080")
 	fmt.Println("Visit http://localhost:8080 to check your User-Agent")
 	fmt.Println("API endpoint: http://localhost:8080/api/ua")
@@ -50,6 +64,7 @@ func createRecord(r *http.Request) UserAgentRecord {
 		Timestamp:  time.Now(),
 		Method:     r.Method,
 		RequestURI: r.RequestURI,
+		Protocol:   r.Proto,
 	}
 }
 
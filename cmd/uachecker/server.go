@@ -1,13 +1,18 @@
 package main
 
 import (
+	"crypto/tls"
 	"encoding/json"
+	"fmt"
+	"net"
 	"net/http"
+	"os"
 )
 
 type Server struct {
 	renderer *TemplateRenderer
 	logger   *Logger
+	hellos   *helloRecorder
 }
 
 type TemplateData struct {
@@ -31,7 +36,7 @@ func (s *Server) LoadTemplate(templatePath string) error {
 }
 
 func (s *Server) HandleRequest(w http.ResponseWriter, r *http.Request) {
-	record := createRecord(r)
+	record := s.newRecord(r)
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
@@ -55,7 +60,7 @@ func (s *Server) HandleRequest(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) HandleUA(w http.ResponseWriter, r *http.Request) {
-	record := createRecord(r)
+	record := s.newRecord(r)
 
 	w.Header().Set("Content-Type", "application/json")
 
@@ -73,7 +78,7 @@ func (s *Server) HandleUA(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) HandleHeaders(w http.ResponseWriter, r *http.Request) {
-	record := createRecord(r)
+	record := s.newRecord(r)
 
 	w.Header().Set("Content-Type", "application/json")
 	err := json.NewEncoder(w).Encode(record)
@@ -84,6 +89,53 @@ func (s *Server) HandleHeaders(w http.ResponseWriter, r *http.Request) {
 	s.logRequest(record)
 }
 
+// newRecord creates the record of a request, with its ClientHello when served
+// over TLS and its HTTP/2 fingerprint when served over HTTP/2.
+func (s *Server) newRecord(r *http.Request) UserAgentRecord {
+	record := createRecord(r)
+	if r.TLS != nil {
+		record.TLS = s.hellos.lookup(r.RemoteAddr)
+	}
+	if r.ProtoMajor == 2 {
+		record.HTTP2 = s.hellos.lookupHTTP2(r.RemoteAddr)
+	}
+	return record
+}
+
 func (s *Server) logRequest(record UserAgentRecord) {
 	s.logger.LogRequest(record)
 }
+
+// ListenAndServeTLS serves over HTTPS with a fresh self-signed certificate,
+// written to certOut, and records the ClientHello and HTTP/2 fingerprint of
+// every connection.
+func (s *Server) ListenAndServeTLS(addr, certOut string) error {
+	cert, certPEM, err := selfSignedCertificate()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(certOut, certPEM, 0o644); err != nil {
+		return fmt.Errorf("failed to write certificate: %w", err)
+	}
+
+	s.hellos = newHelloRecorder()
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: http.DefaultServeMux,
+		TLSConfig: &tls.Config{
+			Certificates:       []tls.Certificate{cert},
+			GetConfigForClient: s.hellos.getConfigForClient,
+			NextProtos:         []string{"h2", "http/1.1"},
+		},
+		TLSNextProto: map[string]func(*http.Server, *tls.Conn, http.Handler){
+			"h2": s.hellos.serveHTTP2,
+		},
+		ConnState: func(conn net.Conn, state http.ConnState) {
+			if state == http.StateClosed {
+				s.hellos.forget(conn.RemoteAddr().String())
+			}
+		},
+	}
+	s.logger.LogInfo(fmt.Sprintf("Serving HTTPS on %s, trust it with SSL_CERT_FILE=%s", addr, certOut))
+	return srv.ListenAndServeTLS("", "")
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TLSRecord describes the ClientHello of a connection.
+type TLSRecord struct {
+	CipherSuites    []uint16 `json:"cipher_suites"`
+	Extensions      []uint16 `json:"extensions"`
+	SupportedCurves []uint16 `json:"supported_curves"`
+	ALPN            []string `json:"alpn"`
+	JA3             string   `json:"ja3"`
+	JA3Hash         string   `json:"ja3_hash"`
+}
+
+// helloRecorder remembers the ClientHello and the HTTP/2 fingerprint of every
+// connection by remote address.
+type helloRecorder struct {
+	mu     sync.Mutex
+	hellos map[string]*TLSRecord
+	frames map[string]*HTTP2Record
+}
+
+func newHelloRecorder() *helloRecorder {
+	return &helloRecorder{hellos: map[string]*TLSRecord{}, frames: map[string]*HTTP2Record{}}
+}
+
+// getConfigForClient is a tls.Config.GetConfigForClient that only records.
+func (h *helloRecorder) getConfigForClient(info *tls.ClientHelloInfo) (*tls.Config, error) {
+	record := newTLSRecord(info)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.hellos[info.Conn.RemoteAddr().String()] = record
+	return nil, nil
+}
+
+func (h *helloRecorder) lookup(remoteAddr string) *TLSRecord {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.hellos[remoteAddr]
+}
+
+func (h *helloRecorder) lookupHTTP2(remoteAddr string) *HTTP2Record {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.frames[remoteAddr]
+}
+
+func (h *helloRecorder) forget(remoteAddr string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.hellos, remoteAddr)
+	delete(h.frames, remoteAddr)
+}
+
+func newTLSRecord(info *tls.ClientHelloInfo) *TLSRecord {
+	curves := make([]uint16, len(info.SupportedCurves))
+	for i, curve := range info.SupportedCurves {
+		curves[i] = uint16(curve)
+	}
+	ja3 := strings.Join([]string{
+		strconv.Itoa(int(helloVersion(info))),
+		joinValues(info.CipherSuites),
+		joinValues(info.Extensions),
+		joinValues(curves),
+		joinValues(info.SupportedPoints),
+	}, ",")
+	sum := md5.Sum([]byte(ja3))
+	return &TLSRecord{
+		CipherSuites:    info.CipherSuites,
+		Extensions:      info.Extensions,
+		SupportedCurves: curves,
+		ALPN:            info.SupportedProtos,
+		JA3:             ja3,
+		JA3Hash:         hex.EncodeToString(sum[:]),
+	}
+}
+
+// helloVersion returns the legacy ClientHello version JA3 starts with. The
+// TLS stack does not expose it, so it is derived from the supported versions:
+// clients offering TLS 1.2 or later send TLS 1.2 there, older ones their
+// highest version.
+func helloVersion(info *tls.ClientHelloInfo) uint16 {
+	var version uint16
+	for _, v := range info.SupportedVersions {
+		if !isGREASE(v) {
+			version = max(version, v)
+		}
+	}
+	return min(version, tls.VersionTLS12)
+}
+
+// joinValues joins values with dashes as JA3 does, leaving out GREASE values.
+func joinValues[T ~uint8 | ~uint16](values []T) string {
+	var parts []string
+	for _, v := range values {
+		if isGREASE(uint16(v)) {
+			continue
+		}
+		parts = append(parts, strconv.Itoa(int(v)))
+	}
+	return strings.Join(parts, "-")
+}
+
+func isGREASE(v uint16) bool {
+	return v&0x0f0f == 0x0a0a && v>>8 == v&0xff
+}
+
+// selfSignedCertificate creates a certificate for localhost and returns it
+// with its PEM encoding, so clients can trust it through SSL_CERT_FILE.
+func selfSignedCertificate() (tls.Certificate, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "uachecker"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(30 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	cert := tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	return cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
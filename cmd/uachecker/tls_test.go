@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"x-revalidate-bot/pkg/xserver"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// chromeHTTP2Fingerprint is the Akamai fingerprint of Chrome 120 and later.
+const chromeHTTP2Fingerprint = "1:65536;2:0;4:6291456;6:262144|15663105|0|m,a,s,p"
+
+func TestHandleHeadersTLS(t *testing.T) {
+	cert, certPEM, err := selfSignedCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(certPEM) {
+		t.Fatal("failed to parse the certificate PEM")
+	}
+
+	server := NewServer()
+	server.hellos = newHelloRecorder()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(server.HandleHeaders))
+	srv.TLS = &tls.Config{
+		Certificates:       []tls.Certificate{cert},
+		GetConfigForClient: server.hellos.getConfigForClient,
+		NextProtos:         []string{"h2", "http/1.1"},
+	}
+	srv.Config.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){
+		"h2": server.hellos.serveHTTP2,
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	tests := []struct {
+		name      string
+		transport http.RoundTripper
+		protocol  string
+		chrome    bool
+	}{
+		{name: "Go", transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}, ForceAttemptHTTP2: true}, protocol: "HTTP/2.0"},
+		{name: "Chrome", transport: xserver.NewChromeTransport(xserver.ChromeTransportOptions{RootCAs: roots}), protocol: "HTTP/2.0", chrome: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := (&http.Client{Transport: tt.transport}).Get(srv.URL + "/headers")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer resp.Body.Close()
+
+			var record UserAgentRecord
+			if err := json.NewDecoder(resp.Body).Decode(&record); err != nil {
+				t.Fatal(err)
+			}
+			if record.Protocol != tt.protocol {
+				t.Errorf("expected protocol %s, got %s", tt.protocol, record.Protocol)
+			}
+			if record.TLS == nil {
+				t.Fatal("expected a TLS record")
+			}
+			if len(record.TLS.JA3Hash) != 32 || !strings.HasPrefix(record.TLS.JA3, "771,") {
+				t.Errorf("unexpected JA3 %q (%s)", record.TLS.JA3, record.TLS.JA3Hash)
+			}
+			if grease := isGREASE(record.TLS.CipherSuites[0]); grease != tt.chrome {
+				t.Errorf("expected GREASE %v, got cipher suites %v", tt.chrome, record.TLS.CipherSuites)
+			}
+			if record.HTTP2 == nil {
+				t.Fatal("expected an HTTP/2 record")
+			}
+			if got := record.HTTP2.Fingerprint; (got == chromeHTTP2Fingerprint) != tt.chrome {
+				t.Errorf("expected Chrome's HTTP/2 fingerprint %v, got %q", tt.chrome, got)
+			}
+		})
+	}
+}
+
+func TestJoinValues(t *testing.T) {
+	if got := joinValues([]uint16{0x0a0a, 4865, 4866, 0xfafa, 49195}); got != "4865-4866-49195" {
+		t.Errorf("expected GREASE values to be left out, got %q", got)
+	}
+}
+
+func TestHelloVersion(t *testing.T) {
+	tests := []struct {
+		versions []uint16
+		want     uint16
+	}{
+		{versions: []uint16{0x1a1a, tls.VersionTLS13, tls.VersionTLS12}, want: tls.VersionTLS12},
+		{versions: []uint16{tls.VersionTLS12, tls.VersionTLS11}, want: tls.VersionTLS12},
+		{versions: []uint16{tls.VersionTLS11, tls.VersionTLS10}, want: tls.VersionTLS11},
+	}
+	for _, tt := range tests {
+		if got := helloVersion(&tls.ClientHelloInfo{SupportedVersions: tt.versions}); got != tt.want {
+			t.Errorf("helloVersion(%v) = %d, want %d", tt.versions, got, tt.want)
+		}
+	}
+}
+
+func TestParseHTTP2Fingerprint(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString(http2.ClientPreface)
+	framer := http2.NewFramer(&buf, nil)
+	framer.WriteSettings(http2.Setting{ID: http2.SettingEnablePush, Val: 0})
+	framer.WritePriority(3, http2.PriorityParam{StreamDep: 0, Weight: 200})
+	var block bytes.Buffer
+	encoder := hpack.NewEncoder(&block)
+	for _, name := range []string{":method", ":path", ":scheme", ":authority"} {
+		encoder.WriteField(hpack.HeaderField{Name: name, Value: "x"})
+	}
+	framer.WriteHeaders(http2.HeadersFrameParam{StreamID: 1, BlockFragment: block.Bytes(), EndHeaders: true, EndStream: true})
+	data := buf.Bytes()
+
+	record, err := parseHTTP2Fingerprint(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "2:0|0|3:0:0:201|m,p,s,a"; record == nil || record.Fingerprint != want {
+		t.Errorf("expected fingerprint %q, got %+v", want, record)
+	}
+	if record, err := parseHTTP2Fingerprint(data[:len(data)-1]); record != nil || err != nil {
+		t.Errorf("expected nothing from incomplete frames, got %+v, %v", record, err)
+	}
+	if _, err := parseHTTP2Fingerprint([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")); err == nil {
+		t.Error("expected an error without the client preface")
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"x-revalidate-bot/pkg/xserver"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// defaultAccount names the account built from VPS_ID, X2SESSID and XSERVER_DEVICEKEY.
+const defaultAccount = "default"
+
+var (
+	Concurrency   int
+	ReadNewExpiry bool
+)
+
+// account is a login with the servers to renew, from the environment or the
+// config file.
+type account struct {
+	Name string
+	credentials
+	HeaderProfile string
+	Proxy         string
+}
+
+// loadAccounts returns the account of --vps or VPS_ID, X2SESSID and XSERVER_DEVICEKEY
+// followed by the accounts of the config file. The former may be left out when
+// the config file has accounts. With discover the servers are left empty, as
+// they are read from the panel instead.
+func loadAccounts(discover bool) ([]account, error) {
+	hasDefault := rawVPSIDs() != ""
+	if discover {
+		hasDefault = cmp.Or(os.Getenv("X2SESSID"), fileConfig.SessionID) != ""
+	}
+
+	var accounts []account
+	if len(fileConfig.Accounts) == 0 || hasDefault {
+		load := loadCredentials
+		if discover {
+			load = loadSession
+		}
+		creds, err := load()
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account{Name: defaultAccount, credentials: creds})
+	}
+	for _, config := range fileConfig.Accounts {
+		var ids []xserver.VPSID
+		if !discover {
+			if len(config.VPSIDs) == 0 {
+				return nil, fmt.Errorf("account %q: vps_ids is empty, list the servers or use --all", config.Name)
+			}
+			var err error
+			if ids, err = parseVPSIDs(strings.Join(config.VPSIDs, ",")); err != nil {
+				return nil, fmt.Errorf("account %q: %w", config.Name, err)
+			}
+		}
+		accounts = append(accounts, account{
+			Name:          config.Name,
+			credentials:   credentials{VPSIDs: ids, SessionID: config.SessionID, DeviceKey: config.DeviceKey},
+			HeaderProfile: config.HeaderProfile,
+			Proxy:         config.Proxy,
+		})
+	}
+	return accounts, nil
+}
+
+// renewal is the outcome of one server in a run. The expiries are zero when
+// they could not be read.
+type renewal struct {
+	Item     xserver.BatchItem
+	Previous time.Time
+	New      time.Time
+	Err      error
+	// Skipped is why the server was left alone; it was not renewed nor failed.
+	Skipped string
+}
+
+// newPool creates the clients of accounts.
+func newPool(accounts []account) (*xserver.AccountPool, error) {
+	options, poolAccounts, err := poolConfig(accounts)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := xserver.NewAccountPool(poolAccounts, options)
+	if err != nil {
+		slog.Error("Error creating XServer clients", "error", err)
+		return nil, err
+	}
+	return pool, nil
+}
+
+// poolConfig returns the options shared by the clients of accounts and the
+// pool accounts with their own headers and proxies.
+func poolConfig(accounts []account) (xserver.ClientOptions, []xserver.Account, error) {
+	options, err := clientOptions(credentials{})
+	if err != nil {
+		return options, nil, err
+	}
+	poolAccounts := make([]xserver.Account, len(accounts))
+	for i, account := range accounts {
+		poolAccounts[i] = xserver.Account{
+			Name:      account.Name,
+			SessionID: account.SessionID,
+			DeviceKey: account.DeviceKey,
+		}
+		if account.HeaderProfile != "" {
+			if poolAccounts[i].Headers, err = loadHeaders(account.HeaderProfile); err != nil {
+				slog.Error("Error getting headers", "error", err, "account", account.Name)
+				return options, nil, err
+			}
+		}
+		if account.Proxy != "" {
+			if poolAccounts[i].Proxy, err = url.Parse(account.Proxy); err != nil {
+				return options, nil, fmt.Errorf("account %q: proxy: %w", account.Name, err)
+			}
+		}
+	}
+	return options, poolAccounts, nil
+}
+
+// batchItems lists the servers of every account.
+func batchItems(accounts []account) []xserver.BatchItem {
+	var items []xserver.BatchItem
+	for _, account := range accounts {
+		for _, vpsID := range account.VPSIDs {
+			items = append(items, xserver.BatchItem{Account: account.Name, VPSID: vpsID})
+		}
+	}
+	return items
+}
+
+// runBatch renews every item, continuing past failures. listed holds the
+// expiries already read from the server list, used when the extend page does
+// not show one. Pass the renewals to runError for the outcome of the run.
+func runBatch(ctx context.Context, pool *xserver.AccountPool, items []xserver.BatchItem, listed map[xserver.BatchItem]time.Time) []renewal {
+	vpsIDs := make([]xserver.VPSID, len(items))
+	for i, item := range items {
+		vpsIDs[i] = item.VPSID
+	}
+	slog.Info("Starting VPS renewal process", "vps_ids", vpsIDs, "concurrency", Concurrency)
+
+	var mu sync.Mutex
+	expiries := make(map[xserver.BatchItem][2]time.Time, len(items))
+	summary := pool.RenewBatch(ctx, items, xserver.BatchOptions{
+		Concurrency: Concurrency,
+		Renew: func(ctx context.Context, client xserver.Client, item xserver.BatchItem) (err error) {
+			vpsID := item.VPSID
+			// Every server gets its own span, so the retries of one do not
+			// overwrite those of another on the run span.
+			ctx, span := tracer().Start(ctx, "updater.renew", trace.WithAttributes(
+				xserver.AttributeAccount.String(item.Account),
+				xserver.AttributeVPSID.String(vpsID.String()),
+			))
+			defer func() { xserver.EndSpan(span, err) }()
+
+			// The expiries only feed the summary. The previous one comes from
+			// the page the unique ID is read from; the new one costs another
+			// page load, so it is only read with --read-new-expiry.
+			var before, after xserver.ServerStatus
+			err = retryOnInterstitial(ctx, func() error {
+				form, err := client.GetRenewalForm(ctx, vpsID)
+				if err != nil {
+					return err
+				}
+				before.Expiry = form.Expiry
+				return client.ExtendFreeVPSExpiration(ctx, vpsID, form.UniqueID)
+			})
+			if err == nil && ReadNewExpiry {
+				after, _ = client.GetServerStatus(ctx, vpsID)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			expiries[item] = [2]time.Time{before.Expiry, after.Expiry}
+			return err
+		},
+		OnResult: func(result xserver.BatchResult) {
+			if result.Err != nil {
+				slog.Error("Error renewing VPS", "error", result.Err, "error_code", xserver.ErrorCodeOf(result.Err), "account", result.Item.Account, "vps_id", result.Item.VPSID)
+				return
+			}
+			slog.Info("VPS renewed", "account", result.Item.Account, "vps_id", result.Item.VPSID, "duration", result.Duration)
+		},
+	})
+
+	slog.Info("VPS renewal finished", "total", len(summary.Results), "succeeded", summary.Succeeded, "failed", summary.Failed)
+	renewals := make([]renewal, len(summary.Results))
+	for i, result := range summary.Results {
+		renewals[i] = renewal{Item: result.Item, Err: result.Err}
+		renewals[i].Previous, renewals[i].New = cmp.Or(expiries[result.Item][0], listed[result.Item]), expiries[result.Item][1]
+	}
+	return renewals
+}
+
+// runError is nil when no server failed and a *partialError when some servers
+// were renewed and others failed. Skipped servers count as neither.
+func runError(renewals []renewal) error {
+	succeeded, failed := 0, 0
+	for _, r := range renewals {
+		switch {
+		case r.Err != nil:
+			failed++
+		case r.Skipped == "":
+			succeeded++
+		}
+	}
+	switch {
+	case failed == 0:
+		return nil
+	case succeeded == 0:
+		return batchError(renewals, failed)
+	default:
+		return &partialError{err: batchError(renewals, failed)}
+	}
+}
+
+// partialError is returned when some servers were renewed and others were not.
+type partialError struct {
+	err error
+}
+
+func (e *partialError) Error() string { return e.err.Error() }
+func (e *partialError) Unwrap() error { return e.err }
+
+// batchError reports a temporary failure only when every failure was temporary,
+// so a wrapper retrying on EX_TEMPFAIL does not mask a broken server.
+func batchError(renewals []renewal, failed int) error {
+	var errs []error
+	for _, r := range renewals {
+		if r.Err == nil {
+			continue
+		}
+		if !isTemporary(r.Err) {
+			return fmt.Errorf("%d of %d renewals failed: %w", failed, len(renewals), r.Err)
+		}
+		errs = append(errs, fmt.Errorf("%s/%s: %w", r.Item.Account, r.Item.VPSID, r.Err))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+	"x-revalidate-bot/pkg/xserver"
+	"x-revalidate-bot/pkg/xserver/xservertest"
+)
+
+func Test_runBatch_FakePanel(t *testing.T) {
+	withoutPacing(t)
+	panel := xservertest.NewPanel(xservertest.PanelOptions{
+		SessionID: "fake-session",
+		DeviceKey: "fake-devicekey",
+	})
+	panel.AddServer("111", time.Now().Add(6*time.Hour))
+	panel.AddServer("222", time.Now().Add(40*time.Hour))
+	panel.AddServer("333", time.Now().Add(6*time.Hour))
+	srv := httptest.NewServer(panel)
+	defer srv.Close()
+
+	defer func(concurrency int) { Concurrency = concurrency }(Concurrency)
+	Concurrency = 2
+
+	t.Setenv("VPS_ID", "111,222,333")
+	t.Setenv("X2SESSID", "fake-session")
+	t.Setenv("XSERVER_DEVICEKEY", "fake-devicekey")
+	t.Setenv("XSERVER_BASE_URL", srv.URL)
+
+	var out bytes.Buffer
+	err := runInternally(context.Background(), &out)
+	if xserver.ErrorCodeOf(err) != xserver.ErrorCodeNotYetRenewable {
+		t.Errorf("expected a not_yet_renewable error, got %v", err)
+	}
+	if exitCode(err) != exitPartial {
+		t.Errorf("expected exit code %d, got %d", exitPartial, exitCode(err))
+	}
+	if !regexp.MustCompile(`(?m)^222 +default +\S+ \S+ JST +- +failed +not_yet_renewable +-$`).MatchString(out.String()) ||
+		!strings.HasSuffix(out.String(), "2 renewed, 1 failed, 0 skipped\n") {
+		t.Errorf("unexpected summary\n%s", out.String())
+	}
+	for id, expected := range map[xserver.VPSID]int{"111": 1, "222": 0, "333": 1} {
+		if got := panel.Renewals(id); got != expected {
+			t.Errorf("expected %d renewals of %s, got %d", expected, id, got)
+		}
+	}
+	// One extend page and one renewal per server, the previous expiry read
+	// from the extend page.
+	if panel.Requests() != 6 {
+		t.Errorf("expected 6 requests, got %d", panel.Requests())
+	}
+	if !regexp.MustCompile(`(?m)^111 +default +\S+ \S+ JST +- +renewed `).MatchString(out.String()) {
+		t.Errorf("expected the previous expiry only\n%s", out.String())
+	}
+}
+
+func Test_runBatch_ReadNewExpiry(t *testing.T) {
+	withoutPacing(t)
+	panel := xservertest.NewPanel(xservertest.PanelOptions{
+		SessionID: "fake-session",
+		DeviceKey: "fake-devicekey",
+	})
+	panel.AddServer("111", time.Now().Add(6*time.Hour))
+	srv := httptest.NewServer(panel)
+	defer srv.Close()
+
+	defer func(read bool) { ReadNewExpiry = read }(ReadNewExpiry)
+	ReadNewExpiry = true
+
+	t.Setenv("VPS_ID", "111")
+	t.Setenv("X2SESSID", "fake-session")
+	t.Setenv("XSERVER_DEVICEKEY", "fake-devicekey")
+	t.Setenv("XSERVER_BASE_URL", srv.URL)
+
+	var out bytes.Buffer
+	if err := runInternally(context.Background(), &out); err != nil {
+		t.Fatalf("runInternally failed: %v", err)
+	}
+	if panel.Requests() != 3 {
+		t.Errorf("expected 3 requests, got %d", panel.Requests())
+	}
+	if !regexp.MustCompile(`(?m)^111 +default +\S+ \S+ JST +\S+ \S+ JST +renewed `).MatchString(out.String()) {
+		t.Errorf("expected both expiries\n%s", out.String())
+	}
+}
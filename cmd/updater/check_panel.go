@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"x-revalidate-bot/pkg/xserver"
+
+	"github.com/spf13/cobra"
+)
+
+// exitDrift is returned by check-panel when the page drifted from the baseline.
+const exitDrift = 2
+
+var UpdateBaseline bool
+
+func init() {
+	checkPanelCmd.Flags().BoolVar(&UpdateBaseline, "update-baseline", false, "Write the current page structure to --panel-baseline")
+	rootCmd.AddCommand(checkPanelCmd)
+}
+
+var checkPanelCmd = &cobra.Command{
+	Use:   "check-panel",
+	Short: "Fetch the extend page read-only and report structure drift",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if PanelBaseline == "" {
+			return fmt.Errorf("--panel-baseline is required")
+		}
+		creds, err := loadCredentials()
+		if err != nil {
+			return err
+		}
+		xs, err := newClient(creds)
+		if err != nil {
+			return err
+		}
+
+		report, err := xs.CheckPanel(context.Background(), creds.VPSIDs[0])
+		if err != nil {
+			slog.Error("Error checking panel", "error", err, "vps_id", creds.VPSIDs[0])
+			return err
+		}
+		printDriftReport(cmd.OutOrStdout(), report)
+
+		if UpdateBaseline {
+			if err := updateBaseline(PanelBaseline, report); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Baseline for %s written to %s\n", report.Page, PanelBaseline)
+			return nil
+		}
+		if report.Drifted() {
+			cmd.SilenceUsage = true
+			return &driftError{report: report}
+		}
+		return nil
+	},
+}
+
+// driftError reports a page that no longer matches its baseline.
+type driftError struct {
+	report xserver.DriftReport
+}
+
+func (e *driftError) Error() string {
+	return fmt.Sprintf("%s drifted from the baseline with %d change(s)", e.report.Page, len(e.report.Changes))
+}
+
+// updateBaseline records the current structure of report.Page in the
+// baseline at path, keeping the other pages. A missing file starts a new
+// baseline; one that cannot be read is left alone.
+func updateBaseline(path string, report xserver.DriftReport) error {
+	baseline, err := xserver.LoadPanelBaseline(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		baseline = xserver.PanelBaseline{}
+	} else if err != nil {
+		return err
+	}
+	baseline[report.Page] = report.Current
+	return baseline.Save(path)
+}
+
+func printDriftReport(w io.Writer, report xserver.DriftReport) {
+	switch {
+	case !report.HasBaseline:
+		fmt.Fprintf(w, "%s: no baseline recorded (skeleton %s)\n", report.Page, report.Current.Skeleton)
+	case !report.Drifted():
+		fmt.Fprintf(w, "%s: no drift (skeleton %s)\n", report.Page, report.Current.Skeleton)
+	default:
+		fmt.Fprintf(w, "%s: %d change(s) since baseline\n", report.Page, len(report.Changes))
+		for _, change := range report.Changes {
+			fmt.Fprintf(w, "  - %s\n", change)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"x-revalidate-bot/pkg/xserver"
+)
+
+func Test_printDriftReport(t *testing.T) {
+	tests := []struct {
+		name     string
+		report   xserver.DriftReport
+		expected string
+	}{
+		{
+			name: "No baseline",
+			report: xserver.DriftReport{
+				Page:    xserver.PageExtendIndex,
+				Current: xserver.PageFingerprint{Skeleton: "abcd"},
+			},
+			expected: "extend_index: no baseline recorded (skeleton abcd)\n",
+		},
+		{
+			name: "No drift",
+			report: xserver.DriftReport{
+				Page:        xserver.PageExtendIndex,
+				HasBaseline: true,
+				Current:     xserver.PageFingerprint{Skeleton: "abcd"},
+			},
+			expected: "extend_index: no drift (skeleton abcd)\n",
+		},
+		{
+			name: "Drift",
+			report: xserver.DriftReport{
+				Page:        xserver.PageExtendIndex,
+				HasBaseline: true,
+				Changes:     []string{"form field removed: uniqid"},
+			},
+			expected: "extend_index: 1 change(s) since baseline\n  - form field removed: uniqid\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			printDriftReport(&buf, tt.report)
+			if buf.String() != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, buf.String())
+			}
+		})
+	}
+}
+
+func Test_updateBaseline(t *testing.T) {
+	report := xserver.DriftReport{Page: xserver.PageExtendIndex, Current: xserver.PageFingerprint{Skeleton: "new"}}
+
+	t.Run("Missing file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "baseline.json")
+		if err := updateBaseline(path, report); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		baseline, err := xserver.LoadPanelBaseline(path)
+		if err != nil || baseline[xserver.PageExtendIndex].Skeleton != "new" {
+			t.Errorf("expected the new baseline, got %v, %v", baseline, err)
+		}
+	})
+
+	t.Run("Other pages are kept", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "baseline.json")
+		if err := (xserver.PanelBaseline{"top": {Skeleton: "top"}}).Save(path); err != nil {
+			t.Fatal(err)
+		}
+		if err := updateBaseline(path, report); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		baseline, err := xserver.LoadPanelBaseline(path)
+		if err != nil || baseline["top"].Skeleton != "top" || baseline[xserver.PageExtendIndex].Skeleton != "new" {
+			t.Errorf("expected both pages, got %v, %v", baseline, err)
+		}
+	})
+
+	t.Run("Corrupt file is left alone", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "baseline.json")
+		if err := os.WriteFile(path, []byte("{not json"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := updateBaseline(path, report); err == nil {
+			t.Error("expected an error for a corrupt baseline")
+		}
+		if data, _ := os.ReadFile(path); string(data) != "{not json" {
+			t.Errorf("expected the file to be left alone, got %q", data)
+		}
+	})
+}
+
+func Test_exitCode_Drift(t *testing.T) {
+	err := &driftError{report: xserver.DriftReport{Page: xserver.PageExtendIndex, Changes: []string{"form field removed: uniqid"}}}
+	if got := exitCode(err); got != exitDrift {
+		t.Errorf("expected exit code %d, got %d", exitDrift, got)
+	}
+}
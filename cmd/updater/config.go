@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// configDirName is the directory under the user config directory holding the
+// default config file.
+const configDirName = "x-revalidate-bot"
+
+var (
+	ConfigPath string
+	EnvFile    string
+)
+
+// fileConfig is the config file of the run, read from fileConfigPath. Its
+// zero value is an empty file.
+var (
+	fileConfig     Config
+	fileConfigPath string
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&ConfigPath, "config", "", "Path to a YAML or TOML config file (default $XDG_CONFIG_HOME/"+configDirName+"/config.{yaml,yml,toml} when present)")
+	rootCmd.PersistentFlags().StringVar(&EnvFile, "env-file", ".env", "Path to a .env file loaded into the environment when present")
+}
+
+// Config is the content of the config file. Every field is optional; flags
+// and environment variables take precedence over it. Relative paths in the
+// file are relative to the directory of the file.
+type Config struct {
+	// VPSIDs are the servers of the default account, like VPS_ID.
+	VPSIDs    []string `yaml:"vps_ids,omitempty" toml:"vps_ids,omitempty"`
+	SessionID string   `yaml:"session_id,omitempty" toml:"session_id,omitempty"`
+	DeviceKey string   `yaml:"device_key,omitempty" toml:"device_key,omitempty"`
+	// HeaderProfile is a JSON file of request headers replacing the bundled Chrome profile.
+	HeaderProfile string `yaml:"header_profile,omitempty" toml:"header_profile,omitempty"`
+	BaseURL       string `yaml:"base_url,omitempty" toml:"base_url,omitempty"`
+
+	PanelSchema    string `yaml:"panel_schema,omitempty" toml:"panel_schema,omitempty"`
+	PanelBaseline  string `yaml:"panel_baseline,omitempty" toml:"panel_baseline,omitempty"`
+	PageDumpDir    string `yaml:"page_dump_dir,omitempty" toml:"page_dump_dir,omitempty"`
+	Navigate       bool   `yaml:"navigate,omitempty" toml:"navigate,omitempty"`
+	TLSFingerprint string `yaml:"tls_fingerprint,omitempty" toml:"tls_fingerprint,omitempty"`
+	// Concurrency and Retries are nil when the file leaves them out, so 0
+	// can be set explicitly.
+	Concurrency *int `yaml:"concurrency,omitempty" toml:"concurrency,omitempty"`
+	Retries     *int `yaml:"retries,omitempty" toml:"retries,omitempty"`
+
+	// Accounts are renewed by extend in addition to the default account.
+	Accounts      []AccountConfig    `yaml:"accounts,omitempty" toml:"accounts,omitempty"`
+	Notifications NotificationConfig `yaml:"notifications,omitempty" toml:"notifications,omitempty"`
+	Schedule      ScheduleConfig     `yaml:"schedule,omitempty" toml:"schedule,omitempty"`
+}
+
+// AccountConfig is one more XServer login with its own servers.
+type AccountConfig struct {
+	Name      string `yaml:"name" toml:"name"`
+	SessionID string `yaml:"session_id" toml:"session_id"`
+	DeviceKey string `yaml:"device_key" toml:"device_key"`
+	// VPSIDs may be left out when the account is only renewed with --all.
+	VPSIDs        []string `yaml:"vps_ids,omitempty" toml:"vps_ids,omitempty"`
+	HeaderProfile string   `yaml:"header_profile,omitempty" toml:"header_profile,omitempty"`
+	// Proxy is an http, https or socks5 URL the account's requests go through.
+	Proxy string `yaml:"proxy,omitempty" toml:"proxy,omitempty"`
+}
+
+// NotificationConfig is where the outcome of extend is reported.
+type NotificationConfig struct {
+	// WebhookURL receives a JSON POST with a "text" field, as Slack and most
+	// chat webhooks accept.
+	WebhookURL string `yaml:"webhook_url,omitempty" toml:"webhook_url,omitempty"`
+	// OnSuccess also reports successful runs. Failures are always reported.
+	OnSuccess bool `yaml:"on_success,omitempty" toml:"on_success,omitempty"`
+}
+
+// ScheduleConfig is when the daemon runs its renewals, instead of when the
+// renewal window of each server opens.
+type ScheduleConfig struct {
+	// Cron is a five field cron expression or a descriptor such as @daily.
+	Cron string `yaml:"cron,omitempty" toml:"cron,omitempty"`
+	// Timezone is the IANA zone Cron is evaluated in. Defaults to Asia/Tokyo.
+	Timezone string `yaml:"timezone,omitempty" toml:"timezone,omitempty"`
+}
+
+// UnmarshalYAML accepts the cron expression alone, as in
+// schedule: "0 9 * * *", besides the cron and timezone keys.
+func (s *ScheduleConfig) UnmarshalYAML(value *yaml.Node) error {
+	switch value.Kind {
+	case yaml.ScalarNode:
+		return value.Decode(&s.Cron)
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(value.Content); i += 2 {
+			var v string
+			if err := value.Content[i+1].Decode(&v); err != nil {
+				return err
+			}
+			if err := s.set(value.Content[i].Value, v); err != nil {
+				return fmt.Errorf("line %d: %w", value.Content[i].Line, err)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("line %d: schedule must be a cron expression or have cron and timezone keys", value.Line)
+}
+
+// UnmarshalTOML is UnmarshalYAML for TOML.
+func (s *ScheduleConfig) UnmarshalTOML(data any) error {
+	switch data := data.(type) {
+	case string:
+		s.Cron = data
+		return nil
+	case map[string]any:
+		for key, value := range data {
+			v, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("schedule.%s must be a string", key)
+			}
+			if err := s.set(key, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return errors.New("schedule must be a cron expression or have cron and timezone keys")
+}
+
+func (s *ScheduleConfig) set(key, value string) error {
+	switch key {
+	case "cron":
+		s.Cron = value
+	case "timezone":
+		s.Timezone = value
+	default:
+		return fmt.Errorf("unknown key schedule.%s", key)
+	}
+	return nil
+}
+
+// defaultConfigPath returns the first config file present in the user config
+// directory, or "" when there is none.
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	for _, name := range []string{"config.yaml", "config.yml", "config.toml"} {
+		path := filepath.Join(dir, configDirName, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// loadConfigFile reads a YAML or TOML config file, picked by its extension.
+// Unknown keys are rejected so typos do not go unnoticed.
+func loadConfigFile(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	var cfg Config
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+			return Config{}, fmt.Errorf("%s: %w", path, err)
+		}
+	case ".toml":
+		meta, err := toml.Decode(string(data), &cfg)
+		if err != nil {
+			return Config{}, fmt.Errorf("%s: %w", path, err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return Config{}, fmt.Errorf("%s: unknown keys %v", path, undecoded)
+		}
+	default:
+		return Config{}, fmt.Errorf("%s: config file must end in .yaml, .yml or .toml", path)
+	}
+	if err := cfg.validate(); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	cfg.resolvePaths(filepath.Dir(path))
+	return cfg, nil
+}
+
+// resolvePaths makes the relative file paths of c relative to dir instead of
+// the working directory.
+func (c *Config) resolvePaths(dir string) {
+	resolve := func(path *string) {
+		if *path != "" && !filepath.IsAbs(*path) {
+			*path = filepath.Join(dir, *path)
+		}
+	}
+	resolve(&c.HeaderProfile)
+	resolve(&c.PanelSchema)
+	resolve(&c.PanelBaseline)
+	resolve(&c.PageDumpDir)
+	for i := range c.Accounts {
+		resolve(&c.Accounts[i].HeaderProfile)
+	}
+}
+
+func (c Config) validate() error {
+	names := map[string]bool{defaultAccount: true}
+	for i, account := range c.Accounts {
+		if account.Name == "" {
+			return fmt.Errorf("accounts[%d]: name is required", i)
+		}
+		if names[account.Name] {
+			return fmt.Errorf("accounts[%d]: duplicate name %q", i, account.Name)
+		}
+		names[account.Name] = true
+		if account.SessionID == "" || account.DeviceKey == "" {
+			return fmt.Errorf("account %q: session_id and device_key are required", account.Name)
+		}
+		if len(account.VPSIDs) > 0 {
+			if _, err := parseVPSIDs(strings.Join(account.VPSIDs, ",")); err != nil {
+				return fmt.Errorf("account %q: %w", account.Name, err)
+			}
+		}
+		if account.Proxy != "" {
+			if _, err := url.Parse(account.Proxy); err != nil {
+				return fmt.Errorf("account %q: proxy: %w", account.Name, err)
+			}
+		}
+	}
+	if c.Schedule.Cron != "" {
+		if _, err := parseSchedule(c.Schedule); err != nil {
+			return err
+		}
+	} else if c.Schedule.Timezone != "" {
+		if _, err := time.LoadLocation(c.Schedule.Timezone); err != nil {
+			return fmt.Errorf("schedule.timezone: %w", err)
+		}
+	}
+	return nil
+}
+
+// flagValues maps the flags the config file can set to their values in it.
+func (c Config) flagValues() map[string]string {
+	values := map[string]string{
+		"panel-schema":    c.PanelSchema,
+		"panel-baseline":  c.PanelBaseline,
+		"page-dump-dir":   c.PageDumpDir,
+		"tls-fingerprint": c.TLSFingerprint,
+	}
+	if c.Navigate {
+		values["navigate"] = "true"
+	}
+	if c.Concurrency != nil {
+		values["concurrency"] = strconv.Itoa(*c.Concurrency)
+	}
+	if c.Retries != nil {
+		values["retries"] = strconv.Itoa(*c.Retries)
+	}
+	return values
+}
+
+// configFlags are the flags the config file can set.
+var configFlags = []string{"panel-schema", "panel-baseline", "page-dump-dir", "tls-fingerprint", "navigate", "concurrency", "retries"}
+
+// applyConfig sets every flag that was not given on the command line to its
+// value in cfg, so flags keep precedence over the file. Flags cfg leaves out
+// go back to their defaults, which matters when the daemon reloads.
+func applyConfig(flags *pflag.FlagSet, cfg Config) error {
+	values := cfg.flagValues()
+	for _, name := range configFlags {
+		flag := flags.Lookup(name)
+		if flag == nil || flag.Changed {
+			continue
+		}
+		value := cmp.Or(values[name], flag.DefValue)
+		if err := flag.Value.Set(value); err != nil {
+			return fmt.Errorf("config %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// loadConfig loads the .env file and the config file of cmd.
+func loadConfig(cmd *cobra.Command) error {
+	// The environment alone is enough, for example in containers or CI.
+	if err := godotenv.Load(EnvFile); err != nil {
+		if !errors.Is(err, fs.ErrNotExist) || cmd.Flags().Changed("env-file") {
+			return fmt.Errorf("error loading %s: %w", EnvFile, err)
+		}
+	}
+
+	path := ConfigPath
+	if path == "" {
+		path = defaultConfigPath()
+	}
+	if path == "" {
+		fileConfig, fileConfigPath = Config{}, ""
+		return nil
+	}
+	cfg, err := loadConfigFile(path)
+	if err != nil {
+		return err
+	}
+	fileConfig, fileConfigPath = cfg, path
+	return applyConfig(cmd.Flags(), cfg)
+}
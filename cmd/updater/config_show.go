@@ -0,0 +1,99 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	configCmd.AddCommand(configShowCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the configuration",
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective configuration with secrets masked",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return printConfig(cmd.OutOrStdout(), fileConfigPath, effectiveConfig())
+	},
+}
+
+// effectiveConfig merges flags, the environment and the config file the same
+// way the other commands do.
+func effectiveConfig() Config {
+	cfg := fileConfig
+	if raw := cmp.Or(strings.Join(VPSFlags, ","), os.Getenv("VPS_ID")); raw != "" {
+		cfg.VPSIDs = strings.Split(raw, ",")
+		for i := range cfg.VPSIDs {
+			cfg.VPSIDs[i] = strings.TrimSpace(cfg.VPSIDs[i])
+		}
+	}
+	cfg.SessionID = cmp.Or(os.Getenv("X2SESSID"), cfg.SessionID)
+	cfg.DeviceKey = cmp.Or(os.Getenv("XSERVER_DEVICEKEY"), cfg.DeviceKey)
+	cfg.BaseURL = cmp.Or(BaseURL, os.Getenv("XSERVER_BASE_URL"), cfg.BaseURL)
+	cfg.PanelSchema = PanelSchema
+	cfg.PanelBaseline = PanelBaseline
+	cfg.PageDumpDir = PageDumpDir
+	cfg.Navigate = Navigate
+	cfg.TLSFingerprint = TLSProfile
+	concurrency, retries := Concurrency, Retries
+	cfg.Concurrency, cfg.Retries = &concurrency, &retries
+	return cfg
+}
+
+// masked returns a copy of c safe to print.
+func (c Config) masked() Config {
+	c.SessionID = maskSecret(c.SessionID)
+	c.DeviceKey = maskSecret(c.DeviceKey)
+	accounts := make([]AccountConfig, len(c.Accounts))
+	for i, account := range c.Accounts {
+		account.SessionID = maskSecret(account.SessionID)
+		account.DeviceKey = maskSecret(account.DeviceKey)
+		if u, err := url.Parse(account.Proxy); err == nil && account.Proxy != "" {
+			account.Proxy = u.Redacted()
+		}
+		accounts[i] = account
+	}
+	c.Accounts = accounts
+	// Webhook URLs carry their token in the path.
+	if u, err := url.Parse(c.Notifications.WebhookURL); err == nil && u.Host != "" {
+		c.Notifications.WebhookURL = u.Scheme + "://" + u.Host + "/****"
+	} else if c.Notifications.WebhookURL != "" {
+		c.Notifications.WebhookURL = "****"
+	}
+	return c
+}
+
+func maskSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return maskCredential(secret)
+}
+
+func printConfig(w io.Writer, path string, cfg Config) error {
+	if path == "" {
+		fmt.Fprintln(w, "# No config file, showing flags, environment and defaults")
+	} else {
+		fmt.Fprintf(w, "# Config file: %s\n", path)
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(cfg.masked()); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+	"x-revalidate-bot/pkg/xserver"
+)
+
+// controlService is the name the control API is registered under, so its
+// methods are called as "Daemon.Status" and so on.
+const controlService = "Daemon"
+
+// defaultSocketPath is $XDG_RUNTIME_DIR/x-revalidate-bot/daemon.sock, or next
+// to the state file when there is no runtime directory.
+func defaultSocketPath() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, configDirName, "daemon.sock")
+	}
+	return filepath.Join(filepath.Dir(defaultStatePath()), "daemon.sock")
+}
+
+// ControlArgs selects what a control call works on.
+type ControlArgs struct {
+	Account string        `json:"account,omitempty"`
+	VPSID   xserver.VPSID `json:"vps_id,omitempty"`
+}
+
+// DaemonStatus is the reply of Daemon.Status.
+type DaemonStatus struct {
+	// Schedule is the cron expression, or "renewal window" without one.
+	Schedule string          `json:"schedule"`
+	Timezone string          `json:"timezone,omitempty"`
+	NextRun  time.Time       `json:"next_run,omitzero"`
+	LastRun  time.Time       `json:"last_run,omitzero"`
+	Accounts []AccountStatus `json:"accounts"`
+	Servers  []DaemonServer  `json:"servers"`
+}
+
+type AccountStatus struct {
+	Name string `json:"name"`
+	// Session is "ok", "login required" or "unknown".
+	Session   string    `json:"session"`
+	CheckedAt time.Time `json:"checked_at,omitzero"`
+	Paused    bool      `json:"paused"`
+}
+
+// DaemonServer is the state of one server of the daemon.
+type DaemonServer struct {
+	Account string        `json:"account"`
+	VPSID   xserver.VPSID `json:"vps_id"`
+	Paused  bool          `json:"paused"`
+	serverState
+}
+
+// DaemonSchedules is the reply of Daemon.Schedules.
+type DaemonSchedules struct {
+	Schedule string `json:"schedule"`
+	Timezone string `json:"timezone,omitempty"`
+	// NextRuns are the upcoming fire times of Schedule.
+	NextRuns []time.Time    `json:"next_runs,omitempty"`
+	Servers  []DaemonServer `json:"servers"`
+}
+
+// Control is the API served on the control socket. Every exported method is
+// an RPC method.
+type Control struct {
+	ctx    context.Context
+	daemon *daemon
+	reload func() error
+}
+
+func (c *Control) Status(args ControlArgs, reply *DaemonStatus) error {
+	*reply = c.daemon.status()
+	return nil
+}
+
+func (c *Control) Schedules(args ControlArgs, reply *DaemonSchedules) error {
+	status := c.daemon.status()
+	*reply = DaemonSchedules{Schedule: status.Schedule, Timezone: status.Timezone, Servers: status.Servers}
+	if schedule := c.daemon.schedule(); schedule != nil {
+		next := c.daemon.now()
+		for range 5 {
+			next = schedule.Next(next)
+			reply.NextRuns = append(reply.NextRuns, next)
+		}
+	}
+	return nil
+}
+
+// Renew checks the server and renews it when its window is open.
+func (c *Control) Renew(args ControlArgs, reply *DaemonServer) error {
+	if args.VPSID == "" {
+		return errors.New("vps_id is required")
+	}
+	item, state, err := c.daemon.renewNow(c.ctx, args.Account, args.VPSID)
+	if err != nil {
+		return err
+	}
+	*reply = DaemonServer{Account: item.Account, VPSID: item.VPSID, Paused: c.daemon.paused(item.Account), serverState: state}
+	return nil
+}
+
+func (c *Control) Pause(args ControlArgs, reply *AccountStatus) error {
+	if err := c.daemon.pause(args.Account); err != nil {
+		return err
+	}
+	c.daemon.save()
+	*reply = AccountStatus{Name: args.Account, Paused: true}
+	return nil
+}
+
+func (c *Control) Resume(args ControlArgs, reply *AccountStatus) error {
+	if err := c.daemon.resume(args.Account); err != nil {
+		return err
+	}
+	c.daemon.save()
+	*reply = AccountStatus{Name: args.Account}
+	return nil
+}
+
+// Reload reads the config file and header profiles again, as SIGHUP does.
+func (c *Control) Reload(args ControlArgs, reply *DaemonStatus) error {
+	if err := c.reload(); err != nil {
+		return err
+	}
+	*reply = c.daemon.status()
+	return nil
+}
+
+func (d *daemon) status() DaemonStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	status := DaemonStatus{Schedule: "renewal window", LastRun: d.state.LastRun}
+	if schedule := d.options.Schedule; schedule != nil {
+		status.Schedule, status.Timezone = schedule.Spec, schedule.Location.String()
+		status.NextRun = schedule.Next(d.now())
+	}
+	for _, account := range d.accounts() {
+		status.Accounts = append(status.Accounts, AccountStatus{
+			Name:      account,
+			Session:   d.session(account),
+			CheckedAt: d.sessions[account].CheckedAt,
+			Paused:    slices.Contains(d.state.Paused, account),
+		})
+	}
+	for _, item := range d.items {
+		status.Servers = append(status.Servers, DaemonServer{
+			Account:     item.Account,
+			VPSID:       item.VPSID,
+			Paused:      slices.Contains(d.state.Paused, item.Account),
+			serverState: *d.state.Servers[stateKey(item)],
+		})
+	}
+	return status
+}
+
+// listenControl listens on the Unix socket at path, readable and writable by
+// the current user only. A socket left behind by a daemon that is gone is
+// replaced, one still answered by a running daemon is not.
+func listenControl(path string) (net.Listener, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode().Perm()&0o022 != 0 {
+		return nil, fmt.Errorf("control socket directory %s is writable by other users", dir)
+	}
+	if err := checkOwner(dir, info); err != nil {
+		return nil, err
+	}
+
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode().Type() != fs.ModeSocket {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("another daemon is listening on %s", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// serveControl serves the control API of d on listener until ctx is done.
+// Calls that work on the panel are cancelled with ctx.
+func serveControl(ctx context.Context, listener net.Listener, d *daemon, reload func() error) error {
+	server := rpc.NewServer()
+	if err := server.RegisterName(controlService, &Control{ctx: ctx, daemon: d, reload: reload}); err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	slog.Info("Control socket listening", "path", listener.Addr().String())
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if err := checkPeer(conn); err != nil {
+			slog.Warn("Rejected control connection", "error", err)
+			conn.Close()
+			continue
+		}
+		go server.ServeCodec(jsonrpc.NewServerCodec(conn))
+	}
+}
+
+// dialControl connects to the control socket at path after checking it
+// belongs to the current user, so nothing is sent to a socket planted by
+// someone else.
+func dialControl(path string) (*rpc.Client, error) {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("no daemon is listening on %s", path)
+	} else if err != nil {
+		return nil, err
+	}
+	if info.Mode().Type() != fs.ModeSocket {
+		return nil, fmt.Errorf("%s is not a socket", path)
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		return nil, fmt.Errorf("control socket %s is accessible by other users (%v)", path, info.Mode().Perm())
+	}
+	if err := checkOwner(path, info); err != nil {
+		return nil, err
+	}
+	return jsonrpc.Dial("unix", path)
+}
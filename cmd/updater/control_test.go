@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"x-revalidate-bot/pkg/xserver"
+	"x-revalidate-bot/pkg/xserver/xservertest"
+)
+
+// socketPath returns a socket path short enough for sun_path.
+func socketPath(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "ctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, "run", "daemon.sock")
+}
+
+func Test_Control(t *testing.T) {
+	saved := CtlSocket
+	defer func() { CtlSocket = saved }()
+	CtlSocket = socketPath(t)
+
+	now := time.Now().Truncate(time.Second)
+	fake := xservertest.NewFakeClient().
+		WithServer("111", now.Add(6*time.Hour)).
+		WithServer("222", now.Add(30*time.Hour))
+	d := testDaemon(fake, &now, "111", "222")
+	reloads := 0
+	listener, err := listenControl(CtlSocket)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- serveControl(ctx, listener, d, func() error { reloads++; return nil }) }()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}()
+
+	var server DaemonServer
+	if err := callControl("Renew", ControlArgs{VPSID: "111"}, &server); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if server.LastResult != "renewed" || fake.Renewals("111") != 1 {
+		t.Errorf("expected 111 to be renewed, got %+v", server)
+	}
+	if err := callControl("Renew", ControlArgs{VPSID: "222"}, &server); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fake.Renewals("222") != 0 || !server.NextAttempt.Equal(now.Add(6*time.Hour)) {
+		t.Errorf("expected 222 to be scheduled, got %+v", server)
+	}
+	if err := callControl("Renew", ControlArgs{VPSID: "333"}, &server); err == nil || !strings.Contains(err.Error(), "not configured") {
+		t.Errorf("expected an error for an unknown server, got %v", err)
+	}
+
+	var account AccountStatus
+	if err := callControl("Pause", ControlArgs{Account: defaultAccount}, &account); err != nil || !account.Paused {
+		t.Errorf("unexpected pause reply %+v, %v", account, err)
+	}
+	if err := callControl("Pause", ControlArgs{Account: "nobody"}, &account); err == nil {
+		t.Error("expected an error for an unknown account")
+	}
+	var status DaemonStatus
+	if err := callControl("Status", ControlArgs{}, &status); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(status.Accounts) != 1 || !status.Accounts[0].Paused || status.Accounts[0].Session != "ok" ||
+		len(status.Servers) != 2 || status.Servers[0].LastResult != "renewed" {
+		t.Errorf("unexpected status %+v", status)
+	}
+	if err := callControl("Resume", ControlArgs{Account: defaultAccount}, &account); err != nil || account.Paused {
+		t.Errorf("unexpected resume reply %+v, %v", account, err)
+	}
+
+	if err := callControl("Reload", ControlArgs{}, &status); err != nil || reloads != 1 {
+		t.Errorf("expected a reload, got %d, %v", reloads, err)
+	}
+	var schedules DaemonSchedules
+	if err := callControl("Schedules", ControlArgs{}, &schedules); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if schedules.Schedule != "renewal window" || len(schedules.NextRuns) != 0 || len(schedules.Servers) != 2 {
+		t.Errorf("unexpected schedules %+v", schedules)
+	}
+}
+
+func Test_listenControl(t *testing.T) {
+	t.Run("Refuses while a daemon listens", func(t *testing.T) {
+		path := socketPath(t)
+		listener, err := listenControl(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer listener.Close()
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+			t.Errorf("expected a 0600 socket, got %v, %v", info.Mode(), err)
+		}
+		if _, err := listenControl(path); err == nil || !strings.Contains(err.Error(), "another daemon") {
+			t.Errorf("expected an error, got %v", err)
+		}
+	})
+
+	t.Run("Replaces a stale socket", func(t *testing.T) {
+		path := socketPath(t)
+		stale, err := listenControl(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Closing without unlinking leaves the file behind, as a crash does.
+		stale.(*net.UnixListener).SetUnlinkOnClose(false)
+		stale.Close()
+		listener, err := listenControl(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		listener.Close()
+	})
+
+	t.Run("Refuses a directory writable by others", func(t *testing.T) {
+		path := socketPath(t)
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(filepath.Dir(path), 0o777); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := listenControl(path); err == nil || !strings.Contains(err.Error(), "writable by other users") {
+			t.Errorf("expected an error, got %v", err)
+		}
+	})
+
+	t.Run("Refuses to dial an open socket", func(t *testing.T) {
+		path := socketPath(t)
+		listener, err := listenControl(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer listener.Close()
+		if err := os.Chmod(path, 0o666); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := dialControl(path); err == nil || !strings.Contains(err.Error(), "accessible by other users") {
+			t.Errorf("expected an error, got %v", err)
+		}
+		if _, err := dialControl(path + ".missing"); err == nil || !strings.Contains(err.Error(), "no daemon") {
+			t.Errorf("expected an error, got %v", err)
+		}
+	})
+}
+
+func Test_printRenewal(t *testing.T) {
+	requested := time.Date(2025, 7, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		server serverState
+		want   string
+	}{
+		{name: "Renewed", server: serverState{LastAttempt: requested, LastResult: "renewed"}, want: "default/111: renewed\n"},
+		{
+			name:   "Failed",
+			server: serverState{LastAttempt: requested, LastResult: "failed", LastError: "boom", NextAttempt: requested.Add(5 * time.Minute)},
+			want:   "default/111: failed: boom, retrying at 2025-07-10 12:05 UTC\n",
+		},
+		{
+			name:   "Not renewable yet",
+			server: serverState{NextAttempt: requested.Add(6 * time.Hour)},
+			want:   "default/111: not renewable yet, renewal scheduled for 2025-07-10 18:00 UTC\n",
+		},
+		{
+			name:   "Status error",
+			server: serverState{LastError: "connection reset"},
+			want:   "default/111: not renewed, the expiry could not be read: connection reset\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			printRenewal(&out, DaemonServer{Account: defaultAccount, VPSID: "111", serverState: tt.server}, requested)
+			if out.String() != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, out.String())
+			}
+		})
+	}
+}
+
+func Test_daemon_pause(t *testing.T) {
+	now := time.Date(2025, 7, 10, 12, 0, 0, 0, time.UTC)
+	fake := xservertest.NewFakeClient().WithServer("111", now.Add(6*time.Hour))
+	d := testDaemon(fake, &now, "111")
+	if err := d.pause(defaultAccount); err != nil {
+		t.Fatal(err)
+	}
+	d.cycle(context.Background())
+	if calls := fake.Calls(); len(calls) != 0 {
+		t.Errorf("expected a paused account to be left alone, got %v", calls)
+	}
+	if err := d.resume(defaultAccount); err != nil {
+		t.Fatal(err)
+	}
+	d.cycle(context.Background())
+	if fake.Renewals("111") != 1 {
+		t.Errorf("expected a renewal once resumed, got %d", fake.Renewals("111"))
+	}
+	if err := d.resume(defaultAccount); err == nil {
+		t.Error("expected an error resuming an account that is not paused")
+	}
+	if _, err := d.find("", xserver.VPSID("111")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := d.find("other", xserver.VPSID("111")); err == nil {
+		t.Error("expected an error for a server of another account")
+	}
+}
+
+func Test_daemon_renewNow(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 7, 10, 12, 0, 0, 0, time.UTC)
+
+	t.Run("Paused account", func(t *testing.T) {
+		fake := xservertest.NewFakeClient().WithServer("111", now.Add(6*time.Hour))
+		d := testDaemon(fake, &now, "111")
+		if err := d.pause(defaultAccount); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := d.renewNow(ctx, "", "111"); err == nil || !strings.Contains(err.Error(), "paused") {
+			t.Errorf("expected a paused account to be refused, got %v", err)
+		}
+		if calls := fake.Calls(); len(calls) != 0 {
+			t.Errorf("expected no calls, got %v", calls)
+		}
+	})
+
+	t.Run("Removed by a reload", func(t *testing.T) {
+		fake := xservertest.NewFakeClient().WithServer("111", now.Add(6*time.Hour))
+		d := testDaemon(fake, &now, "111")
+		// As if a reload dropped the state of 111 right after find.
+		delete(d.state.Servers, stateKey(xserver.BatchItem{Account: defaultAccount, VPSID: "111"}))
+		if _, _, err := d.renewNow(ctx, "", "111"); err == nil || !strings.Contains(err.Error(), "not configured") {
+			t.Errorf("expected a not configured error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+	"x-revalidate-bot/pkg/xserver"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	CtlSocket  string
+	CtlAccount string
+)
+
+func init() {
+	ctlCmd.PersistentFlags().StringVar(&CtlSocket, "socket", defaultSocketPath(), "Control socket of the daemon")
+	ctlStatusCmd.Flags().BoolVar(&JSONOutput, "json", false, "Print JSON instead of a table")
+	ctlSchedulesCmd.Flags().BoolVar(&JSONOutput, "json", false, "Print JSON instead of a table")
+	ctlRenewCmd.Flags().StringVar(&CtlAccount, "account", "", "Account of the server, when several accounts have it")
+	ctlCmd.AddCommand(ctlStatusCmd, ctlSchedulesCmd, ctlRenewCmd, ctlPauseCmd, ctlResumeCmd, ctlReloadCmd)
+	rootCmd.AddCommand(ctlCmd)
+}
+
+var ctlCmd = &cobra.Command{
+	Use:   "ctl",
+	Short: "Query and drive a running daemon over its control socket",
+}
+
+var ctlStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the schedule, sessions and last results of the daemon",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var status DaemonStatus
+		if err := callControl("Status", ControlArgs{}, &status); err != nil {
+			return err
+		}
+		if JSONOutput {
+			return writeJSON(cmd.OutOrStdout(), status)
+		}
+		printDaemonStatus(cmd.OutOrStdout(), status, time.Now())
+		return nil
+	},
+}
+
+var ctlSchedulesCmd = &cobra.Command{
+	Use:   "schedules",
+	Short: "List when the daemon checks and renews every server next",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var schedules DaemonSchedules
+		if err := callControl("Schedules", ControlArgs{}, &schedules); err != nil {
+			return err
+		}
+		if JSONOutput {
+			return writeJSON(cmd.OutOrStdout(), schedules)
+		}
+		printSchedules(cmd.OutOrStdout(), schedules)
+		return nil
+	},
+}
+
+var ctlRenewCmd = &cobra.Command{
+	Use:   "renew VPS_ID",
+	Short: "Check a server now and renew it when its renewal window is open",
+	Long:  "Check a server now and renew it when its renewal window is open. Servers of paused accounts are refused until resumed.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		vpsID, err := xserver.ParseVPSID(args[0])
+		if err != nil {
+			return err
+		}
+		requested := time.Now()
+		var server DaemonServer
+		if err := callControl("Renew", ControlArgs{Account: CtlAccount, VPSID: vpsID}, &server); err != nil {
+			return err
+		}
+		printRenewal(cmd.OutOrStdout(), server, requested)
+		return nil
+	},
+}
+
+var ctlPauseCmd = &cobra.Command{
+	Use:   "pause ACCOUNT",
+	Short: "Leave the servers of an account alone until resumed",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var account AccountStatus
+		if err := callControl("Pause", ControlArgs{Account: args[0]}, &account); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "%s paused\n", account.Name)
+		return nil
+	},
+}
+
+var ctlResumeCmd = &cobra.Command{
+	Use:   "resume ACCOUNT",
+	Short: "Renew the servers of a paused account again",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var account AccountStatus
+		if err := callControl("Resume", ControlArgs{Account: args[0]}, &account); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "%s resumed\n", account.Name)
+		return nil
+	},
+}
+
+var ctlReloadCmd = &cobra.Command{
+	Use:   "reload",
+	Short: "Reload the config file and header profiles of the daemon",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var status DaemonStatus
+		if err := callControl("Reload", ControlArgs{}, &status); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Reloaded: %d accounts, %d servers\n", len(status.Accounts), len(status.Servers))
+		return nil
+	},
+}
+
+// callControl calls method of the daemon listening on CtlSocket.
+func callControl(method string, args ControlArgs, reply any) error {
+	client, err := dialControl(CtlSocket)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	return client.Call(controlService+"."+method, args, reply)
+}
+
+func printDaemonStatus(w io.Writer, status DaemonStatus, now time.Time) {
+	printSchedule(w, status.Schedule, status.Timezone)
+	if !status.NextRun.IsZero() {
+		fmt.Fprintf(w, "Next run: %s\n", formatExpiry(status.NextRun))
+	}
+	if !status.LastRun.IsZero() {
+		fmt.Fprintf(w, "Last run: %s\n", formatExpiry(status.LastRun))
+	}
+	fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACCOUNT\tSESSION\tCHECKED\tPAUSED")
+	for _, account := range status.Accounts {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%t\n", account.Name, account.Session, formatExpiry(account.CheckedAt), account.Paused)
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACCOUNT\tVPS ID\tEXPIRY\tREMAINING\tLAST ATTEMPT\tRESULT\tERROR")
+	for _, server := range status.Servers {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", server.Account, server.VPSID, formatExpiry(server.Expiry),
+			remaining(server.Expiry, now), formatExpiry(server.LastAttempt), orDash(server.LastResult), orDash(server.LastError))
+	}
+	tw.Flush()
+}
+
+func printSchedules(w io.Writer, schedules DaemonSchedules) {
+	printSchedule(w, schedules.Schedule, schedules.Timezone)
+	for _, next := range schedules.NextRuns {
+		fmt.Fprintf(w, "  %s\n", formatExpiry(next))
+	}
+	fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACCOUNT\tVPS ID\tPAUSED\tNEXT CHECK\tNEXT RENEWAL\tFAILURES")
+	for _, server := range schedules.Servers {
+		fmt.Fprintf(tw, "%s\t%s\t%t\t%s\t%s\t%d\n", server.Account, server.VPSID, server.Paused,
+			formatExpiry(server.NextCheck), formatExpiry(server.NextAttempt), server.Failures)
+	}
+	tw.Flush()
+}
+
+func printSchedule(w io.Writer, schedule, timezone string) {
+	if timezone != "" {
+		fmt.Fprintf(w, "Schedule: %s (%s)\n", schedule, timezone)
+		return
+	}
+	fmt.Fprintf(w, "Schedule: %s\n", schedule)
+}
+
+// printRenewal reports what the daemon did with a renewal requested at requested.
+func printRenewal(w io.Writer, server DaemonServer, requested time.Time) {
+	name := server.Account + "/" + server.VPSID.String()
+	switch {
+	case server.LastAttempt.Before(requested) && server.NextAttempt.IsZero():
+		fmt.Fprintf(w, "%s: not renewed, the expiry could not be read: %s\n", name, orDash(server.LastError))
+	case server.LastAttempt.Before(requested):
+		fmt.Fprintf(w, "%s: not renewable yet, renewal scheduled for %s\n", name, formatExpiry(server.NextAttempt))
+	case server.LastResult == "renewed":
+		fmt.Fprintf(w, "%s: renewed\n", name)
+	default:
+		fmt.Fprintf(w, "%s: failed: %s, retrying at %s\n", name, server.LastError, formatExpiry(server.NextAttempt))
+	}
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
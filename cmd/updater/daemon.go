@@ -0,0 +1,685 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+	"x-revalidate-bot/pkg/xserver"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	DaemonCheckInterval time.Duration
+	DaemonJitter        time.Duration
+	DaemonBackoff       time.Duration
+	DaemonMaxBackoff    time.Duration
+	DaemonStateFile     string
+	DaemonSocket        string
+	DaemonWatch         bool
+)
+
+func init() {
+	daemonCmd.Flags().DurationVar(&DaemonCheckInterval, "check-interval", 6*time.Hour, "Time between two expiry checks of a server")
+	daemonCmd.Flags().DurationVar(&DaemonJitter, "jitter", 30*time.Minute, "Upper bound of the random delay added after the renewal window opens")
+	daemonCmd.Flags().DurationVar(&DaemonBackoff, "backoff", 5*time.Minute, "Wait before retrying a failed renewal, doubled on every failure")
+	daemonCmd.Flags().DurationVar(&DaemonMaxBackoff, "max-backoff", 2*time.Hour, "Upper bound of the wait between two renewal attempts")
+	daemonCmd.Flags().DurationVar(&RenewalWindow, "renewal-window", xserver.DefaultRenewalWindow, "How long before its expiry a server is renewed")
+	daemonCmd.Flags().StringVar(&DaemonStateFile, "state-file", defaultStatePath(), "File the schedule is kept in across restarts")
+	daemonCmd.Flags().StringVar(&DaemonSocket, "socket", defaultSocketPath(), "Unix socket serving the control API used by \"updater ctl\", empty to disable")
+	daemonCmd.Flags().BoolVar(&DaemonWatch, "watch", true, "Reload when the config file or a header profile changes")
+	rootCmd.AddCommand(daemonCmd)
+}
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Keep running and renew every server when its renewal window opens (experimental)",
+	Long: `Keep running and renew every server when its renewal window opens, or at
+the fire times of schedule.cron when the config file sets one. Failed
+renewals are retried with backoff in both modes, between fire times too. A
+server whose expiry cannot be read is renewed anyway: right away in a
+scheduled run, after three checks in a row otherwise.
+
+The config file and header profiles are reloaded when they change or on
+SIGHUP; an invalid config is rejected and the current one kept. SIGUSR1 runs
+a check and renewal of every server right away and SIGUSR2 logs the daemon
+state. The same and more is available on the control socket with
+"updater ctl".` + experimentalNote,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		options := daemonOptions{
+			CheckInterval: DaemonCheckInterval,
+			Jitter:        DaemonJitter,
+			Backoff:       DaemonBackoff,
+			MaxBackoff:    DaemonMaxBackoff,
+			RenewalWindow: RenewalWindow,
+			StatePath:     DaemonStateFile,
+		}
+		if err := options.validate(); err != nil {
+			return err
+		}
+		loader := &daemonLoader{cmd: cmd}
+		clients, items, schedule, err := loader.load()
+		if err != nil {
+			return err
+		}
+		state, err := loadDaemonState(DaemonStateFile)
+		if err != nil {
+			return err
+		}
+
+		options.Schedule = schedule
+		d := newDaemon(clients, items, state, options)
+		// Cancelling the context also aborts requests in flight.
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		reload := func() error { return loader.reload(d) }
+		if DaemonSocket != "" {
+			listener, err := listenControl(DaemonSocket)
+			if err != nil {
+				return err
+			}
+			defer listener.Close()
+			go func() {
+				if err := serveControl(ctx, listener, d, reload); err != nil {
+					slog.Error("Error serving the control socket", "error", err)
+				}
+			}()
+		}
+		if DaemonWatch {
+			go func() {
+				if err := watchConfig(ctx, loader.paths, reload, time.Second); err != nil {
+					slog.Error("Error watching the config file", "error", err)
+				}
+			}()
+		}
+		go handleSignals(ctx, d, reload)
+		return d.run(ctx)
+	},
+}
+
+// clientSource returns the client of an account, as an AccountPool does.
+type clientSource interface {
+	Client(name string) (xserver.Client, bool)
+}
+
+type daemonOptions struct {
+	CheckInterval time.Duration
+	Jitter        time.Duration
+	Backoff       time.Duration
+	MaxBackoff    time.Duration
+	// RenewalWindow is how long before its expiry a server is renewed.
+	RenewalWindow time.Duration
+	// StatePath is where the state is saved after every cycle. Empty means nowhere.
+	StatePath string
+	// Schedule, when set, runs the renewals at its fire times instead of when
+	// the renewal windows open. Jitter is not applied then.
+	Schedule *cronSchedule
+}
+
+// validate rejects durations the schedule cannot work with: a zero check
+// interval or backoff would spin on the panel.
+func (o daemonOptions) validate() error {
+	switch {
+	case o.CheckInterval <= 0:
+		return fmt.Errorf("--check-interval must be positive, got %s", o.CheckInterval)
+	case o.Jitter < 0:
+		return fmt.Errorf("--jitter must not be negative, got %s", o.Jitter)
+	case o.Backoff <= 0:
+		return fmt.Errorf("--backoff must be positive, got %s", o.Backoff)
+	case o.MaxBackoff < o.Backoff:
+		return fmt.Errorf("--max-backoff %s must not be shorter than --backoff %s", o.MaxBackoff, o.Backoff)
+	case o.RenewalWindow <= 0:
+		return fmt.Errorf("--renewal-window must be positive, got %s", o.RenewalWindow)
+	}
+	return nil
+}
+
+// maxUnreadableChecks is how many expiry checks in a row may fail before the
+// daemon stops waiting for the expiry and attempts the renewal.
+const maxUnreadableChecks = 3
+
+// daemon renews every server shortly after its renewal window opens. It reads
+// the expiry of each server every CheckInterval, schedules the renewal for
+// the opening of the window plus a random jitter and backs off on failures.
+type daemon struct {
+	options daemonOptions
+	now     func() time.Time
+	jitter  func() time.Duration
+
+	// trigger asks for an immediate run and changed for the schedule to be
+	// worked out again after a reload.
+	trigger chan struct{}
+	changed chan struct{}
+	// running is held by the cycle in progress, so cycles never overlap.
+	running sync.Mutex
+
+	mu       sync.Mutex
+	clients  clientSource
+	items    []xserver.BatchItem
+	state    daemonState
+	sessions map[string]sessionHealth
+}
+
+// sessionHealth is what the last panel answer said about an account's session.
+type sessionHealth struct {
+	CheckedAt time.Time
+	// LoginRequired is set when the panel answered with its login page.
+	LoginRequired bool
+}
+
+func newDaemon(clients clientSource, items []xserver.BatchItem, state daemonState, options daemonOptions) *daemon {
+	d := &daemon{
+		options:  options,
+		now:      time.Now,
+		trigger:  make(chan struct{}, 1),
+		changed:  make(chan struct{}, 1),
+		clients:  clients,
+		items:    items,
+		state:    daemonState{Servers: keepServers(items, state.Servers), LastRun: state.LastRun, Paused: state.Paused},
+		sessions: map[string]sessionHealth{},
+	}
+	d.jitter = func() time.Duration {
+		if d.options.Jitter <= 0 || d.schedule() != nil {
+			return 0
+		}
+		return rand.N(d.options.Jitter)
+	}
+	return d
+}
+
+// keepServers returns the states of items, forgetting servers that are no
+// longer configured.
+func keepServers(items []xserver.BatchItem, states map[string]*serverState) map[string]*serverState {
+	servers := make(map[string]*serverState, len(items))
+	for _, item := range items {
+		s := states[stateKey(item)]
+		if s == nil {
+			s = &serverState{}
+		}
+		servers[stateKey(item)] = s
+	}
+	return servers
+}
+
+// apply replaces the clients, servers and schedule, as a reload does. The
+// state of servers still configured is kept and requests in flight finish
+// with the clients they started with.
+func (d *daemon) apply(clients clientSource, items []xserver.BatchItem, schedule *cronSchedule) {
+	d.mu.Lock()
+	d.clients, d.items, d.options.Schedule = clients, items, schedule
+	d.state.Servers = keepServers(items, d.state.Servers)
+	d.mu.Unlock()
+	wake(d.changed)
+}
+
+// runNow makes the daemon check every server and renew those that are due
+// right away, whatever their jitter or backoff.
+func (d *daemon) runNow() {
+	wake(d.trigger)
+}
+
+// wake wakes up the receiver of ch without blocking; a pending wake up is enough.
+func wake(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// run works through the schedule until ctx is done.
+func (d *daemon) run(ctx context.Context) error {
+	if schedule := d.schedule(); schedule != nil {
+		slog.Info("Daemon started", "servers", len(d.snapshot()), "schedule", schedule.Spec, "timezone", schedule.Location.String(), "state_file", d.options.StatePath)
+		if last := d.lastRun(); !last.IsZero() {
+			if missed := schedule.Next(last); missed.Before(d.now()) {
+				slog.Info("Catching up on a run missed while stopped", "missed_at", missed, "last_run", last)
+				d.scheduledRun(ctx)
+			}
+		}
+	} else {
+		slog.Info("Daemon started", "servers", len(d.snapshot()), "state_file", d.options.StatePath)
+	}
+
+	for {
+		// Without a schedule every cycle works out when a server is due next.
+		schedule := d.schedule()
+		var wait time.Duration
+		retry := false
+		if schedule == nil {
+			d.running.Lock()
+			next := d.cycle(ctx)
+			d.running.Unlock()
+			d.save()
+			wait = max(next.Sub(d.now()), time.Second)
+			slog.Debug("Waiting for the next due server", "until", next)
+		} else if next, at := schedule.Next(d.now()), d.retryAt(); !at.IsZero() && at.Before(next) {
+			// Failed renewals are retried after their backoff instead of
+			// waiting for the next fire time.
+			retry = true
+			wait = max(at.Sub(d.now()), 0)
+			slog.Info("Next retry of failed renewals", "at", at, "next_run", next)
+		} else {
+			wait = max(next.Sub(d.now()), 0)
+			slog.Info("Next scheduled run", "at", next)
+		}
+		if ctx.Err() != nil {
+			slog.Info("Daemon stopped")
+			return nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			slog.Info("Daemon stopped")
+			return nil
+		case <-d.changed:
+			timer.Stop()
+			continue
+		case <-d.trigger:
+			timer.Stop()
+			slog.Info("Run triggered")
+			d.forceDue()
+			retry = false
+		case <-timer.C:
+		}
+		switch {
+		case schedule != nil && retry:
+			d.retryRun(ctx)
+		case schedule != nil:
+			d.scheduledRun(ctx)
+		}
+	}
+}
+
+// scheduledRun reads the expiry of every server and renews those whose
+// window is open. It is skipped while another run is going, so runs never
+// overlap.
+func (d *daemon) scheduledRun(ctx context.Context) {
+	if !d.running.TryLock() {
+		slog.Warn("Skipping scheduled run, the previous one is still going")
+		return
+	}
+	defer d.running.Unlock()
+
+	start := d.now()
+	slog.Info("Scheduled run started")
+	d.forceDue()
+	d.cycle(ctx)
+	if ctx.Err() == nil {
+		// An interrupted run is made up for at the next start.
+		d.mu.Lock()
+		d.state.LastRun = start
+		d.mu.Unlock()
+		slog.Info("Scheduled run finished", "duration", d.now().Sub(start))
+	}
+	d.save()
+}
+
+// retryAt returns when the first failed renewal is due for another attempt,
+// or zero when none failed. A failed expiry check is not retried before the
+// next fire time.
+func (d *daemon) retryAt() time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var at time.Time
+	for _, item := range d.items {
+		s := d.state.Servers[stateKey(item)]
+		if s == nil || s.Failures == 0 || s.NextAttempt.IsZero() || slices.Contains(d.state.Paused, item.Account) {
+			continue
+		}
+		if at.IsZero() || s.NextAttempt.Before(at) {
+			at = s.NextAttempt
+		}
+	}
+	return at
+}
+
+// retryRun attempts the failed renewals that are due again between two
+// scheduled runs. Other servers wait for the next fire time.
+func (d *daemon) retryRun(ctx context.Context) {
+	if !d.running.TryLock() {
+		slog.Warn("Skipping retry, a run is still going")
+		return
+	}
+	defer d.running.Unlock()
+
+	slog.Info("Retrying failed renewals")
+	for _, item := range d.snapshot() {
+		if ctx.Err() != nil {
+			break
+		}
+		if s := d.server(item); s.Failures > 0 && !d.paused(item.Account) && !d.now().Before(s.NextAttempt) {
+			d.step(ctx, item)
+		}
+	}
+	d.save()
+}
+
+// forceDue makes every expiry due for a check, and every renewal whose
+// window is open due for an attempt.
+func (d *daemon) forceDue() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, s := range d.state.Servers {
+		s.force(d.now(), d.options.RenewalWindow)
+	}
+}
+
+func (s *serverState) force(now time.Time, window time.Duration) {
+	s.NextCheck = time.Time{}
+	if s.NextAttempt.After(now) && !s.Expiry.Add(-window).After(now) {
+		s.NextAttempt = now
+	}
+}
+
+// renewNow checks the server vpsID and renews it when its window is open,
+// as runNow does for every server. account may be left empty when only one
+// account has vpsID. Servers of paused accounts are refused.
+func (d *daemon) renewNow(ctx context.Context, account string, vpsID xserver.VPSID) (xserver.BatchItem, serverState, error) {
+	item, err := d.find(account, vpsID)
+	if err != nil {
+		return item, serverState{}, err
+	}
+	if !d.running.TryLock() {
+		return item, serverState{}, errors.New("a renewal run is in progress, try again later")
+	}
+	defer d.running.Unlock()
+
+	// A reload or a pause may have come in since find.
+	d.mu.Lock()
+	s, paused := d.state.Servers[stateKey(item)], slices.Contains(d.state.Paused, item.Account)
+	if s != nil && !paused {
+		s.force(d.now(), d.options.RenewalWindow)
+	}
+	d.mu.Unlock()
+	switch {
+	case s == nil:
+		return item, serverState{}, fmt.Errorf("VPS %s is not configured", vpsID)
+	case paused:
+		return item, serverState{}, fmt.Errorf("account %q is paused, resume it first", item.Account)
+	}
+	slog.Info("Renewal requested", "account", item.Account, "vps_id", item.VPSID)
+	d.step(ctx, item)
+	d.save()
+	if err := ctx.Err(); err != nil {
+		return item, serverState{}, err
+	}
+	return item, d.server(item), nil
+}
+
+func (d *daemon) find(account string, vpsID xserver.VPSID) (xserver.BatchItem, error) {
+	var found []xserver.BatchItem
+	for _, item := range d.snapshot() {
+		if item.VPSID == vpsID && (account == "" || item.Account == account) {
+			found = append(found, item)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return xserver.BatchItem{}, fmt.Errorf("VPS %s is not configured", vpsID)
+	case 1:
+		return found[0], nil
+	default:
+		return xserver.BatchItem{}, fmt.Errorf("VPS %s is configured in several accounts, pick one", vpsID)
+	}
+}
+
+// pause leaves the servers of account alone until resume is called, across
+// restarts too.
+func (d *daemon) pause(account string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !slices.ContainsFunc(d.items, func(item xserver.BatchItem) bool { return item.Account == account }) {
+		return fmt.Errorf("account %q is not configured", account)
+	}
+	if !slices.Contains(d.state.Paused, account) {
+		d.state.Paused = append(d.state.Paused, account)
+	}
+	slog.Info("Account paused", "account", account)
+	return nil
+}
+
+func (d *daemon) resume(account string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !slices.Contains(d.state.Paused, account) {
+		return fmt.Errorf("account %q is not paused", account)
+	}
+	d.state.Paused = slices.DeleteFunc(d.state.Paused, func(paused string) bool { return paused == account })
+	slog.Info("Account resumed", "account", account)
+	// Its servers may be due before the one the run loop waits for.
+	wake(d.changed)
+	return nil
+}
+
+func (d *daemon) paused(account string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return slices.Contains(d.state.Paused, account)
+}
+
+func (d *daemon) lastRun() time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.state.LastRun
+}
+
+func (d *daemon) schedule() *cronSchedule {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.options.Schedule
+}
+
+// snapshot returns the servers, which a reload may replace at any time.
+func (d *daemon) snapshot() []xserver.BatchItem {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.items
+}
+
+func (d *daemon) client(account string) (xserver.Client, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.clients.Client(account)
+}
+
+// checkSession records whether err says the session of account is logged out.
+// Other errors say nothing about the session and are ignored.
+func (d *daemon) checkSession(account string, err error) {
+	loginRequired := errors.Is(err, xserver.ErrLoginRequired) || xserver.ErrorCodeOf(err) == xserver.ErrorCodeLoginRequired
+	if err != nil && !loginRequired {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sessions[account] = sessionHealth{CheckedAt: d.now(), LoginRequired: loginRequired}
+}
+
+// dump logs the schedule, the sessions and the state of every server.
+func (d *daemon) dump() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+	if schedule := d.options.Schedule; schedule != nil {
+		slog.Info("Daemon schedule", "schedule", schedule.Spec, "timezone", schedule.Location.String(), "next_run", schedule.Next(now), "last_run", d.state.LastRun)
+	} else {
+		slog.Info("Daemon schedule", "schedule", "renewal window", "check_interval", d.options.CheckInterval, "jitter", d.options.Jitter)
+	}
+
+	for _, account := range d.accounts() {
+		health := d.sessions[account]
+		slog.Info("Session state", "account", account, "session", d.session(account), "checked_at", health.CheckedAt, "paused", slices.Contains(d.state.Paused, account))
+	}
+
+	for _, item := range d.items {
+		s := d.state.Servers[stateKey(item)]
+		slog.Info("Server state", "account", item.Account, "vps_id", item.VPSID,
+			"expiry", s.Expiry, "next_check", s.NextCheck, "next_attempt", s.NextAttempt, "failures", s.Failures,
+			"last_attempt", s.LastAttempt, "last_result", s.LastResult, "last_error", s.LastError)
+	}
+}
+
+// accounts lists the accounts with servers. d.mu must be held.
+func (d *daemon) accounts() []string {
+	var accounts []string
+	for _, item := range d.items {
+		if !slices.Contains(accounts, item.Account) {
+			accounts = append(accounts, item.Account)
+		}
+	}
+	return accounts
+}
+
+// session describes the session of account as "ok", "login required" or
+// "unknown" before the panel was asked. d.mu must be held.
+func (d *daemon) session(account string) string {
+	health, ok := d.sessions[account]
+	switch {
+	case !ok:
+		return "unknown"
+	case health.LoginRequired:
+		return "login required"
+	default:
+		return "ok"
+	}
+}
+
+// cycle handles every server that is due and returns when the next one is.
+func (d *daemon) cycle(ctx context.Context) time.Time {
+	next := d.now().Add(d.options.CheckInterval)
+	for _, item := range d.snapshot() {
+		if ctx.Err() != nil {
+			break
+		}
+		if d.paused(item.Account) {
+			continue
+		}
+		if due := d.step(ctx, item); due.Before(next) {
+			next = due
+		}
+	}
+	return next
+}
+
+// step checks the expiry of item and renews it when it is due. It returns
+// when item needs attention again.
+func (d *daemon) step(ctx context.Context, item xserver.BatchItem) time.Time {
+	logger := slog.With("account", item.Account, "vps_id", item.VPSID)
+	s := d.server(item)
+	client, ok := d.client(item.Account)
+	if !ok {
+		logger.Error("No client for account")
+		return d.now().Add(d.options.CheckInterval)
+	}
+
+	if now := d.now(); !now.Before(s.NextCheck) {
+		status, err := client.GetServerStatus(ctx, item.VPSID)
+		if ctx.Err() != nil {
+			return now
+		}
+		d.checkSession(item.Account, err)
+		switch {
+		case err != nil:
+			s.CheckFailures++
+			s.LastError = err.Error()
+			s.NextCheck = now.Add(min(d.options.Backoff, d.options.CheckInterval))
+			// A renewal already scheduled from an earlier expiry stands.
+			// Without one none would ever be scheduled, so renewing is the
+			// only way left to keep the server: a scheduled run does so right
+			// away, as extend from cron would, the renewal window mode after a
+			// few checks.
+			if !s.NextAttempt.IsZero() || d.schedule() == nil && s.CheckFailures < maxUnreadableChecks {
+				logger.Warn("Error reading server status", "error", err, "error_code", xserver.ErrorCodeOf(err), "failures", s.CheckFailures)
+				break
+			}
+			logger.Error("Expiry unreadable, renewing without it", "error", err, "error_code", xserver.ErrorCodeOf(err), "failures", s.CheckFailures)
+			s.NextAttempt = now
+		default:
+			s.CheckFailures = 0
+			s.NextCheck = now.Add(d.options.CheckInterval)
+			if status.Expiry.Equal(s.Expiry) && !s.NextAttempt.IsZero() {
+				break
+			}
+			s.Expiry = status.Expiry
+			opens := status.Expiry.Add(-d.options.RenewalWindow)
+			if opens.Before(now) {
+				opens = now
+			}
+			s.NextAttempt = opens.Add(d.jitter())
+			s.Failures = 0
+			logger.Info("Renewal scheduled", "expiry", s.Expiry, "at", s.NextAttempt)
+		}
+	}
+
+	if now := d.now(); !s.NextAttempt.IsZero() && !now.Before(s.NextAttempt) {
+		logger.Info("Renewing VPS", "expiry", s.Expiry, "attempt", s.Failures+1)
+		err := xserver.Renew(ctx, client, item.VPSID)
+		if ctx.Err() != nil {
+			return now
+		}
+		d.checkSession(item.Account, err)
+		s.LastAttempt = now
+		switch {
+		case err == nil, xserver.ErrorCodeOf(err) == xserver.ErrorCodeAlreadyRenewed:
+			logger.Info("VPS renewed")
+			s.LastResult, s.LastError = "renewed", ""
+			s.Failures = 0
+			// Read the new expiry on the next cycle to schedule the next renewal.
+			s.NextAttempt, s.NextCheck = time.Time{}, time.Time{}
+		default:
+			s.Failures++
+			wait := backoffUntil(err, d.options.Backoff<<(min(s.Failures, 16)-1), d.options.MaxBackoff, now)
+			s.NextAttempt = now.Add(wait)
+			s.LastResult, s.LastError = "failed", err.Error()
+			logger.Error("Error renewing VPS", "error", err, "error_code", xserver.ErrorCodeOf(err), "failures", s.Failures, "retry_at", s.NextAttempt)
+		}
+	}
+
+	d.setServer(item, s)
+	due := s.NextCheck
+	if !s.NextAttempt.IsZero() && s.NextAttempt.Before(due) {
+		due = s.NextAttempt
+	}
+	return due
+}
+
+// server returns a copy of the state of item, so no lock is held while the
+// panel is being talked to.
+func (d *daemon) server(item xserver.BatchItem) serverState {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if s := d.state.Servers[stateKey(item)]; s != nil {
+		return *s
+	}
+	return serverState{}
+}
+
+// setServer stores the state of item unless a reload removed it meanwhile.
+func (d *daemon) setServer(item xserver.BatchItem, s serverState) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if current := d.state.Servers[stateKey(item)]; current != nil {
+		*current = s
+	}
+}
+
+func (d *daemon) save() {
+	if d.options.StatePath == "" {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.state.save(d.options.StatePath); err != nil {
+		slog.Error("Error saving daemon state", "error", err, "path", d.options.StatePath)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+import "context"
+
+// handleSignals does nothing where SIGHUP, SIGUSR1 and SIGUSR2 do not exist.
+func handleSignals(ctx context.Context, d *daemon, reload func() error) {}
@@ -0,0 +1,37 @@
+//go:build unix
+
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// handleSignals controls d with signals until ctx is done: SIGHUP reloads the
+// config with reload, SIGUSR1 triggers a run and SIGUSR2 logs the state.
+func handleSignals(ctx context.Context, d *daemon, reload func() error) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGUSR2)
+	defer signal.Stop(signals)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-signals:
+			slog.Info("Signal received", "signal", sig.String())
+			switch sig {
+			case syscall.SIGHUP:
+				if err := reload(); err != nil {
+					slog.Error("Error reloading config, keeping the current one", "error", err)
+				}
+			case syscall.SIGUSR1:
+				d.runNow()
+			case syscall.SIGUSR2:
+				d.dump()
+			}
+		}
+	}
+}
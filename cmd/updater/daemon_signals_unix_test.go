@@ -0,0 +1,55 @@
+//go:build unix
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+	"x-revalidate-bot/pkg/xserver/xservertest"
+)
+
+func Test_handleSignals(t *testing.T) {
+	// Keep the default actions away, even before handleSignals subscribes.
+	guard := make(chan os.Signal, 3)
+	signal.Notify(guard, syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGUSR2)
+	defer signal.Stop(guard)
+
+	now := time.Date(2025, 7, 10, 12, 0, 0, 0, time.UTC)
+	d := testDaemon(xservertest.NewFakeClient().WithServer("111", now.Add(30*time.Hour)), &now, "111")
+	reloads := make(chan struct{}, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		handleSignals(ctx, d, func() error { reloads <- struct{}{}; return nil })
+		close(done)
+	}()
+
+	// Signals sent before handleSignals subscribed are lost, so keep sending.
+	sendUntil := func(sig syscall.Signal, received <-chan struct{}) {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+			if err := syscall.Kill(os.Getpid(), sig); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case <-received:
+				return
+			case <-time.After(50 * time.Millisecond):
+			}
+		}
+		t.Errorf("%v was not handled", sig)
+	}
+	sendUntil(syscall.SIGHUP, reloads)
+	sendUntil(syscall.SIGUSR1, d.trigger)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("expected handleSignals to return once cancelled")
+	}
+}
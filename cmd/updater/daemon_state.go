@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+	"x-revalidate-bot/pkg/xserver"
+)
+
+// serverState is what the daemon knows about one server.
+type serverState struct {
+	Expiry time.Time `json:"expiry,omitzero"`
+	// NextCheck is when Expiry is read again. Zero means right away.
+	NextCheck time.Time `json:"next_check,omitzero"`
+	// NextAttempt is when the next renewal is due. Zero means none is scheduled.
+	NextAttempt time.Time `json:"next_attempt,omitzero"`
+	// Failures counts the renewal failures since the last success.
+	Failures int `json:"failures,omitempty"`
+	// CheckFailures counts the expiry checks that failed in a row.
+	CheckFailures int       `json:"check_failures,omitempty"`
+	LastAttempt   time.Time `json:"last_attempt,omitzero"`
+	LastResult    string    `json:"last_result,omitempty"`
+	LastError     string    `json:"last_error,omitempty"`
+}
+
+// daemonState is persisted by the daemon so a restart keeps its schedule.
+type daemonState struct {
+	Servers map[string]*serverState `json:"servers"`
+	// LastRun is when the last scheduled run started, to catch up on runs
+	// missed while the daemon was stopped.
+	LastRun time.Time `json:"last_run,omitzero"`
+	// Paused are the accounts whose servers are left alone until resumed.
+	Paused []string `json:"paused,omitempty"`
+}
+
+func stateKey(item xserver.BatchItem) string {
+	return item.Account + "/" + item.VPSID.String()
+}
+
+// defaultStatePath is $XDG_STATE_HOME/x-revalidate-bot/daemon.json.
+func defaultStatePath() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "daemon.json"
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, configDirName, "daemon.json")
+}
+
+// loadDaemonState reads the state file. A missing file is an empty state.
+func loadDaemonState(path string) (daemonState, error) {
+	state := daemonState{Servers: map[string]*serverState{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	} else if err != nil {
+		return state, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("%s: %w", path, err)
+	}
+	if state.Servers == nil {
+		state.Servers = map[string]*serverState{}
+	}
+	return state, nil
+}
+
+// save writes the state through a temporary file so a crash never leaves a
+// truncated file behind.
+func (s daemonState) save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".daemon-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"x-revalidate-bot/pkg/xserver"
+	"x-revalidate-bot/pkg/xserver/xservertest"
+)
+
+// fakeClients serves the same client for every account.
+type fakeClients struct {
+	client xserver.Client
+}
+
+func (f fakeClients) Client(string) (xserver.Client, bool) { return f.client, true }
+
+// blockingClient holds GetServerStatus until release is closed, after
+// reporting the call on started.
+type blockingClient struct {
+	*xservertest.FakeClient
+	started chan struct{}
+	release chan struct{}
+}
+
+func (c blockingClient) GetServerStatus(ctx context.Context, vpsID xserver.VPSID) (xserver.ServerStatus, error) {
+	c.started <- struct{}{}
+	<-c.release
+	return c.FakeClient.GetServerStatus(ctx, vpsID)
+}
+
+// testDaemon returns a daemon without jitter whose clock is *now.
+func testDaemon(fake *xservertest.FakeClient, now *time.Time, ids ...xserver.VPSID) *daemon {
+	fake.WithClock(func() time.Time { return *now })
+	items := make([]xserver.BatchItem, len(ids))
+	for i, id := range ids {
+		items[i] = xserver.BatchItem{Account: defaultAccount, VPSID: id}
+	}
+	d := newDaemon(fakeClients{fake}, items, daemonState{}, daemonOptions{
+		CheckInterval: time.Hour,
+		Backoff:       5 * time.Minute,
+		MaxBackoff:    time.Hour,
+		RenewalWindow: 24 * time.Hour,
+	})
+	d.now = func() time.Time { return *now }
+	return d
+}
+
+func Test_daemon_cycle(t *testing.T) {
+	ctx := context.Background()
+	item := xserver.BatchItem{Account: defaultAccount, VPSID: "111"}
+
+	t.Run("Renews when the window opens", func(t *testing.T) {
+		now := time.Date(2025, 7, 10, 12, 0, 0, 0, time.UTC)
+		expiry := now.Add(30 * time.Hour)
+		fake := xservertest.NewFakeClient().WithServer("111", expiry)
+		d := testDaemon(fake, &now, "111")
+
+		if next := d.cycle(ctx); !next.Equal(now.Add(time.Hour)) {
+			t.Errorf("expected the next check in an hour, got %v", next)
+		}
+		if s := d.server(item); !s.NextAttempt.Equal(expiry.Add(-24 * time.Hour)) {
+			t.Errorf("expected the renewal when the window opens, got %v", s.NextAttempt)
+		}
+		fake.AssertNotCalled(t, xserver.OperationExtend)
+
+		now = now.Add(6 * time.Hour)
+		d.cycle(ctx)
+		if fake.Renewals("111") != 1 {
+			t.Fatalf("expected one renewal, got %d", fake.Renewals("111"))
+		}
+		if s := d.server(item); s.LastResult != "renewed" || !s.NextAttempt.IsZero() {
+			t.Errorf("unexpected state after renewal %+v", s)
+		}
+
+		// The new expiry is read on the next cycle and the next renewal scheduled.
+		d.cycle(ctx)
+		newExpiry, _ := fake.Expiry("111")
+		if s := d.server(item); !s.Expiry.Equal(newExpiry) || !s.NextAttempt.Equal(newExpiry.Add(-24*time.Hour)) {
+			t.Errorf("expected the next renewal to be scheduled, got %+v", s)
+		}
+	})
+
+	t.Run("Backs off on failures", func(t *testing.T) {
+		now := time.Date(2025, 7, 10, 12, 0, 0, 0, time.UTC)
+		fake := xservertest.NewFakeClient().
+			WithServer("111", now.Add(6*time.Hour)).
+			WithError(xserver.OperationGetCSRFToken, errors.New("connection reset")).
+			WithError(xserver.OperationGetCSRFToken, errors.New("connection reset"))
+		d := testDaemon(fake, &now, "111")
+
+		if next := d.cycle(ctx); !next.Equal(now.Add(5 * time.Minute)) {
+			t.Errorf("expected a retry in 5m, got %v", next.Sub(now))
+		}
+		now = now.Add(5 * time.Minute)
+		if next := d.cycle(ctx); !next.Equal(now.Add(10 * time.Minute)) {
+			t.Errorf("expected a retry in 10m, got %v", next.Sub(now))
+		}
+		if s := d.server(item); s.Failures != 2 || s.LastResult != "failed" {
+			t.Errorf("unexpected state %+v", s)
+		}
+		now = now.Add(10 * time.Minute)
+		d.cycle(ctx)
+		if s := d.server(item); s.Failures != 0 || fake.Renewals("111") != 1 {
+			t.Errorf("expected the third attempt to succeed, got %+v", s)
+		}
+	})
+
+	t.Run("Renews when the expiry cannot be read", func(t *testing.T) {
+		now := time.Date(2025, 7, 10, 12, 0, 0, 0, time.UTC)
+		fake := xservertest.NewFakeClient().WithServer("111", now.Add(6*time.Hour))
+		for range maxUnreadableChecks {
+			fake.WithError(xserver.OperationGetStatus, errors.New("expiry not found"))
+		}
+		d := testDaemon(fake, &now, "111")
+
+		for i := 1; i < maxUnreadableChecks; i++ {
+			if next := d.cycle(ctx); !next.Equal(now.Add(5 * time.Minute)) {
+				t.Errorf("check %d: expected another check in 5m, got %v", i, next.Sub(now))
+			}
+			fake.AssertNotCalled(t, xserver.OperationExtend)
+			now = now.Add(5 * time.Minute)
+		}
+		d.cycle(ctx)
+		if fake.Renewals("111") != 1 {
+			t.Fatalf("expected a renewal after %d unreadable checks, got %d", maxUnreadableChecks, fake.Renewals("111"))
+		}
+		d.cycle(ctx)
+		if s := d.server(item); s.CheckFailures != 0 || s.Expiry.IsZero() {
+			t.Errorf("expected the expiry to be read again, got %+v", s)
+		}
+	})
+
+	t.Run("Stops when cancelled", func(t *testing.T) {
+		now := time.Now()
+		fake := xservertest.NewFakeClient().WithServer("111", now.Add(6*time.Hour))
+		d := testDaemon(fake, &now, "111")
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		if err := d.run(ctx); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		fake.AssertNotCalled(t, xserver.OperationExtend)
+	})
+}
+
+func Test_daemonState_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "daemon.json")
+	now := time.Date(2025, 7, 10, 12, 0, 0, 0, time.UTC)
+	fake := xservertest.NewFakeClient().WithServer("111", now.Add(30*time.Hour))
+	d := testDaemon(fake, &now, "111")
+	d.options.StatePath = path
+	d.cycle(context.Background())
+	d.save()
+
+	state, err := loadDaemonState(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// A restarted daemon keeps the schedule and drops servers no longer configured.
+	state.Servers["default/999"] = &serverState{}
+	restarted := newDaemon(fakeClients{fake}, d.items, state, d.options)
+	if len(restarted.state.Servers) != 1 {
+		t.Errorf("expected only the configured server, got %v", restarted.state.Servers)
+	}
+	if s := restarted.server(d.items[0]); !s.NextAttempt.Equal(now.Add(6 * time.Hour)) {
+		t.Errorf("expected the schedule to survive, got %+v", s)
+	}
+
+	if state, err := loadDaemonState(filepath.Join(t.TempDir(), "missing.json")); err != nil || len(state.Servers) != 0 {
+		t.Errorf("expected an empty state for a missing file, got %v, %v", state, err)
+	}
+}
+
+func Test_daemon_scheduledRun(t *testing.T) {
+	schedule, err := parseSchedule(ScheduleConfig{Cron: "0 9 * * *", Timezone: "UTC"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := xserver.BatchItem{Account: defaultAccount, VPSID: "111"}
+
+	t.Run("Renews only servers in their window", func(t *testing.T) {
+		now := time.Date(2025, 7, 10, 9, 0, 0, 0, time.UTC)
+		fake := xservertest.NewFakeClient().
+			WithServer("111", now.Add(6*time.Hour)).
+			WithServer("222", now.Add(30*time.Hour))
+		d := testDaemon(fake, &now, "111", "222")
+		d.options.Schedule = schedule
+		d.options.Jitter = time.Hour
+
+		d.scheduledRun(context.Background())
+		if fake.Renewals("111") != 1 || fake.Renewals("222") != 0 {
+			t.Errorf("expected only 111 to be renewed, got %d and %d", fake.Renewals("111"), fake.Renewals("222"))
+		}
+		if !d.lastRun().Equal(now) {
+			t.Errorf("expected the run to be recorded, got %v", d.lastRun())
+		}
+
+		// The next run reads the expiries again even though no check is due.
+		now = now.Add(24 * time.Hour)
+		d.scheduledRun(context.Background())
+		if fake.Renewals("222") != 1 {
+			t.Errorf("expected 222 to be renewed on the next run, got %d", fake.Renewals("222"))
+		}
+	})
+
+	t.Run("Renews when the expiry cannot be read", func(t *testing.T) {
+		now := time.Date(2025, 7, 10, 9, 0, 0, 0, time.UTC)
+		fake := xservertest.NewFakeClient().
+			WithServer("111", now.Add(6*time.Hour)).
+			WithError(xserver.OperationGetStatus, errors.New("expiry not found"))
+		d := testDaemon(fake, &now, "111")
+		d.options.Schedule = schedule
+
+		d.scheduledRun(context.Background())
+		if fake.Renewals("111") != 1 {
+			t.Errorf("expected the run to renew without the expiry, got %d renewals", fake.Renewals("111"))
+		}
+		if s := d.server(item); s.LastResult != "renewed" {
+			t.Errorf("unexpected state %+v", s)
+		}
+	})
+
+	t.Run("Never overlaps", func(t *testing.T) {
+		now := time.Date(2025, 7, 10, 9, 0, 0, 0, time.UTC)
+		fake := xservertest.NewFakeClient().WithServer("111", now.Add(6*time.Hour))
+		d := testDaemon(fake, &now, "111")
+		d.options.Schedule = schedule
+		blocking := blockingClient{FakeClient: fake, started: make(chan struct{}), release: make(chan struct{})}
+		d.clients = fakeClients{blocking}
+
+		// A renewal requested over the control socket is still going.
+		done := make(chan error)
+		go func() {
+			_, _, err := d.renewNow(context.Background(), "", "111")
+			done <- err
+		}()
+		<-blocking.started
+		d.scheduledRun(context.Background())
+		close(blocking.release)
+		if err := <-done; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if fake.Renewals("111") != 1 || !d.lastRun().IsZero() {
+			t.Errorf("expected the scheduled run to be skipped, got %d renewals, last run %v", fake.Renewals("111"), d.lastRun())
+		}
+	})
+
+	t.Run("Retries failed renewals before the next run", func(t *testing.T) {
+		now := time.Date(2025, 7, 10, 9, 0, 0, 0, time.UTC)
+		fake := xservertest.NewFakeClient().
+			WithServer("111", now.Add(6*time.Hour)).
+			WithServer("222", now.Add(30*time.Hour)).
+			WithError(xserver.OperationGetCSRFToken, errors.New("connection reset"))
+		d := testDaemon(fake, &now, "111", "222")
+		d.options.Schedule = schedule
+
+		d.scheduledRun(context.Background())
+		if at := d.retryAt(); !at.Equal(now.Add(5 * time.Minute)) {
+			t.Fatalf("expected a retry in 5m, got %v", at)
+		}
+		now = now.Add(5 * time.Minute)
+		d.retryRun(context.Background())
+		if fake.Renewals("111") != 1 || !d.retryAt().IsZero() {
+			t.Errorf("expected the retry to renew 111, got %d renewals, retry at %v", fake.Renewals("111"), d.retryAt())
+		}
+		// 222 opens its window before the next fire time but waits for it.
+		now = now.Add(24 * time.Hour)
+		d.retryRun(context.Background())
+		if fake.Renewals("222") != 0 {
+			t.Errorf("expected 222 to wait for the next run, got %d renewals", fake.Renewals("222"))
+		}
+	})
+
+	t.Run("Catches up on a missed run", func(t *testing.T) {
+		now := time.Date(2025, 7, 10, 12, 0, 0, 0, time.UTC)
+		fake := xservertest.NewFakeClient().WithServer("111", now.Add(6*time.Hour))
+		d := testDaemon(fake, &now, "111")
+		d.options.Schedule = schedule
+		d.state.LastRun = now.Add(-27 * time.Hour) // Missed today's 09:00.
+
+		// The run loop then waits for tomorrow's 09:00 until cancelled.
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		if err := d.run(ctx); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if fake.Renewals("111") != 1 || !d.lastRun().Equal(now) {
+			t.Errorf("expected a catch-up run, got %d renewals, last run %v", fake.Renewals("111"), d.lastRun())
+		}
+		if s := d.server(item); s.LastResult != "renewed" {
+			t.Errorf("unexpected state %+v", s)
+		}
+	})
+
+	t.Run("Does not catch up when nothing was missed", func(t *testing.T) {
+		now := time.Date(2025, 7, 10, 12, 0, 0, 0, time.UTC)
+		fake := xservertest.NewFakeClient().WithServer("111", now.Add(6*time.Hour))
+		d := testDaemon(fake, &now, "111")
+		d.options.Schedule = schedule
+		d.state.LastRun = now.Add(-3 * time.Hour)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if err := d.run(ctx); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if calls := fake.Calls(); len(calls) != 0 {
+			t.Errorf("expected no run, got %v", calls)
+		}
+	})
+}
+
+func Test_daemon_runNow(t *testing.T) {
+	now := time.Date(2025, 7, 10, 12, 0, 0, 0, time.UTC)
+	fake := xservertest.NewFakeClient().WithServer("111", now.Add(6*time.Hour))
+	d := testDaemon(fake, &now, "111")
+	d.jitter = func() time.Duration { return time.Hour }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- d.run(ctx) }()
+	// The first cycle waits out the jitter, the triggered one does not.
+	d.runNow()
+	for deadline := time.Now().Add(5 * time.Second); fake.Renewals("111") == 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if fake.Renewals("111") != 1 {
+		t.Errorf("expected the triggered run to renew, got %d renewals", fake.Renewals("111"))
+	}
+}
+
+func Test_daemon_apply(t *testing.T) {
+	now := time.Date(2025, 7, 10, 12, 0, 0, 0, time.UTC)
+	fake := xservertest.NewFakeClient().
+		WithServer("111", now.Add(30*time.Hour)).
+		WithServer("222", now.Add(30*time.Hour))
+	d := testDaemon(fake, &now, "111")
+	d.cycle(context.Background())
+	first, second := d.snapshot()[0], xserver.BatchItem{Account: defaultAccount, VPSID: "222"}
+
+	d.apply(fakeClients{fake}, []xserver.BatchItem{first, second}, nil)
+	if s := d.server(first); s.NextAttempt.IsZero() {
+		t.Errorf("expected the state of 111 to be kept, got %+v", s)
+	}
+	if s := d.server(second); s != (serverState{}) {
+		t.Errorf("expected an empty state for 222, got %+v", s)
+	}
+	select {
+	case <-d.changed:
+	default:
+		t.Error("expected the run loop to be woken up")
+	}
+
+	d.apply(fakeClients{fake}, []xserver.BatchItem{second}, nil)
+	if _, ok := d.state.Servers[stateKey(first)]; ok {
+		t.Error("expected the state of 111 to be dropped")
+	}
+}
+
+func Test_daemon_dump(t *testing.T) {
+	var logs bytes.Buffer
+	saved := slog.Default()
+	defer slog.SetDefault(saved)
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+
+	now := time.Date(2025, 7, 10, 12, 0, 0, 0, time.UTC)
+	fake := xservertest.NewFakeClient().WithServer("111", now.Add(30*time.Hour))
+	d := testDaemon(fake, &now, "111")
+	d.cycle(context.Background())
+	d.dump()
+
+	for _, want := range []string{
+		`"msg":"Daemon schedule","schedule":"renewal window"`,
+		`"msg":"Session state","account":"default","session":"ok"`,
+		`"msg":"Server state","account":"default","vps_id":"111","expiry":"2025-07-11T18:00:00Z"`,
+	} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("expected the logs to contain %s, got:\n%s", want, logs.String())
+		}
+	}
+}
+
+func Test_daemonOptions_validate(t *testing.T) {
+	valid := daemonOptions{CheckInterval: time.Hour, Backoff: time.Minute, MaxBackoff: time.Hour, RenewalWindow: 24 * time.Hour}
+	tests := []struct {
+		name    string
+		modify  func(*daemonOptions)
+		wantErr string
+	}{
+		{name: "Valid", modify: func(*daemonOptions) {}},
+		{name: "Equal backoffs", modify: func(o *daemonOptions) { o.MaxBackoff = o.Backoff }},
+		{name: "Zero check interval", modify: func(o *daemonOptions) { o.CheckInterval = 0 }, wantErr: "--check-interval"},
+		{name: "Negative jitter", modify: func(o *daemonOptions) { o.Jitter = -time.Second }, wantErr: "--jitter"},
+		{name: "Zero backoff", modify: func(o *daemonOptions) { o.Backoff = 0 }, wantErr: "--backoff"},
+		{name: "Max backoff below backoff", modify: func(o *daemonOptions) { o.MaxBackoff = time.Second }, wantErr: "--max-backoff"},
+		{name: "Zero renewal window", modify: func(o *daemonOptions) { o.RenewalWindow = 0 }, wantErr: "--renewal-window"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := valid
+			tt.modify(&options)
+			err := options.validate()
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.HasPrefix(err.Error(), tt.wantErr)) {
+				t.Errorf("expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
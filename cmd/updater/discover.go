@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"time"
+	"x-revalidate-bot/pkg/xserver"
+)
+
+var (
+	ExtendAll     bool
+	Include       []string
+	Exclude       []string
+	RenewalWindow time.Duration
+)
+
+func init() {
+	extendFlags.BoolVar(&ExtendAll, "all", false, "Renew every free VPS listed on the panel instead of VPS_ID (experimental, see \"updater list --help\")")
+	extendFlags.StringArrayVar(&Include, "include", nil, "With --all, only renew servers whose ID or name matches this glob pattern, repeatable")
+	extendFlags.StringArrayVar(&Exclude, "exclude", nil, "With --all, skip servers whose ID or name matches this glob pattern, repeatable")
+	extendFlags.DurationVar(&RenewalWindow, "renewal-window", xserver.DefaultRenewalWindow, "With --all, skip servers expiring later than this from now")
+}
+
+// serverFilter picks the servers --all renews.
+type serverFilter struct {
+	include []string
+	exclude []string
+	window  time.Duration
+}
+
+func newServerFilter(include, exclude []string, window time.Duration) (serverFilter, error) {
+	for _, pattern := range append(append([]string{}, include...), exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return serverFilter{}, fmt.Errorf("pattern %q: %w", pattern, err)
+		}
+	}
+	return serverFilter{include: include, exclude: exclude, window: window}, nil
+}
+
+// skipReason returns why server is not renewed now, or "" to renew it.
+func (f serverFilter) skipReason(server xserver.Server, now time.Time) string {
+	switch {
+	case !server.Free:
+		return fmt.Sprintf("not a free plan (%s)", server.Plan)
+	case len(f.include) > 0 && !matchesAny(f.include, server):
+		return "not matched by --include"
+	case matchesAny(f.exclude, server):
+		return "matched by --exclude"
+	case !server.Expiry.IsZero() && server.Expiry.Sub(now) > f.window:
+		return fmt.Sprintf("not renewable yet, %s left", remaining(server.Expiry, now))
+	}
+	return ""
+}
+
+func matchesAny(patterns []string, server xserver.Server) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, server.VPSID.String()); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, server.Name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// discover lists the servers of every account and splits them into the ones
+// to renew, with the expiries the list shows, and the skipped ones. An account
+// whose list cannot be read is reported as a failed row.
+func discover(ctx context.Context, pool *xserver.AccountPool, filter serverFilter, now time.Time) ([]xserver.BatchItem, map[xserver.BatchItem]time.Time, []renewal) {
+	var items []xserver.BatchItem
+	expiries := make(map[xserver.BatchItem]time.Time)
+	var others []renewal
+	for _, account := range pool.Accounts() {
+		client, _ := pool.Client(account.Name)
+		servers, err := client.ListServers(ctx)
+		if err != nil {
+			slog.Error("Error listing servers", "error", err, "error_code", xserver.ErrorCodeOf(err), "account", account.Name)
+			others = append(others, renewal{Item: xserver.BatchItem{Account: account.Name}, Err: fmt.Errorf("listing servers: %w", err)})
+			continue
+		}
+		for _, server := range servers {
+			item := xserver.BatchItem{Account: account.Name, VPSID: server.VPSID}
+			if reason := filter.skipReason(server, now); reason != "" {
+				slog.Info("Skipping VPS", "account", account.Name, "vps_id", server.VPSID, "reason", reason)
+				others = append(others, renewal{Item: item, Previous: server.Expiry, Skipped: reason})
+				continue
+			}
+			items = append(items, item)
+			if !server.Expiry.IsZero() {
+				expiries[item] = server.Expiry
+			}
+		}
+	}
+	return items, expiries, others
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+	"x-revalidate-bot/pkg/xserver"
+	"x-revalidate-bot/pkg/xserver/xservertest"
+)
+
+func Test_serverFilter_skipReason(t *testing.T) {
+	now := time.Date(2025, 7, 10, 12, 0, 0, 0, time.UTC)
+	filter, err := newServerFilter([]string{"vps-*", "999"}, []string{"vps-2*"}, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		server   xserver.Server
+		expected string
+	}{
+		{name: "Renewable", server: xserver.Server{VPSID: "111", Name: "vps-111", Free: true, Expiry: now.Add(6 * time.Hour)}},
+		{name: "Matched by ID", server: xserver.Server{VPSID: "999", Name: "other", Free: true}},
+		{name: "Paid", server: xserver.Server{VPSID: "111", Name: "vps-111", Plan: "2GB"}, expected: "not a free plan (2GB)"},
+		{name: "Not included", server: xserver.Server{VPSID: "111", Name: "other", Free: true}, expected: "not matched by --include"},
+		{name: "Excluded", server: xserver.Server{VPSID: "222", Name: "vps-222", Free: true}, expected: "matched by --exclude"},
+		{name: "Too early", server: xserver.Server{VPSID: "111", Name: "vps-111", Free: true, Expiry: now.Add(30 * time.Hour)}, expected: "not renewable yet, 1d6h left"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := filter.skipReason(tt.server, now); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+
+	if _, err := newServerFilter([]string{"["}, nil, time.Hour); err == nil {
+		t.Error("expected an error for a malformed pattern")
+	}
+}
+
+func Test_runInternally_All(t *testing.T) {
+	withoutPacing(t)
+	defer func(all bool, exclude []string) { ExtendAll, Exclude = all, exclude }(ExtendAll, Exclude)
+	ExtendAll, Exclude = true, []string{"vps-444"}
+
+	panel := xservertest.NewPanel(xservertest.PanelOptions{
+		SessionID: "fake-session",
+		DeviceKey: "fake-devicekey",
+	})
+	panel.AddServer("111", time.Now().Add(6*time.Hour))
+	panel.AddServer("222", time.Now().Add(6*time.Hour))
+	panel.SetPlan("222", xservertest.PlanPaid)
+	panel.AddServer("333", time.Now().Add(40*time.Hour))
+	panel.AddServer("444", time.Now().Add(6*time.Hour))
+	srv := httptest.NewServer(panel)
+	defer srv.Close()
+
+	t.Setenv("VPS_ID", "")
+	t.Setenv("X2SESSID", "fake-session")
+	t.Setenv("XSERVER_DEVICEKEY", "fake-devicekey")
+	t.Setenv("XSERVER_BASE_URL", srv.URL)
+
+	var out bytes.Buffer
+	if err := runInternally(context.Background(), &out); err != nil {
+		t.Fatalf("runInternally failed: %v\n%s", err, out.String())
+	}
+	for id, expected := range map[xserver.VPSID]int{"111": 1, "222": 0, "333": 0, "444": 0} {
+		if got := panel.Renewals(id); got != expected {
+			t.Errorf("expected %d renewals of %s, got %d", expected, id, got)
+		}
+	}
+	// The list, then the extend page and the renewal of 111.
+	if panel.Requests() != 3 {
+		t.Errorf("expected 3 requests, got %d", panel.Requests())
+	}
+	for _, pattern := range []string{
+		`(?m)^111 +default +\S+ \S+ JST +- +renewed .*$`,
+		`(?m)^222 .* skipped +- +not a free plan \(2GBプラン\)$`,
+		`(?m)^333 .* skipped +- +not renewable yet, 1d\d+h left$`,
+		`(?m)^444 .* skipped +- +matched by --exclude$`,
+		`1 renewed, 0 failed, 3 skipped\n$`,
+	} {
+		if !regexp.MustCompile(pattern).MatchString(out.String()) {
+			t.Errorf("expected summary to match %s, got\n%s", pattern, out.String())
+		}
+	}
+}
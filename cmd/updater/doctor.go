@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+	"x-revalidate-bot/pkg/xserver"
+
+	"github.com/spf13/cobra"
+)
+
+var DoctorOffline bool
+
+func init() {
+	doctorCmd.Flags().BoolVar(&DoctorOffline, "offline", false, "Skip the checks that talk to the panel")
+	rootCmd.AddCommand(doctorCmd)
+}
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the configuration and the panel session",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if failed := runChecks(cmd.OutOrStdout(), doctorChecks(DoctorOffline)); failed > 0 {
+			return fmt.Errorf("%d check(s) failed", failed)
+		}
+		return nil
+	},
+}
+
+// errSkipped marks a check that could not run because an earlier one failed.
+var errSkipped = errors.New("skipped")
+
+// warning is a check result worth reporting that does not count as a failure.
+type warning struct{ err error }
+
+func (w warning) Error() string { return w.err.Error() }
+
+type check struct {
+	name string
+	run  func() (string, error)
+}
+
+// runChecks runs every check in order, prints one line per check and returns
+// the number of failures.
+func runChecks(w io.Writer, checks []check) int {
+	failed := 0
+	for _, c := range checks {
+		detail, err := c.run()
+		var warn warning
+		switch {
+		case errors.Is(err, errSkipped):
+			fmt.Fprintf(w, "[skip] %s\n", c.name)
+		case errors.As(err, &warn):
+			fmt.Fprintf(w, "[warn] %s: %v\n", c.name, err)
+		case err != nil:
+			failed++
+			fmt.Fprintf(w, "[fail] %s: %v\n", c.name, err)
+		case detail != "":
+			fmt.Fprintf(w, "[ok]   %s: %s\n", c.name, detail)
+		default:
+			fmt.Fprintf(w, "[ok]   %s\n", c.name)
+		}
+	}
+	return failed
+}
+
+func doctorChecks(offline bool) []check {
+	var creds credentials
+	var sessionErr error
+	var accounts []account
+	return []check{
+		{name: ".env file", run: func() (string, error) {
+			if _, err := os.Stat(EnvFile); errors.Is(err, fs.ErrNotExist) {
+				return "not found, using the environment only", nil
+			} else if err != nil {
+				return "", err
+			}
+			return EnvFile, nil
+		}},
+		{name: "config file", run: func() (string, error) {
+			if fileConfigPath == "" {
+				return "none", nil
+			}
+			return fmt.Sprintf("%s, %d extra account(s)", fileConfigPath, len(fileConfig.Accounts)), nil
+		}},
+		{name: "session", run: func() (string, error) {
+			creds, sessionErr = loadSession()
+			if sessionErr != nil {
+				return "", sessionErr
+			}
+			return fmt.Sprintf("X2SESSID %s, XSERVER_DEVICEKEY %s", maskCredential(creds.SessionID), maskCredential(creds.DeviceKey)), nil
+		}},
+		{name: "servers", run: func() (string, error) {
+			// The same accounts a run renews: --vps, VPS_ID or vps_ids for
+			// the default account, and the accounts of the config file.
+			var err error
+			if accounts, err = loadAccounts(false); err != nil {
+				return "", err
+			}
+			var details []string
+			for _, account := range accounts {
+				details = append(details, fmt.Sprintf("%s: %d server(s) %v", account.Name, len(account.VPSIDs), account.VPSIDs))
+			}
+			return strings.Join(details, "; "), nil
+		}},
+		{name: "headers", run: func() (string, error) {
+			headers, err := getHeaders()
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%d header(s)", len(headers)), nil
+		}},
+		{name: "panel schema", run: func() (string, error) {
+			if PanelSchema == "" {
+				return "embedded", nil
+			}
+			if _, err := xserver.LoadPanelSchemaFile(PanelSchema); err != nil {
+				return "", err
+			}
+			return PanelSchema, nil
+		}},
+		{name: "panel baseline", run: func() (string, error) {
+			if PanelBaseline == "" {
+				return "not configured", nil
+			}
+			baseline, err := xserver.LoadPanelBaseline(PanelBaseline)
+			if errors.Is(err, fs.ErrNotExist) {
+				return "not recorded yet, run check-panel --update-baseline", nil
+			} else if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%d page(s) in %s", len(baseline), PanelBaseline), nil
+		}},
+		{name: "base URL", run: func() (string, error) {
+			u, err := getBaseURL()
+			if err != nil {
+				return "", err
+			}
+			if u == nil {
+				return "real panel", nil
+			}
+			return u.String(), nil
+		}},
+		{name: "panel", run: func() (string, error) {
+			if accounts == nil && sessionErr == nil {
+				// Without servers the session can still be checked.
+				accounts = []account{{Name: defaultAccount, credentials: creds}}
+			}
+			if offline || accounts == nil {
+				return "", errSkipped
+			}
+			pool, err := newPool(accounts)
+			if err != nil {
+				return "", err
+			}
+			var details []string
+			for _, account := range accounts {
+				xs, _ := pool.Client(account.Name)
+				servers, err := xs.ListServers(context.Background())
+				if errors.Is(err, xserver.ErrLoginRequired) {
+					return "", fmt.Errorf("account %s: %w", account.Name, err)
+				} else if err != nil {
+					return "", warning{fmt.Errorf("account %s: could not list the servers, the server list selectors are experimental: %w", account.Name, err)}
+				}
+				// The server list selectors are unverified against the real
+				// panel, so a mismatch is more likely theirs than the config's.
+				listed := make([]xserver.VPSID, len(servers))
+				for i, server := range servers {
+					listed[i] = server.VPSID
+				}
+				for _, id := range account.VPSIDs {
+					if !slices.Contains(listed, id) {
+						return "", warning{fmt.Errorf("account %s: VPS %s is not among the %d server(s) listed %v, the server list selectors are experimental", account.Name, id, len(servers), listed)}
+					}
+				}
+				details = append(details, fmt.Sprintf("%s: session accepted, %d server(s) listed", account.Name, len(servers)))
+			}
+			return strings.Join(details, "; "), nil
+		}},
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func Test_runChecks(t *testing.T) {
+	checks := []check{
+		{name: "plain", run: func() (string, error) { return "", nil }},
+		{name: "detail", run: func() (string, error) { return "3 server(s)", nil }},
+		{name: "broken", run: func() (string, error) { return "", errors.New("not set") }},
+		{name: "unsure", run: func() (string, error) { return "", warning{errors.New("0 server(s) listed")} }},
+		{name: "later", run: func() (string, error) { return "", errSkipped }},
+	}
+
+	var buf bytes.Buffer
+	if failed := runChecks(&buf, checks); failed != 1 {
+		t.Errorf("expected 1 failure, got %d", failed)
+	}
+	expected := "[ok]   plain\n[ok]   detail: 3 server(s)\n[fail] broken: not set\n[warn] unsure: 0 server(s) listed\n[skip] later\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func Test_doctorChecks_Servers(t *testing.T) {
+	tests := []struct {
+		name     string
+		vpsID    string
+		session  bool
+		config   Config
+		contains []string
+	}{
+		{
+			name:     "VPS_ID",
+			vpsID:    "12345",
+			session:  true,
+			contains: []string{"[ok]   servers: default: 1 server(s) [12345]"},
+		},
+		{
+			name:     "Config vps_ids",
+			session:  true,
+			config:   Config{VPSIDs: []string{"12345", "67890"}},
+			contains: []string{"[ok]   servers: default: 2 server(s) [12345 67890]"},
+		},
+		{
+			name:   "Config accounts only",
+			config: Config{Accounts: []AccountConfig{{Name: "second", VPSIDs: []string{"67890"}, SessionID: "s", DeviceKey: "d"}}},
+			contains: []string{
+				"[fail] session: missing X2SESSID or XSERVER_DEVICEKEY",
+				"[ok]   servers: second: 1 server(s) [67890]",
+			},
+		},
+		{
+			name:     "Nothing",
+			session:  true,
+			contains: []string{"[fail] servers: missing servers: no --vps, VPS_ID or vps_ids"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, tt.config)
+			t.Setenv("VPS_ID", tt.vpsID)
+			t.Setenv("X2SESSID", "")
+			t.Setenv("XSERVER_DEVICEKEY", "")
+			if tt.session {
+				t.Setenv("X2SESSID", "session")
+				t.Setenv("XSERVER_DEVICEKEY", "device")
+			}
+
+			var buf bytes.Buffer
+			runChecks(&buf, doctorChecks(true))
+			for _, s := range tt.contains {
+				if !strings.Contains(buf.String(), s+"\n") {
+					t.Errorf("expected %q, got\n%s", s, buf.String())
+				}
+			}
+		})
+	}
+}
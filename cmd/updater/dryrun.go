@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"x-revalidate-bot/pkg/xserver"
+)
+
+var DryRun bool
+
+func init() {
+	extendFlags.BoolVar(&DryRun, "dry-run", false, "Fetch the extend page of every server and print the request that would be posted without renewing")
+}
+
+// errDryRun stops the renewal POST of a dry run before it is sent.
+var errDryRun = errors.New("dry run")
+
+// runDryRun renews the servers of every account the way a run does, but a
+// hook stops the final POST and prints it instead of sending it. The printed
+// request is the one the client built, navigation headers included; only the
+// cookies are added later by the client's cookie jar.
+func runDryRun(ctx context.Context, w io.Writer) error {
+	accounts, err := loadAccounts(false)
+	if err != nil {
+		return err
+	}
+	options, poolAccounts, err := poolConfig(accounts)
+	if err != nil {
+		return err
+	}
+	var posted *http.Request
+	options.Hooks = append(options.Hooks, xserver.Hooks{
+		BeforeRequest: func(ctx context.Context, info xserver.HookInfo, req *http.Request) error {
+			if info.Operation != xserver.OperationExtend {
+				return nil
+			}
+			posted = req
+			return errDryRun
+		},
+	})
+	pool, err := xserver.NewAccountPool(poolAccounts, options)
+	if err != nil {
+		slog.Error("Error creating XServer clients", "error", err)
+		return err
+	}
+
+	for _, account := range accounts {
+		xs, _ := pool.Client(account.Name)
+		for _, vpsID := range account.VPSIDs {
+			form, err := xs.GetRenewalForm(ctx, vpsID)
+			if err != nil {
+				slog.Error("Error getting unique ID", "error", err, "error_code", xserver.ErrorCodeOf(err), "account", account.Name, "vps_id", vpsID)
+				return err
+			}
+			posted = nil
+			err = xs.ExtendFreeVPSExpiration(ctx, vpsID, form.UniqueID)
+			if !errors.Is(err, errDryRun) || posted == nil {
+				return fmt.Errorf("dry run for VPS %s did not stop before the POST: %w", vpsID, err)
+			}
+			if err := printDryRun(w, account, form, posted, time.Now()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// printDryRun prints req, the renewal POST for form, with the credentials and
+// the unique ID masked.
+func printDryRun(w io.Writer, account account, form xserver.RenewalForm, req *http.Request, now time.Time) error {
+	var body []byte
+	if req.GetBody != nil {
+		r, err := req.GetBody()
+		if err != nil {
+			return err
+		}
+		if body, err = io.ReadAll(r); err != nil {
+			return err
+		}
+	}
+	uniqueID := string(form.UniqueID)
+
+	fmt.Fprintf(w, "Dry run for VPS %s of account %s, nothing was submitted\n", form.VPSID, account.Name)
+	fmt.Fprintf(w, "Expiry: %s (%s)\n", formatExpiry(form.Expiry), remaining(form.Expiry, now))
+	fmt.Fprintf(w, "%s %s\n", req.Method, req.URL)
+	for _, key := range slices.Sorted(maps.Keys(req.Header)) {
+		for _, value := range req.Header[key] {
+			// Blank headers are left to the transport.
+			if value != "" {
+				fmt.Fprintf(w, "%s: %s\n", key, value)
+			}
+		}
+	}
+	fmt.Fprintf(w, "Cookie: X2SESSID=%s; XSERVER_DEVICEKEY=%s\n", maskCredential(account.SessionID), maskCredential(account.DeviceKey))
+	fmt.Fprintf(w, "\n%s\n", strings.Replace(string(body), "uniqid="+uniqueID, "uniqid="+maskCredential(uniqueID), 1))
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+	"x-revalidate-bot/pkg/xserver"
+	"x-revalidate-bot/pkg/xserver/xservertest"
+)
+
+func Test_printDryRun(t *testing.T) {
+	now := time.Date(2025, 7, 10, 12, 0, 0, 0, time.UTC)
+	acct := account{Name: "default", credentials: credentials{SessionID: "session-id", DeviceKey: "device-key"}}
+	form := xserver.RenewalForm{VPSID: "12345", UniqueID: "0123456789abcdef", Expiry: now.Add(3 * time.Hour)}
+	req := httptest.NewRequest(http.MethodPost, "https://secure.xserver.ne.jp/xapanel/xvps/server/freevps/extend/do", strings.NewReader(xserver.ExtendForm(form.VPSID, form.UniqueID)))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Origin", "https://secure.xserver.ne.jp")
+	req.Header.Set("Accept-Encoding", "")
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(xserver.ExtendForm(form.VPSID, form.UniqueID))), nil
+	}
+
+	var buf bytes.Buffer
+	if err := printDryRun(&buf, acct, form, req, now); err != nil {
+		t.Fatal(err)
+	}
+	expected := "Dry run for VPS 12345 of account default, nothing was submitted\n" +
+		"Expiry: 2025-07-10 15:00 UTC (3h0m)\n" +
+		"POST https://secure.xserver.ne.jp/xapanel/xvps/server/freevps/extend/do\n" +
+		"Content-Type: application/x-www-form-urlencoded\n" +
+		"Origin: https://secure.xserver.ne.jp\n" +
+		"Cookie: X2SESSID=se****id; XSERVER_DEVICEKEY=de****ey\n" +
+		"\n" +
+		"uniqid=01****ef&ethna_csrf=&id_vps=12345\n"
+	if buf.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, buf.String())
+	}
+}
+
+func Test_runDryRun_FakePanel(t *testing.T) {
+	tests := []struct {
+		name     string
+		navigate bool
+		// requests is the number of requests per server.
+		requests int
+		headers  []string
+	}{
+		{name: "Direct", requests: 1, headers: []string{"Content-Type: application/x-www-form-urlencoded"}},
+		{name: "Navigate", navigate: true, requests: 3, headers: []string{"Referer: SRV/xapanel/xvps/server/freevps/extend/index?vpsid=12345", "Origin: SRV", "Sec-Fetch-Site: same-origin"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withoutPacing(t)
+			defer func(navigate bool) { Navigate = navigate }(Navigate)
+			Navigate = tt.navigate
+
+			panel := xservertest.NewPanel(xservertest.PanelOptions{
+				SessionID: "fake-session",
+				DeviceKey: "fake-devicekey",
+			})
+			panel.AddServer("12345", time.Now().Add(6*time.Hour))
+			panel.AddServer("67890", time.Now().Add(6*time.Hour))
+			srv := httptest.NewServer(panel)
+			defer srv.Close()
+
+			t.Setenv("VPS_ID", "12345")
+			t.Setenv("X2SESSID", "fake-session")
+			t.Setenv("XSERVER_DEVICEKEY", "fake-devicekey")
+			t.Setenv("XSERVER_BASE_URL", srv.URL)
+			withConfig(t, Config{Accounts: []AccountConfig{
+				{Name: "second", VPSIDs: []string{"67890"}, SessionID: "fake-session", DeviceKey: "fake-devicekey"},
+			}})
+
+			var buf bytes.Buffer
+			if err := runDryRun(context.Background(), &buf); err != nil {
+				t.Fatalf("runDryRun failed: %v", err)
+			}
+			out := buf.String()
+			for _, vpsID := range []xserver.VPSID{"12345", "67890"} {
+				if panel.Renewals(vpsID) != 0 {
+					t.Errorf("expected no renewal of %s, got %d", vpsID, panel.Renewals(vpsID))
+				}
+			}
+			if panel.Requests() != 2*tt.requests {
+				t.Errorf("expected %d requests, got %d", 2*tt.requests, panel.Requests())
+			}
+			if !strings.Contains(out, "Dry run for VPS 12345 of account default") || !strings.Contains(out, "Dry run for VPS 67890 of account second") {
+				t.Errorf("expected both accounts, got\n%s", out)
+			}
+			if strings.Contains(out, "Expiry: -") {
+				t.Errorf("expected the expiry from the extend page, got\n%s", out)
+			}
+			if !regexp.MustCompile(`(?m)^POST ` + regexp.QuoteMeta(srv.URL) + `/xapanel/xvps/server/freevps/extend/do$`).MatchString(out) {
+				t.Errorf("expected the POST target, got\n%s", out)
+			}
+			for _, header := range tt.headers {
+				if header = strings.ReplaceAll(header, "SRV", srv.URL); !strings.Contains(out, "\n"+header+"\n") {
+					t.Errorf("expected %q, got\n%s", header, out)
+				}
+			}
+			if !regexp.MustCompile(`(?m)^uniqid=\w\w\*\*\*\*\w\w&ethna_csrf=&id_vps=12345$`).MatchString(out) {
+				t.Errorf("expected a masked form, got\n%s", out)
+			}
+		})
+	}
+}
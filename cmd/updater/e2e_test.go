@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"x-revalidate-bot/pkg/xserver/xservertest"
+
+	"github.com/spf13/cobra"
+)
+
+// withoutPacing turns pacing off for the duration of a test.
+func withoutPacing(t *testing.T) {
+	t.Helper()
+	minGap, thinkMin, thinkMax, rate := MinGap, ThinkTimeMin, ThinkTimeMax, RequestRate
+	t.Cleanup(func() { MinGap, ThinkTimeMin, ThinkTimeMax, RequestRate = minGap, thinkMin, thinkMax, rate })
+	MinGap, ThinkTimeMin, ThinkTimeMax, RequestRate = 0, 0, 0, 0
+}
+
+func Test_runInternally_FakePanel(t *testing.T) {
+	tests := []struct {
+		name     string
+		navigate bool
+		requests int
+	}{
+		{name: "Direct", navigate: false, requests: 2},
+		{name: "Navigate", navigate: true, requests: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withoutPacing(t)
+			defer func(navigate bool) { Navigate = navigate }(Navigate)
+			Navigate = tt.navigate
+
+			panel := xservertest.NewPanel(xservertest.PanelOptions{
+				SessionID: "fake-session",
+				DeviceKey: "fake-devicekey",
+			})
+			panel.AddServer("12345", time.Now().Add(6*time.Hour))
+			srv := httptest.NewServer(panel)
+			defer srv.Close()
+
+			t.Setenv("VPS_ID", "12345")
+			t.Setenv("X2SESSID", "fake-session")
+			t.Setenv("XSERVER_DEVICEKEY", "fake-devicekey")
+			t.Setenv("XSERVER_BASE_URL", srv.URL)
+
+			if err := runInternally(context.Background(), io.Discard); err != nil {
+				t.Fatalf("runInternally failed: %v", err)
+			}
+			if panel.Renewals("12345") != 1 {
+				t.Errorf("expected one renewal, got %d", panel.Renewals("12345"))
+			}
+			if panel.Requests() != tt.requests {
+				t.Errorf("expected %d requests, got %d", tt.requests, panel.Requests())
+			}
+		})
+	}
+}
+
+func Test_getBaseURL(t *testing.T) {
+	tests := []struct {
+		env     string
+		want    string
+		wantErr bool
+	}{
+		{env: "", want: ""},
+		{env: "http://localhost:8081", want: "http://localhost:8081"},
+		{env: "localhost:8081", wantErr: true},
+		{env: "ftp://localhost", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.env, func(t *testing.T) {
+			t.Setenv("XSERVER_BASE_URL", tt.env)
+			u, err := getBaseURL()
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := ""
+			if u != nil {
+				got = u.String()
+			}
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_Subcommands_FakePanel(t *testing.T) {
+	withoutPacing(t)
+	panel := xservertest.NewPanel(xservertest.PanelOptions{
+		SessionID: "fake-session",
+		DeviceKey: "fake-devicekey",
+	})
+	panel.AddServer("12345", time.Now().Add(30*time.Hour))
+	panel.AddServer("67890", time.Now().Add(30*time.Hour))
+	panel.SetPlan("67890", xservertest.PlanPaid)
+	srv := httptest.NewServer(panel)
+	defer srv.Close()
+
+	t.Setenv("VPS_ID", "12345")
+	t.Setenv("X2SESSID", "fake-session")
+	t.Setenv("XSERVER_DEVICEKEY", "fake-devicekey")
+	t.Setenv("XSERVER_BASE_URL", srv.URL)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	tests := []struct {
+		args     []string
+		contains []string
+		excludes []string
+	}{
+		{args: []string{"status"}, contains: []string{"12345", "1d5h"}},
+		{args: []string{"list"}, contains: []string{"vps-12345", "vps-67890"}},
+		{args: []string{"list", "--free-only"}, contains: []string{"vps-12345"}, excludes: []string{"vps-67890"}},
+		{args: []string{"doctor"}, contains: []string{"[ok]   servers: default: 1 server(s) [12345]", "[ok]   panel: default: session accepted, 2 server(s) listed"}, excludes: []string{"[fail]"}},
+		{args: []string{"config", "show"}, contains: []string{"# No config file", "session_id: fa****on", "- \"12345\""}, excludes: []string{"fake-session"}},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			var out bytes.Buffer
+			rootCmd.SetOut(&out)
+			rootCmd.SetArgs(tt.args)
+			t.Cleanup(func() {
+				rootCmd.SetOut(nil)
+				rootCmd.SetArgs(nil)
+				FreeOnly = false
+			})
+
+			if err := rootCmd.Execute(); err != nil {
+				t.Fatalf("%v failed: %v\n%s", tt.args, err, out.String())
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(out.String(), s) {
+					t.Errorf("expected output to contain %q, got\n%s", s, out.String())
+				}
+			}
+			for _, s := range tt.excludes {
+				if strings.Contains(out.String(), s) {
+					t.Errorf("expected output not to contain %q, got\n%s", s, out.String())
+				}
+			}
+		})
+	}
+	if panel.Renewals("12345") != 0 {
+		t.Errorf("expected read-only commands not to renew, got %d renewals", panel.Renewals("12345"))
+	}
+}
+
+func Test_ExtendFlags_RootAlias(t *testing.T) {
+	for _, cmd := range []*cobra.Command{rootCmd, extendCmd} {
+		for _, name := range []string{"concurrency", "retries", "retry-wait", "max-retry-wait"} {
+			if cmd.Flags().Lookup(name) == nil {
+				t.Errorf("expected %s to have --%s", cmd.Name(), name)
+			}
+		}
+	}
+	if statusCmd.Flags().Lookup("retries") != nil || statusCmd.InheritedFlags().Lookup("retries") != nil {
+		t.Error("expected --retries to be limited to extend")
+	}
+}
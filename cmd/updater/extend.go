@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+	"x-revalidate-bot/pkg/xserver"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var VPSFlags []string
+
+// extendFlags are shared by extend and the bare root command, its alias.
+var extendFlags = pflag.NewFlagSet("extend", pflag.ContinueOnError)
+
+func init() {
+	extendFlags.StringArrayVar(&VPSFlags, "vps", nil, "VPS ID or extend page URL to renew instead of VPS_ID, repeatable")
+	extendFlags.IntVar(&Concurrency, "concurrency", 1, "Number of servers renewed at once")
+	extendFlags.BoolVar(&ReadNewExpiry, "read-new-expiry", false, "Read the expiry again after renewing to show it in the summary, one more page load per server")
+	extendFlags.IntVar(&Retries, "retries", 3, "Number of retries when the panel is under maintenance or serves a challenge")
+	extendFlags.DurationVar(&RetryWait, "retry-wait", time.Minute, "Initial wait before retrying, doubled on every attempt")
+	extendFlags.DurationVar(&MaxRetryWait, "max-retry-wait", 30*time.Minute, "Upper bound of a single wait between retries")
+
+	rootCmd.Flags().AddFlagSet(extendFlags)
+	extendCmd.Flags().AddFlagSet(extendFlags)
+	rootCmd.AddCommand(extendCmd)
+}
+
+var extendCmd = &cobra.Command{
+	Use:   "extend",
+	Short: "Renew the free VPS servers in VPS_ID or --vps",
+	Args:  cobra.NoArgs,
+	Run:   runExtend,
+}
+
+func runExtend(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	shutdown, err := setupTracing(ctx)
+	if err != nil {
+		slog.Error("Error setting up tracing", "error", err)
+		os.Exit(1)
+	}
+	if DryRun && ExtendAll {
+		err = errors.New("--dry-run cannot be combined with --all")
+	} else if DryRun {
+		err = runDryRun(ctx, cmd.OutOrStdout())
+	} else {
+		err = runInternally(ctx, cmd.OutOrStdout())
+		notify(ctx, fileConfig.Notifications, err)
+	}
+	if err := shutdown(ctx); err != nil {
+		slog.Warn("Error flushing traces", "error", err)
+	}
+	if err != nil {
+		os.Exit(exitCode(err))
+	}
+}
+
+func runInternally(ctx context.Context, w io.Writer) (err error) {
+	ctx, span := tracer().Start(ctx, "updater.run")
+	defer func() { xserver.EndSpan(span, err) }()
+
+	if ExtendAll && len(VPSFlags) > 0 {
+		return errors.New("--all and --vps cannot be combined")
+	}
+	filter, err := newServerFilter(Include, Exclude, RenewalWindow)
+	if err != nil {
+		return err
+	}
+	accounts, err := loadAccounts(ExtendAll)
+	if err != nil {
+		return err
+	}
+	if len(accounts) == 1 && len(accounts[0].VPSIDs) == 1 {
+		span.SetAttributes(xserver.AttributeVPSID.String(accounts[0].VPSIDs[0].String()))
+	}
+	pool, err := newPool(accounts)
+	if err != nil {
+		return err
+	}
+
+	items := batchItems(accounts)
+	var listed map[xserver.BatchItem]time.Time
+	var others []renewal
+	if ExtendAll {
+		items, listed, others = discover(ctx, pool, filter, time.Now())
+	}
+	renewals := append(runBatch(ctx, pool, items, listed), others...)
+	printSummary(w, renewals)
+	return runError(renewals)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	"x-revalidate-bot/pkg/xserver"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	KeepaliveInterval time.Duration
+	KeepaliveOnce     bool
+)
+
+func init() {
+	keepaliveCmd.Flags().DurationVar(&KeepaliveInterval, "interval", 30*time.Minute, "Time between two visits of the panel top page")
+	keepaliveCmd.Flags().BoolVar(&KeepaliveOnce, "once", false, "Visit the panel once and exit, for running from cron")
+	rootCmd.AddCommand(keepaliveCmd)
+}
+
+var keepaliveCmd = &cobra.Command{
+	Use:   "keepalive",
+	Short: "Keep the panel session alive by visiting the panel top page periodically (experimental)",
+	Long:  "Keep the panel session alive by visiting the panel top page periodically." + experimentalNote,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if KeepaliveInterval <= 0 {
+			return errors.New("--interval must be positive")
+		}
+		creds, err := loadSession()
+		if err != nil {
+			return err
+		}
+		xs, err := newClient(creds)
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return keepalive(ctx, xs, KeepaliveInterval, KeepaliveOnce)
+	},
+}
+
+// keepalive lists the servers every interval until ctx is done. It stops early
+// when the session has expired, as only a new login can fix that.
+func keepalive(ctx context.Context, xs xserver.Client, interval time.Duration, once bool) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		servers, err := xs.ListServers(ctx)
+		switch {
+		case errors.Is(err, xserver.ErrLoginRequired):
+			slog.Error("Session expired", "error", err)
+			return err
+		case err != nil && once:
+			slog.Error("Error visiting the panel", "error", err, "error_code", xserver.ErrorCodeOf(err))
+			return err
+		case err != nil:
+			slog.Warn("Error visiting the panel, trying again later", "error", err, "error_code", xserver.ErrorCodeOf(err))
+		default:
+			slog.Info("Session is alive", "servers", len(servers))
+		}
+		if once {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			slog.Info("Stopping keepalive")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
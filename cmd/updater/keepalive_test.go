@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"x-revalidate-bot/pkg/xserver"
+	"x-revalidate-bot/pkg/xserver/xservertest"
+)
+
+func Test_keepalive(t *testing.T) {
+	t.Run("Once", func(t *testing.T) {
+		fake := xservertest.NewFakeClient().WithServer("12345", time.Now().Add(time.Hour))
+		if err := keepalive(context.Background(), fake, time.Hour, true); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		fake.AssertCalls(t, xserver.OperationListServers)
+	})
+
+	t.Run("Keeps going past errors until the session expires", func(t *testing.T) {
+		fake := xservertest.NewFakeClient().
+			WithError(xserver.OperationListServers, xserver.ErrMaintenance).
+			WithError(xserver.OperationListServers, nil).
+			WithError(xserver.OperationListServers, xserver.ErrLoginRequired)
+		err := keepalive(context.Background(), fake, time.Millisecond, false)
+		if !errors.Is(err, xserver.ErrLoginRequired) {
+			t.Fatalf("expected ErrLoginRequired, got %v", err)
+		}
+		fake.AssertCalls(t, xserver.OperationListServers, xserver.OperationListServers, xserver.OperationListServers)
+	})
+
+	t.Run("Stops when cancelled", func(t *testing.T) {
+		fake := xservertest.NewFakeClient()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := keepalive(ctx, fake, time.Hour, false); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"x-revalidate-bot/pkg/xserver"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+)
+
+var (
+	LoginSessionID string
+	LoginDeviceKey string
+	LoginSave      string
+)
+
+func init() {
+	loginCmd.Flags().StringVar(&LoginSessionID, "session", "", "X2SESSID cookie copied from a logged in browser, prompted for when empty (env X2SESSID)")
+	loginCmd.Flags().StringVar(&LoginDeviceKey, "device-key", "", "XSERVER_DEVICEKEY cookie copied from a logged in browser, prompted for when empty (env XSERVER_DEVICEKEY)")
+	loginCmd.Flags().StringVar(&LoginSave, "save", "", "Write the verified cookies to this .env file (mode 0600), keeping its other lines and comments")
+	rootCmd.AddCommand(loginCmd)
+}
+
+// The panel login is protected by a CAPTCHA, so login takes the cookies of a
+// browser session instead of a password and checks that the panel accepts them.
+var loginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Verify panel session cookies and optionally save them to .env",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		creds, err := promptSession(cmd.InOrStdin(), cmd.ErrOrStderr(), credentials{
+			SessionID: cmp.Or(LoginSessionID, os.Getenv("X2SESSID")),
+			DeviceKey: cmp.Or(LoginDeviceKey, os.Getenv("XSERVER_DEVICEKEY")),
+		})
+		if err != nil {
+			return err
+		}
+		xs, err := newClient(creds)
+		if err != nil {
+			return err
+		}
+
+		servers, err := xs.ListServers(context.Background())
+		if err != nil {
+			if errors.Is(err, xserver.ErrLoginRequired) {
+				return fmt.Errorf("the panel rejected the session, copy fresh cookies from the browser: %w", err)
+			}
+			slog.Error("Error verifying session", "error", err, "error_code", xserver.ErrorCodeOf(err))
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Session %s is valid, %d server(s) visible\n", maskCredential(creds.SessionID), len(servers))
+
+		if LoginSave != "" {
+			if err := saveSession(LoginSave, creds); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Session written to %s\n", LoginSave)
+		}
+		return nil
+	},
+}
+
+// promptSession asks for every cookie missing from creds on in.
+func promptSession(in io.Reader, out io.Writer, creds credentials) (credentials, error) {
+	reader := bufio.NewReader(in)
+	for _, field := range []struct {
+		name  string
+		value *string
+	}{
+		{name: "X2SESSID", value: &creds.SessionID},
+		{name: "XSERVER_DEVICEKEY", value: &creds.DeviceKey},
+	} {
+		if *field.value != "" {
+			continue
+		}
+		fmt.Fprintf(out, "%s: ", field.name)
+		line, err := reader.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return creds, err
+		}
+		*field.value = strings.TrimSpace(line)
+		if *field.value == "" {
+			return creds, fmt.Errorf("%s is required", field.name)
+		}
+	}
+	return creds, nil
+}
+
+// saveSession stores the cookies of creds in the .env file at path. Only the
+// X2SESSID and XSERVER_DEVICEKEY lines change; comments and other lines are
+// kept, and missing keys are appended.
+func saveSession(path string, creds credentials) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("error reading %s: %w", path, err)
+	}
+	// Replace the file a symlink points to rather than the symlink.
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+
+	values := []struct{ key, value string }{
+		{"X2SESSID", creds.SessionID},
+		{"XSERVER_DEVICEKEY", creds.DeviceKey},
+	}
+	var lines []string
+	if len(data) > 0 {
+		lines = strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	}
+	for _, v := range values {
+		line, err := godotenv.Marshal(map[string]string{v.key: v.value})
+		if err != nil {
+			return err
+		}
+		found := false
+		for i := range lines {
+			if envKey(lines[i]) == v.key {
+				lines[i], found = line, true
+			}
+		}
+		if !found {
+			lines = append(lines, line)
+		}
+	}
+
+	// The cookies are as good as a password, so the file is never readable
+	// by others, not even while it is written: CreateTemp makes it 0600.
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(strings.Join(lines, "\n") + "\n"); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// envKey returns the key a .env line assigns, or "" for comments and blank lines.
+func envKey(line string) string {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ""
+	}
+	line = strings.TrimPrefix(line, "export ")
+	key, _, ok := strings.Cut(line, "=")
+	if !ok {
+		key, _, ok = strings.Cut(line, ":")
+	}
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(key)
+}
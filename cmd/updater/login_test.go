@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/joho/godotenv"
+)
+
+func Test_promptSession(t *testing.T) {
+	tests := []struct {
+		name    string
+		given   credentials
+		input   string
+		want    credentials
+		wantErr bool
+	}{
+		{name: "Both given", given: credentials{SessionID: "s", DeviceKey: "d"}, want: credentials{SessionID: "s", DeviceKey: "d"}},
+		{name: "Both prompted", input: " s \nd", want: credentials{SessionID: "s", DeviceKey: "d"}},
+		{name: "Device key prompted", given: credentials{SessionID: "s"}, input: "d\n", want: credentials{SessionID: "s", DeviceKey: "d"}},
+		{name: "Empty answer", input: "\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var prompts strings.Builder
+			got, err := promptSession(strings.NewReader(tt.input), &prompts, tt.given)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.SessionID != tt.want.SessionID || got.DeviceKey != tt.want.DeviceKey {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_saveSession(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string // empty for no file
+		want    string
+	}{
+		{
+			name: "New file",
+			want: "X2SESSID=\"new\"\nXSERVER_DEVICEKEY=\"device\"\n",
+		},
+		{
+			name:    "Keeps comments and other lines",
+			content: "# panel login\nVPS_ID=12345\n\nexport X2SESSID=old # expires\nOTHER='x'\n",
+			want:    "# panel login\nVPS_ID=12345\n\nX2SESSID=\"new\"\nOTHER='x'\nXSERVER_DEVICEKEY=\"device\"\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ".env")
+			if tt.content != "" {
+				if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := saveSession(path, credentials{SessionID: "new", DeviceKey: "device"}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("expected\n%s\ngot\n%s", tt.want, data)
+			}
+			if env, err := godotenv.Read(path); err != nil || env["X2SESSID"] != "new" || env["XSERVER_DEVICEKEY"] != "device" {
+				t.Errorf("unexpected .env contents %v (%v)", env, err)
+			}
+			if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+				t.Errorf("expected mode 0600, got %v (%v)", info.Mode().Perm(), err)
+			}
+			if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+				t.Errorf("expected only the .env file to be left, got %v", entries)
+			}
+		})
+	}
+}
@@ -1,40 +1,57 @@
 package main
 
 import (
-	"context"
+	"cmp"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log/slog"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"x-revalidate-bot/pkg/xserver"
 
-	"github.com/joho/godotenv"
 	"github.com/spf13/cobra"
 )
 
-var Verbose bool
+var (
+	Verbose       bool
+	PanelSchema   string
+	PanelBaseline string
+	BaseURL       string
+	Chaos         string
+	TLSProfile    string
+	Navigate      bool
+)
 
 func init() {
 	rootCmd.PersistentFlags().BoolVarP(&Verbose, "verbose", "v", false, "Enable verbose logging")
+	rootCmd.PersistentFlags().StringVar(&PanelSchema, "panel-schema", "", "Path to a YAML panel schema overriding the embedded selectors and phrases")
+	rootCmd.PersistentFlags().StringVar(&PanelBaseline, "panel-baseline", "", "Path to a JSON panel structure baseline used for drift warnings")
+	rootCmd.PersistentFlags().StringVar(&BaseURL, "base-url", "", "Panel origin to talk to instead of the real panel, such as a fake panel (env XSERVER_BASE_URL)")
+	_ = rootCmd.PersistentFlags().MarkHidden("base-url")
+	rootCmd.PersistentFlags().BoolVar(&Navigate, "navigate", false, "Reach the extend page through the panel top page and server page like a person would")
+	rootCmd.PersistentFlags().StringVar(&TLSProfile, "tls-fingerprint", tlsProfileGo, "TLS and HTTP/2 fingerprint to present: \"go\" or \"chrome\" to match the Chrome header profile, without proxy support")
+	rootCmd.PersistentFlags().StringVar(&Chaos, "chaos", "", "Inject faults into panel requests for soak testing, such as \"latency=0.2,reset=0.05,5xx=0.1,seed=42\"")
+	_ = rootCmd.PersistentFlags().MarkHidden("chaos")
+	rootCmd.PersistentFlags().StringVar(&PageDumpDir, "page-dump-dir", "", "Directory to save maintenance and challenge pages to")
 }
 
 func main() {
-	if err := godotenv.Load(); err != nil {
-		panic("Error loading .env file")
-	}
-
 	if err := rootCmd.Execute(); err != nil {
 		slog.Error("Error executing command", "error", err)
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
 
 var rootCmd = &cobra.Command{
 	Use:   "updater",
 	Short: "Updater for XServer Free VPS Expiration",
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+	Long:  "Updater for XServer Free VPS Expiration. Without a subcommand it runs extend.",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 		level := slog.LevelInfo
 		if Verbose {
 			level = slog.LevelDebug
@@ -42,19 +59,21 @@ var rootCmd = &cobra.Command{
 		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
 			Level: level,
 		})))
+		return loadConfig(cmd)
 	},
-	Run: func(cmd *cobra.Command, args []string) {
-		if err := godotenv.Load(); err != nil {
-			slog.Error("Error loading .env file", "error", err)
-			os.Exit(1)
-		}
-
-		if err := runInternally(); err != nil {
-			os.Exit(1)
-		}
-	},
+	Args: cobra.NoArgs,
+	Run:  runExtend,
 }
 
+// experimentalNote is appended to the help of the commands that read expiries
+// or the server list from the panel.
+const experimentalNote = `
+
+Experimental: the selectors reading expiries and the server list (expiry,
+server_row, server_plan and free_plan_markers in the panel schema) have not
+been checked against pages of the real panel yet. If they do not match, pass
+corrected ones with --panel-schema.`
+
 type UserAgentHeaders struct {
 	Chrome map[string]string `json:"chrome"`
 }
@@ -69,10 +88,17 @@ func parseHeaderFile(reader io.Reader) (map[string]string, error) {
 }
 
 func getHeaders() (map[string]string, error) {
-	headerPath := filepath.Join("assets", "headers", "chrome-macos.json")
+	return loadHeaders(fileConfig.HeaderProfile)
+}
 
-	if _, err := os.Stat(headerPath); os.IsNotExist(err) {
-		headerPath = filepath.Join("..", "..", "assets", "headers", "chrome-macos.json")
+// loadHeaders reads the header profile at headerPath, or the bundled Chrome
+// profile when it is empty.
+func loadHeaders(headerPath string) (map[string]string, error) {
+	if headerPath == "" {
+		headerPath = filepath.Join("assets", "headers", "chrome-macos.json")
+		if _, err := os.Stat(headerPath); os.IsNotExist(err) {
+			headerPath = filepath.Join("..", "..", "assets", "headers", "chrome-macos.json")
+		}
 	}
 
 	file, err := os.Open(headerPath)
@@ -94,46 +120,151 @@ func getHeaders() (map[string]string, error) {
 	return headers, nil
 }
 
-func runInternally() error {
-	vpsID := os.Getenv("VPS_ID")
-	x2sessid := os.Getenv("X2SESSID")
-	deviceKey := os.Getenv("XSERVER_DEVICEKEY")
-	if vpsID == "" || x2sessid == "" || deviceKey == "" {
-		slog.Error("VPS_ID, X2SESSID, and XSERVER_DEVICEKEY environment variables are required")
-		return fmt.Errorf("missing required environment variables")
+func getBaseURL() (*url.URL, error) {
+	raw := cmp.Or(BaseURL, os.Getenv("XSERVER_BASE_URL"), fileConfig.BaseURL)
+	if raw == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("base URL must be an absolute http(s) URL: %q", raw)
+	}
+	return u, nil
+}
+
+type credentials struct {
+	VPSIDs    []xserver.VPSID
+	SessionID string
+	DeviceKey string
+}
+
+// loadSession reads the panel session from the environment or the config file.
+func loadSession() (credentials, error) {
+	creds := credentials{
+		SessionID: cmp.Or(os.Getenv("X2SESSID"), fileConfig.SessionID),
+		DeviceKey: cmp.Or(os.Getenv("XSERVER_DEVICEKEY"), fileConfig.DeviceKey),
+	}
+	if creds.SessionID == "" || creds.DeviceKey == "" {
+		slog.Error("The panel session is required: set X2SESSID and XSERVER_DEVICEKEY in the environment or .env, or session_id and device_key in the config file")
+		return creds, errors.New("missing X2SESSID or XSERVER_DEVICEKEY")
+	}
+	slog.Debug("Credentials loaded", "x2sessid", maskCredential(creds.SessionID), "device_key", maskCredential(creds.DeviceKey))
+	return creds, nil
+}
+
+// rawVPSIDs returns the servers given with --vps, VPS_ID or the config file,
+// in that order of precedence, as a comma separated list.
+func rawVPSIDs() string {
+	return cmp.Or(strings.Join(VPSFlags, ","), os.Getenv("VPS_ID"), strings.Join(fileConfig.VPSIDs, ","))
+}
+
+// loadCredentials reads the panel session and the servers to work on.
+func loadCredentials() (credentials, error) {
+	rawVPSID := rawVPSIDs()
+	if rawVPSID == "" {
+		slog.Error("The servers to renew are required: pass --vps, set VPS_ID in the environment or .env, or vps_ids in the config file")
+		return credentials{}, errors.New("missing servers: no --vps, VPS_ID or vps_ids")
+	}
+	creds, err := loadSession()
+	if err != nil {
+		return creds, err
+	}
+	creds.VPSIDs, err = parseVPSIDs(rawVPSID)
+	if err != nil {
+		slog.Error("Invalid VPS_ID", "error", err)
+		return creds, fmt.Errorf("VPS_ID: %w", err)
+	}
+	return creds, nil
+}
+
+// parseVPSIDs parses a comma separated list of IDs or extend page URLs.
+func parseVPSIDs(raw string) ([]xserver.VPSID, error) {
+	var ids []xserver.VPSID
+	for _, part := range strings.Split(raw, ",") {
+		vpsID, err := xserver.ParseVPSID(part)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, vpsID)
 	}
-	slog.Info("Starting VPS renewal process", "vps_id", vpsID)
-	slog.Debug("Credentials loaded", "x2sessid", maskCredential(x2sessid), "device_key", maskCredential(deviceKey))
+	return ids, nil
+}
 
+// clientOptions builds the client options shared by every client of a run.
+func clientOptions(creds credentials) (xserver.ClientOptions, error) {
 	headers, err := getHeaders()
 	if err != nil {
 		slog.Error("Error getting headers", "error", err)
-		return err
+		return xserver.ClientOptions{}, err
+	}
+
+	var schema *xserver.PanelSchema
+	if PanelSchema != "" {
+		schema, err = xserver.LoadPanelSchemaFile(PanelSchema)
+		if err != nil {
+			slog.Error("Error loading panel schema", "error", err, "path", PanelSchema)
+			return xserver.ClientOptions{}, err
+		}
+	}
+
+	var baseline xserver.PanelBaseline
+	if PanelBaseline != "" {
+		baseline, err = xserver.LoadPanelBaseline(PanelBaseline)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			slog.Error("Error loading panel baseline", "error", err, "path", PanelBaseline)
+			return xserver.ClientOptions{}, err
+		}
 	}
 
-	xs, err := xserver.NewClient(xserver.ClientOptions{
-		SessionID: x2sessid,
-		DeviceKey: deviceKey,
-		Headers:   headers,
-		Logger:    slog.Default(),
-	})
+	baseURL, err := getBaseURL()
 	if err != nil {
-		slog.Error("Error creating XServer client", "error", err)
-		return err
+		slog.Error("Error parsing base URL", "error", err)
+		return xserver.ClientOptions{}, err
+	}
+
+	transport, err := newTransport(baseURL)
+	if err != nil {
+		return xserver.ClientOptions{}, err
 	}
-	ctx := context.Background()
 
-	uniqueID, err := xs.GetCSRFTokenAsUniqueID(ctx, xserver.VPSID(vpsID))
+	pace, err := pacing()
 	if err != nil {
-		slog.Error("Error getting unique ID", "error", err, "vps_id", vpsID)
-		return err
+		slog.Error("Invalid pacing", "error", err)
+		return xserver.ClientOptions{}, err
 	}
-	slog.Info("Unique ID retrieved", "unique_id", uniqueID)
 
-	if err := xs.ExtendFreeVPSExpiration(ctx, xserver.VPSID(vpsID), uniqueID); err != nil {
-		slog.Error("Error extending free VPS", "error", err, "vps_id", vpsID, "unique_id", uniqueID)
-		return err
+	var navigation *xserver.Navigation
+	if Navigate {
+		navigation = &xserver.Navigation{}
 	}
 
-	return nil
+	return xserver.ClientOptions{
+		SessionID:     creds.SessionID,
+		DeviceKey:     creds.DeviceKey,
+		Headers:       headers,
+		Logger:        slog.Default(),
+		PanelSchema:   schema,
+		PanelBaseline: baseline,
+		PageDumpDir:   PageDumpDir,
+		BaseURL:       baseURL,
+		Transport:     transport,
+		Pacing:        pace,
+		Navigation:    navigation,
+	}, nil
+}
+
+func newClient(creds credentials) (xserver.Client, error) {
+	options, err := clientOptions(creds)
+	if err != nil {
+		return nil, err
+	}
+	xs, err := xserver.NewClient(options)
+	if err != nil {
+		slog.Error("Error creating XServer client", "error", err)
+		return nil, err
+	}
+	return xs, nil
 }
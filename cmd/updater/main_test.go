@@ -1,8 +1,11 @@
 package main
 
 import (
+	"errors"
+	"slices"
 	"strings"
 	"testing"
+	"x-revalidate-bot/pkg/xserver"
 )
 
 func Test_parseHeaderFile(t *testing.T) {
@@ -37,3 +40,54 @@ func Test_getHeaders(t *testing.T) {
 		t.Error("Expected User-Agent header to be present")
 	}
 }
+
+func Test_loadCredentials(t *testing.T) {
+	tests := []struct {
+		env     string
+		want    []xserver.VPSID
+		wantErr error
+	}{
+		{env: "12345", want: []xserver.VPSID{"12345"}},
+		{env: "https://secure.xserver.ne.jp/xapanel/xvps/server/freevps/extend/index?vpsid=12345", want: []xserver.VPSID{"12345"}},
+		{env: "12345, 67890", want: []xserver.VPSID{"12345", "67890"}},
+		{env: "12345x", wantErr: xserver.ErrInvalidVPSID},
+		{env: "12345,", wantErr: xserver.ErrInvalidVPSID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.env, func(t *testing.T) {
+			t.Setenv("VPS_ID", tt.env)
+			t.Setenv("X2SESSID", "session")
+			t.Setenv("XSERVER_DEVICEKEY", "device")
+
+			creds, err := loadCredentials()
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(creds.VPSIDs, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, creds.VPSIDs)
+			}
+		})
+	}
+}
+
+func Test_loadCredentials_VPSFlags(t *testing.T) {
+	defer func(flags []string) { VPSFlags = flags }(VPSFlags)
+	VPSFlags = []string{"111", "222,333"}
+	t.Setenv("VPS_ID", "999")
+	t.Setenv("X2SESSID", "session")
+	t.Setenv("XSERVER_DEVICEKEY", "device")
+
+	creds, err := loadCredentials()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []xserver.VPSID{"111", "222", "333"}; !slices.Equal(creds.VPSIDs, want) {
+		t.Errorf("expected --vps to win over VPS_ID with %v, got %v", want, creds.VPSIDs)
+	}
+}
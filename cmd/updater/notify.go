@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// notify reports the outcome of a renewal run to the configured webhook.
+// Delivery problems are logged and never fail the run.
+func notify(ctx context.Context, config NotificationConfig, runErr error) {
+	if config.WebhookURL == "" || runErr == nil && !config.OnSuccess {
+		return
+	}
+	text := "VPS renewal succeeded"
+	if runErr != nil {
+		text = fmt.Sprintf("VPS renewal failed: %v", runErr)
+	}
+	if err := postWebhook(ctx, config.WebhookURL, text); err != nil {
+		slog.Warn("Error sending notification", "error", err)
+	}
+}
+
+func postWebhook(ctx context.Context, webhookURL, text string) error {
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
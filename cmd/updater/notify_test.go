@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_notify(t *testing.T) {
+	tests := []struct {
+		name      string
+		onSuccess bool
+		err       error
+		expected  string
+	}{
+		{name: "Failure", err: errors.New("boom"), expected: "VPS renewal failed: boom"},
+		{name: "Success not reported", expected: ""},
+		{name: "Success reported", onSuccess: true, expected: "VPS renewal succeeded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body map[string]string
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					t.Error(err)
+				}
+				got = body["text"]
+			}))
+			defer srv.Close()
+
+			notify(context.Background(), NotificationConfig{WebhookURL: srv.URL, OnSuccess: tt.onSuccess}, tt.err)
+			if got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
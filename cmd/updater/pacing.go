@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"time"
+	"x-revalidate-bot/pkg/xserver"
+)
+
+var (
+	MinGap       time.Duration
+	ThinkTimeMin time.Duration
+	ThinkTimeMax time.Duration
+	RequestRate  float64
+	RequestBurst int
+)
+
+func init() {
+	rootCmd.PersistentFlags().DurationVar(&MinGap, "min-gap", 0, "Minimum time between two page loads of one client, such as 2s")
+	rootCmd.PersistentFlags().DurationVar(&ThinkTimeMin, "think-time-min", 0, "Lower bound of the random pause between page loads, such as 3s")
+	rootCmd.PersistentFlags().DurationVar(&ThinkTimeMax, "think-time-max", 0, "Upper bound of the random pause between page loads, such as 8s")
+	rootCmd.PersistentFlags().Float64Var(&RequestRate, "rate", 0, "Maximum requests per second to the panel across all clients and servers, 0 for no limit")
+	rootCmd.PersistentFlags().IntVar(&RequestBurst, "burst", 1, "Number of requests allowed at once under --rate")
+}
+
+// pacing returns the pacing for the clients of a run, or nil when every
+// setting is zero, as it is by default.
+func pacing() (*xserver.Pacing, error) {
+	if ThinkTimeMax < ThinkTimeMin {
+		return nil, fmt.Errorf("--think-time-max (%v) must not be less than --think-time-min (%v)", ThinkTimeMax, ThinkTimeMin)
+	}
+	if MinGap < 0 || ThinkTimeMin < 0 || RequestRate < 0 {
+		return nil, fmt.Errorf("pacing settings must not be negative")
+	}
+	if MinGap == 0 && ThinkTimeMax == 0 && RequestRate == 0 {
+		return nil, nil
+	}
+
+	p := &xserver.Pacing{
+		MinGap:       MinGap,
+		ThinkTimeMin: ThinkTimeMin,
+		ThinkTimeMax: ThinkTimeMax,
+	}
+	if RequestRate > 0 {
+		p.Limiter = xserver.NewHostLimiter(RequestRate, RequestBurst)
+	}
+	return p, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_pacing(t *testing.T) {
+	tests := []struct {
+		name        string
+		minGap      time.Duration
+		thinkMin    time.Duration
+		thinkMax    time.Duration
+		rate        float64
+		wantNil     bool
+		wantLimiter bool
+		wantErr     bool
+	}{
+		{name: "Defaults", wantNil: true},
+		{name: "Human-like", minGap: 2 * time.Second, thinkMin: 3 * time.Second, thinkMax: 8 * time.Second, rate: 0.5, wantLimiter: true},
+		{name: "Gap only", minGap: time.Second},
+		{name: "Inverted think time", thinkMin: 2 * time.Second, thinkMax: time.Second, wantErr: true},
+		{name: "Negative rate", rate: -1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withoutPacing(t)
+			MinGap, ThinkTimeMin, ThinkTimeMax, RequestRate = tt.minGap, tt.thinkMin, tt.thinkMax, tt.rate
+
+			p, err := pacing()
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantNil {
+				if p != nil {
+					t.Errorf("expected no pacing, got %+v", p)
+				}
+				return
+			}
+			if p == nil {
+				t.Fatal("expected pacing but got nil")
+			}
+			if p.MinGap != tt.minGap || p.ThinkTimeMin != tt.thinkMin || p.ThinkTimeMax != tt.thinkMax {
+				t.Errorf("unexpected pacing %+v", p)
+			}
+			if (p.Limiter != nil) != tt.wantLimiter {
+				t.Errorf("expected limiter %v, got %v", tt.wantLimiter, p.Limiter)
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"x-revalidate-bot/pkg/xserver"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// daemonLoader builds the clients, servers and schedule of the daemon from
+// the config file and the environment. Reloads are serialized and keep the
+// client of every unchanged account, so its session survives.
+type daemonLoader struct {
+	cmd *cobra.Command
+
+	mu   sync.Mutex
+	pool *xserver.AccountPool
+	// shared describes the client options pool was created with.
+	shared string
+	items  []xserver.BatchItem
+	config Config
+	// The rate limiter and transport outlive reloads that leave their
+	// settings alone, so the rate stays capped across pools and connections
+	// are reused. The keys describe the settings they were made with.
+	limiter      *xserver.HostLimiter
+	limiterKey   string
+	transport    http.RoundTripper
+	transportKey string
+}
+
+// load builds the daemon from the config loaded by the command.
+func (l *daemonLoader) load() (*xserver.AccountPool, []xserver.BatchItem, *cronSchedule, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	pool, shared, items, schedule, err := l.build()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	l.pool, l.shared, l.items, l.config = pool, shared, items, fileConfig
+	return pool, items, schedule, nil
+}
+
+// reload reads the config file and header profiles again and applies them
+// to d. On error d keeps running with the config it had.
+func (l *daemonLoader) reload(d *daemon) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	previous, previousPath := fileConfig, fileConfigPath
+	restore := func() {
+		fileConfig, fileConfigPath = previous, previousPath
+		_ = applyConfig(l.cmd.Flags(), previous)
+	}
+	if err := loadConfig(l.cmd); err != nil {
+		restore()
+		return err
+	}
+	pool, shared, items, schedule, err := l.build()
+	if err != nil {
+		restore()
+		return err
+	}
+
+	changes := configChanges(l.config, fileConfig)
+	changes = append(changes, serverChanges(l.items, items)...)
+	switch {
+	case shared != l.shared:
+		changes = append(changes, "shared client settings changed, every session starts over")
+	default:
+		for _, account := range pool.Accounts() {
+			before, existed := l.pool.Client(account.Name)
+			if after, _ := pool.Client(account.Name); existed && before != after {
+				changes = append(changes, fmt.Sprintf("account %s: session starts over", account.Name))
+			}
+		}
+	}
+	l.pool, l.shared, l.items, l.config = pool, shared, items, fileConfig
+	d.apply(pool, items, schedule)
+
+	if len(changes) == 0 {
+		slog.Info("Config reloaded, nothing changed", "path", fileConfigPath)
+		return nil
+	}
+	slog.Info("Config reloaded", "path", fileConfigPath, "servers", len(items), "changes", len(changes))
+	for _, change := range changes {
+		slog.Info("Config changed", "change", change)
+	}
+	return nil
+}
+
+// build creates the pool, reusing the clients of l.pool when the options
+// they share did not change.
+func (l *daemonLoader) build() (*xserver.AccountPool, string, []xserver.BatchItem, *cronSchedule, error) {
+	accounts, err := loadAccounts(false)
+	if err != nil {
+		return nil, "", nil, nil, err
+	}
+	options, poolAccounts, err := poolConfig(accounts)
+	if err != nil {
+		return nil, "", nil, nil, err
+	}
+	l.reuseShared(&options)
+	// The schema file may change under the same path.
+	var schema string
+	if PanelSchema != "" {
+		data, err := os.ReadFile(PanelSchema)
+		if err != nil {
+			return nil, "", nil, nil, err
+		}
+		sum := sha256.Sum256(data)
+		schema = hex.EncodeToString(sum[:])
+	}
+	shared := fmt.Sprint(options.Headers, options.BaseURL, PanelSchema, schema, PanelBaseline, options.PageDumpDir, options.Navigation != nil, TLSProfile)
+	var pool *xserver.AccountPool
+	if l.pool != nil && shared == l.shared {
+		pool, err = l.pool.Rebuild(poolAccounts, options)
+	} else {
+		pool, err = xserver.NewAccountPool(poolAccounts, options)
+	}
+	if err != nil {
+		slog.Error("Error creating XServer clients", "error", err)
+		return nil, "", nil, nil, err
+	}
+
+	var schedule *cronSchedule
+	if fileConfig.Schedule.Cron != "" {
+		if schedule, err = parseSchedule(fileConfig.Schedule); err != nil {
+			return nil, "", nil, nil, err
+		}
+	}
+	l.rememberShared(options)
+	return pool, shared, batchItems(accounts), schedule, nil
+}
+
+// reuseShared puts the rate limiter and transport of the previous build into
+// options unless their settings changed.
+func (l *daemonLoader) reuseShared(options *xserver.ClientOptions) {
+	if options.Pacing != nil && options.Pacing.Limiter != nil && l.limiter != nil && limiterKey() == l.limiterKey {
+		options.Pacing.Limiter = l.limiter
+	}
+	if l.transport != nil && transportKey(options) == l.transportKey {
+		options.Transport = l.transport
+	}
+}
+
+// rememberShared keeps the rate limiter and transport of options for the
+// next build, closing the idle connections of a transport replaced.
+func (l *daemonLoader) rememberShared(options xserver.ClientOptions) {
+	if options.Pacing != nil && options.Pacing.Limiter != nil {
+		l.limiter, l.limiterKey = options.Pacing.Limiter, limiterKey()
+	}
+	if closer, ok := l.transport.(interface{ CloseIdleConnections() }); ok && l.transport != options.Transport {
+		closer.CloseIdleConnections()
+	}
+	l.transport, l.transportKey = options.Transport, transportKey(&options)
+}
+
+func limiterKey() string { return fmt.Sprint(RequestRate, RequestBurst) }
+
+func transportKey(options *xserver.ClientOptions) string {
+	return fmt.Sprint(TLSProfile, options.BaseURL, Chaos)
+}
+
+// paths lists the files a change of which calls for a reload.
+func (l *daemonLoader) paths() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var paths []string
+	for _, path := range []string{fileConfigPath, fileConfig.HeaderProfile, PanelSchema} {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	for _, account := range fileConfig.Accounts {
+		if account.HeaderProfile != "" {
+			paths = append(paths, account.HeaderProfile)
+		}
+	}
+	for i, path := range paths {
+		if abs, err := filepath.Abs(path); err == nil {
+			paths[i] = abs
+		}
+	}
+	return paths
+}
+
+// configChanges describes how the config file changed from before to after,
+// without revealing secrets. Servers are left to serverChanges.
+func configChanges(before, after Config) []string {
+	var changes []string
+	if before.SessionID != after.SessionID || before.DeviceKey != after.DeviceKey {
+		changes = append(changes, "session_id or device_key changed")
+	}
+	if before.Notifications.WebhookURL != after.Notifications.WebhookURL {
+		changes = append(changes, "notifications.webhook_url changed")
+	}
+
+	old, current := settings(before), settings(after)
+	keys := make([]string, 0, len(old)+len(current))
+	for key := range old {
+		keys = append(keys, key)
+	}
+	for key := range current {
+		if _, ok := old[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if old[key] != current[key] {
+			changes = append(changes, fmt.Sprintf("%s: %q -> %q", key, old[key], current[key]))
+		}
+	}
+
+	accounts := make(map[string]AccountConfig, len(before.Accounts))
+	for _, account := range before.Accounts {
+		accounts[account.Name] = account
+	}
+	for _, account := range after.Accounts {
+		previous, ok := accounts[account.Name]
+		delete(accounts, account.Name)
+		switch {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("account %s added", account.Name))
+		case previous.SessionID != account.SessionID || previous.DeviceKey != account.DeviceKey:
+			changes = append(changes, fmt.Sprintf("account %s: session_id or device_key changed", account.Name))
+		}
+		if ok && previous.HeaderProfile != account.HeaderProfile {
+			changes = append(changes, fmt.Sprintf("account %s: header_profile: %q -> %q", account.Name, previous.HeaderProfile, account.HeaderProfile))
+		}
+		if ok && previous.Proxy != account.Proxy {
+			changes = append(changes, fmt.Sprintf("account %s: proxy changed", account.Name))
+		}
+	}
+	for _, account := range before.Accounts {
+		if _, ok := accounts[account.Name]; ok {
+			changes = append(changes, fmt.Sprintf("account %s removed", account.Name))
+		}
+	}
+	return changes
+}
+
+// settings flattens the plain settings of c to dotted keys, leaving out the
+// servers, accounts and secrets.
+func settings(c Config) map[string]string {
+	c.VPSIDs, c.Accounts, c.SessionID, c.DeviceKey, c.Notifications.WebhookURL = nil, nil, "", "", ""
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return nil
+	}
+	var tree map[string]any
+	if err := yaml.Unmarshal(data, &tree); err != nil {
+		return nil
+	}
+	flat := map[string]string{}
+	for key, value := range tree {
+		if nested, ok := value.(map[string]any); ok {
+			for subkey, value := range nested {
+				flat[key+"."+subkey] = fmt.Sprint(value)
+			}
+			continue
+		}
+		flat[key] = fmt.Sprint(value)
+	}
+	return flat
+}
+
+// serverChanges describes the servers added and removed from before to after.
+func serverChanges(before, after []xserver.BatchItem) []string {
+	var changes []string
+	for _, item := range after {
+		if !slices.Contains(before, item) {
+			changes = append(changes, fmt.Sprintf("server %s added", stateKey(item)))
+		}
+	}
+	for _, item := range before {
+		if !slices.Contains(after, item) {
+			changes = append(changes, fmt.Sprintf("server %s removed", stateKey(item)))
+		}
+	}
+	return changes
+}
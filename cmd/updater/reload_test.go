@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"x-revalidate-bot/pkg/xserver"
+	"x-revalidate-bot/pkg/xserver/xservertest"
+)
+
+func Test_daemonLoader_reload(t *testing.T) {
+	savedPath, savedEnvFile, savedConfigPath := ConfigPath, EnvFile, fileConfigPath
+	t.Cleanup(func() { ConfigPath, EnvFile, fileConfigPath = savedPath, savedEnvFile, savedConfigPath })
+	withConfig(t, Config{})
+	t.Setenv("VPS_ID", "")
+	t.Setenv("X2SESSID", "")
+	t.Setenv("XSERVER_DEVICEKEY", "")
+	var logs bytes.Buffer
+	savedLogger := slog.Default()
+	defer slog.SetDefault(savedLogger)
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+
+	const base = "session_id: session\ndevice_key: device\nbase_url: http://127.0.0.1:1\n"
+	EnvFile = filepath.Join(t.TempDir(), "missing.env")
+	ConfigPath = writeFile(t, "config.yaml", base+"vps_ids: [\"111\"]\n")
+	if err := loadConfig(daemonCmd); err != nil {
+		t.Fatal(err)
+	}
+	loader := &daemonLoader{cmd: daemonCmd}
+	pool, items, _, err := loader.load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	session, _ := pool.Client(defaultAccount)
+
+	now := time.Date(2025, 7, 10, 12, 0, 0, 0, time.UTC)
+	fake := xservertest.NewFakeClient().WithServer("111", now.Add(30*time.Hour))
+	d := testDaemon(fake, &now, items[0].VPSID)
+	d.cycle(context.Background())
+	reload := func(config string) error {
+		t.Helper()
+		if err := os.WriteFile(ConfigPath, []byte(config), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		logs.Reset()
+		return loader.reload(d)
+	}
+
+	config := base + "vps_ids: [\"111\", \"222\"]\nschedule:\n  cron: \"0 9 * * *\"\naccounts:\n  - {name: work, session_id: s, device_key: d, vps_ids: [\"333\"]}\n"
+	if err := reload(config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(d.snapshot()) != 3 || d.schedule() == nil || d.server(items[0]).NextAttempt.IsZero() {
+		t.Errorf("expected the new servers and schedule with the old state, got %v, %v, %+v", d.snapshot(), d.schedule(), d.server(items[0]))
+	}
+	if client, _ := loader.pool.Client(defaultAccount); client != session {
+		t.Error("expected the session of the unchanged account to be kept")
+	}
+	for _, want := range []string{`server default/222 added`, `server work/333 added`, `account work added`, `schedule.cron: \"\" -> \"0 9 * * *\"`} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("expected the logs to contain %s, got:\n%s", want, logs.String())
+		}
+	}
+
+	if err := reload("vps_id: [\"333\"]\n"); err == nil {
+		t.Error("expected an error for an invalid config")
+	}
+	if len(d.snapshot()) != 3 || len(fileConfig.VPSIDs) != 2 || fileConfig.Schedule.Cron == "" {
+		t.Errorf("expected the previous config to be kept, got %v and %+v", d.snapshot(), fileConfig)
+	}
+
+	if err := reload(strings.Replace(base, "session_id: session", "session_id: renewed", 1) + "vps_ids: [\"111\"]\n"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if client, _ := loader.pool.Client(defaultAccount); client == session {
+		t.Error("expected a new session for new credentials")
+	}
+	for _, want := range []string{`session_id or device_key changed`, `account default: session starts over`, `server work/333 removed`, `account work removed`} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("expected the logs to contain %s, got:\n%s", want, logs.String())
+		}
+	}
+}
+
+func Test_daemonLoader_Shared(t *testing.T) {
+	savedPath, savedEnvFile, savedConfigPath := ConfigPath, EnvFile, fileConfigPath
+	savedRate, savedSchema, savedProfile := RequestRate, PanelSchema, TLSProfile
+	t.Cleanup(func() {
+		ConfigPath, EnvFile, fileConfigPath = savedPath, savedEnvFile, savedConfigPath
+		RequestRate, PanelSchema, TLSProfile = savedRate, savedSchema, savedProfile
+	})
+	withConfig(t, Config{})
+	t.Setenv("VPS_ID", "")
+	t.Setenv("X2SESSID", "")
+	t.Setenv("XSERVER_DEVICEKEY", "")
+	RequestRate, TLSProfile = 1, tlsProfileChrome
+
+	embedded, err := os.ReadFile(filepath.Join("..", "..", "pkg", "xserver", "panel_schema.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := writeFile(t, "schema.yaml", string(embedded))
+	EnvFile = filepath.Join(t.TempDir(), "missing.env")
+	ConfigPath = writeFile(t, "config.yaml", "session_id: session\ndevice_key: device\nbase_url: http://127.0.0.1:1\nvps_ids: [\"111\"]\n")
+	PanelSchema = schema
+	if err := loadConfig(daemonCmd); err != nil {
+		t.Fatal(err)
+	}
+	loader := &daemonLoader{cmd: daemonCmd}
+	pool, items, _, err := loader.load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	session, _ := pool.Client(defaultAccount)
+	limiter, transport := loader.limiter, loader.transport
+	if limiter == nil || transport == nil {
+		t.Fatalf("expected a limiter and a transport, got %v and %v", limiter, transport)
+	}
+	if !slices.Contains(loader.paths(), schema) {
+		t.Errorf("expected the schema to be watched, got %v", loader.paths())
+	}
+
+	now := time.Date(2025, 7, 10, 12, 0, 0, 0, time.UTC)
+	d := testDaemon(xservertest.NewFakeClient(), &now, items[0].VPSID)
+	if err := loader.reload(d); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if client, _ := loader.pool.Client(defaultAccount); client != session {
+		t.Error("expected the session to be kept when nothing changed")
+	}
+
+	// The same path with new contents makes new clients, which still share
+	// the limiter and transport.
+	if err := os.WriteFile(schema, append(embedded, "# changed\n"...), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := loader.reload(d); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if client, _ := loader.pool.Client(defaultAccount); client == session {
+		t.Error("expected a new session for a changed schema")
+	}
+	if loader.limiter != limiter || loader.transport != transport {
+		t.Error("expected the limiter and transport to survive the reloads")
+	}
+}
+
+func Test_configChanges(t *testing.T) {
+	zero, three := 0, 3
+	tests := []struct {
+		name          string
+		before, after Config
+		want          []string
+	}{
+		{name: "Nothing", before: Config{Retries: &three}, after: Config{Retries: &three}},
+		{name: "Zero", before: Config{Retries: &three}, after: Config{Retries: &zero}, want: []string{`retries: "3" -> "0"`}},
+		{
+			name:   "Settings",
+			before: Config{Retries: &three, Schedule: ScheduleConfig{Timezone: "UTC"}},
+			after:  Config{Navigate: true, Schedule: ScheduleConfig{Timezone: "Asia/Tokyo"}},
+			want:   []string{`navigate: "" -> "true"`, `retries: "3" -> ""`, `schedule.timezone: "UTC" -> "Asia/Tokyo"`},
+		},
+		{
+			name:   "Secrets are not shown",
+			before: Config{SessionID: "secret-1", Notifications: NotificationConfig{WebhookURL: "https://hooks.example.com/a"}},
+			after:  Config{SessionID: "secret-2", Notifications: NotificationConfig{WebhookURL: "https://hooks.example.com/b"}},
+			want:   []string{"session_id or device_key changed", "notifications.webhook_url changed"},
+		},
+		{
+			name: "Accounts",
+			before: Config{Accounts: []AccountConfig{
+				{Name: "a", SessionID: "s", DeviceKey: "d"},
+				{Name: "b", SessionID: "s", DeviceKey: "d", Proxy: "http://127.0.0.1:3128"},
+			}},
+			after: Config{Accounts: []AccountConfig{
+				{Name: "b", SessionID: "s2", DeviceKey: "d", HeaderProfile: "b.json"},
+				{Name: "c", SessionID: "s", DeviceKey: "d"},
+			}},
+			want: []string{
+				"account b: session_id or device_key changed",
+				`account b: header_profile: "" -> "b.json"`,
+				"account b: proxy changed",
+				"account c added",
+				"account a removed",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := configChanges(tt.before, tt.after); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_serverChanges(t *testing.T) {
+	before := []xserver.BatchItem{{Account: "a", VPSID: "1"}, {Account: "a", VPSID: "2"}}
+	after := []xserver.BatchItem{{Account: "a", VPSID: "2"}, {Account: "b", VPSID: "1"}}
+	want := []string{"server b/1 added", "server a/1 removed"}
+	if got := serverChanges(before, after); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+	"x-revalidate-bot/pkg/xserver"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// exitTempFail is returned when the panel was still unavailable after all retries,
+// so wrappers can tell a temporary outage from a broken setup (EX_TEMPFAIL).
+const exitTempFail = 75
+
+// exitPartial is returned when some servers were renewed and others were not.
+const exitPartial = 3
+
+var (
+	Retries      int
+	RetryWait    time.Duration
+	MaxRetryWait time.Duration
+	PageDumpDir  string
+)
+
+func isTemporary(err error) bool {
+	return errors.Is(err, xserver.ErrMaintenance) || errors.Is(err, xserver.ErrChallengeRequired)
+}
+
+func exitCode(err error) int {
+	var partial *partialError
+	if errors.As(err, &partial) {
+		return exitPartial
+	}
+	var drift *driftError
+	if errors.As(err, &drift) {
+		return exitDrift
+	}
+	if isTemporary(err) {
+		return exitTempFail
+	}
+	return 1
+}
+
+// retryOnInterstitial runs fn again after backing off while the panel serves
+// a maintenance notice or a bot challenge. The retry count is recorded on the
+// span of ctx, which should belong to a single server.
+func retryOnInterstitial(ctx context.Context, fn func() error) error {
+	wait := RetryWait
+	for attempt := 1; ; attempt++ {
+		trace.SpanFromContext(ctx).SetAttributes(xserver.AttributeRetryCount.Int(attempt - 1))
+		err := fn()
+		if err == nil || !isTemporary(err) || attempt > Retries {
+			return err
+		}
+
+		delay := backoffDelay(err, wait, time.Now())
+		slog.Warn("Panel is temporarily unavailable, retrying", "error", err, "attempt", attempt, "retry_in", delay)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		wait *= 2
+	}
+}
+
+// backoffDelay waits until the announced end of maintenance when there is one,
+// and never longer than MaxRetryWait.
+func backoffDelay(err error, wait time.Duration, now time.Time) time.Duration {
+	return backoffUntil(err, wait, MaxRetryWait, now)
+}
+
+// backoffUntil is backoffDelay with an explicit upper bound.
+func backoffUntil(err error, wait, limit time.Duration, now time.Time) time.Duration {
+	var pageErr *xserver.PageError
+	if errors.As(err, &pageErr) && pageErr.Until.After(now) {
+		wait = pageErr.Until.Sub(now) + time.Minute
+	}
+	return min(wait, limit)
+}
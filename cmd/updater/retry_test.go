@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+	"x-revalidate-bot/pkg/xserver"
+)
+
+func Test_backoffDelay(t *testing.T) {
+	MaxRetryWait = 30 * time.Minute
+	now := time.Date(2025, 7, 10, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		err      error
+		wait     time.Duration
+		expected time.Duration
+	}{
+		{
+			name:     "Challenge uses the backoff wait",
+			err:      &xserver.PageError{Err: xserver.ErrChallengeRequired},
+			wait:     2 * time.Minute,
+			expected: 2 * time.Minute,
+		},
+		{
+			name:     "Maintenance waits until the announced end",
+			err:      &xserver.PageError{Err: xserver.ErrMaintenance, Until: now.Add(10 * time.Minute)},
+			wait:     time.Minute,
+			expected: 11 * time.Minute,
+		},
+		{
+			name:     "Long maintenance is capped",
+			err:      &xserver.PageError{Err: xserver.ErrMaintenance, Until: now.Add(5 * time.Hour)},
+			wait:     time.Minute,
+			expected: 30 * time.Minute,
+		},
+		{
+			name:     "Announced end in the past",
+			err:      &xserver.PageError{Err: xserver.ErrMaintenance, Until: now.Add(-time.Hour)},
+			wait:     time.Minute,
+			expected: time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := backoffDelay(tt.err, tt.wait, now); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func Test_retryOnInterstitial(t *testing.T) {
+	Retries = 2
+	RetryWait = time.Millisecond
+	MaxRetryWait = time.Millisecond
+
+	t.Run("Retries temporary errors", func(t *testing.T) {
+		calls := 0
+		err := retryOnInterstitial(context.Background(), func() error {
+			calls++
+			if calls < 3 {
+				return &xserver.PageError{Err: xserver.ErrMaintenance}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if calls != 3 {
+			t.Errorf("expected 3 calls, got %d", calls)
+		}
+	})
+
+	t.Run("Gives up after retries", func(t *testing.T) {
+		calls := 0
+		err := retryOnInterstitial(context.Background(), func() error {
+			calls++
+			return &xserver.PageError{Err: xserver.ErrChallengeRequired}
+		})
+		if !errors.Is(err, xserver.ErrChallengeRequired) {
+			t.Fatalf("expected ErrChallengeRequired, got %v", err)
+		}
+		if calls != 3 {
+			t.Errorf("expected 3 calls, got %d", calls)
+		}
+		if exitCode(err) != exitTempFail {
+			t.Errorf("expected exit code %d, got %d", exitTempFail, exitCode(err))
+		}
+	})
+
+	t.Run("Does not retry other errors", func(t *testing.T) {
+		calls := 0
+		err := retryOnInterstitial(context.Background(), func() error {
+			calls++
+			return fmt.Errorf("VPS renewal failed")
+		})
+		if err == nil || calls != 1 {
+			t.Errorf("expected a single failed call, got %d calls and %v", calls, err)
+		}
+		if exitCode(err) != 1 {
+			t.Errorf("expected exit code 1, got %d", exitCode(err))
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"time"
+	// Bundled so the default timezone works in images without zoneinfo.
+	_ "time/tzdata"
+
+	"github.com/robfig/cron/v3"
+	"github.com/spf13/cobra"
+)
+
+// defaultTimezone is the zone schedules are evaluated in, the panel's own.
+const defaultTimezone = "Asia/Tokyo"
+
+var (
+	ScheduleCount    int
+	ScheduleCron     string
+	ScheduleTimezone string
+)
+
+func init() {
+	scheduleNextCmd.Flags().IntVarP(&ScheduleCount, "count", "n", 5, "Number of fire times to print")
+	scheduleNextCmd.Flags().StringVar(&ScheduleCron, "cron", "", "Cron expression to preview instead of schedule.cron of the config file")
+	scheduleNextCmd.Flags().StringVar(&ScheduleTimezone, "timezone", "", "Timezone to preview in instead of schedule.timezone of the config file")
+	scheduleCmd.AddCommand(scheduleNextCmd)
+	rootCmd.AddCommand(scheduleCmd)
+}
+
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Inspect the renewal schedule of the daemon",
+}
+
+var scheduleNextCmd = &cobra.Command{
+	Use:   "next",
+	Short: "Print the upcoming fire times of the schedule",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config := ScheduleConfig{
+			Cron:     cmp.Or(ScheduleCron, fileConfig.Schedule.Cron),
+			Timezone: cmp.Or(ScheduleTimezone, fileConfig.Schedule.Timezone),
+		}
+		if config.Cron == "" {
+			return fmt.Errorf("no schedule, set schedule.cron in the config file or pass --cron")
+		}
+		schedule, err := parseSchedule(config)
+		if err != nil {
+			return err
+		}
+		printNext(cmd.OutOrStdout(), schedule, time.Now(), ScheduleCount)
+		return nil
+	},
+}
+
+// cronSchedule is a cron expression evaluated in a timezone.
+type cronSchedule struct {
+	Spec     string
+	Location *time.Location
+	schedule cron.Schedule
+}
+
+// parseSchedule parses a five field cron expression or a descriptor such as
+// @daily, evaluated in config.Timezone or Asia/Tokyo.
+func parseSchedule(config ScheduleConfig) (*cronSchedule, error) {
+	loc, err := time.LoadLocation(cmp.Or(config.Timezone, defaultTimezone))
+	if err != nil {
+		return nil, fmt.Errorf("schedule.timezone: %w", err)
+	}
+	schedule, err := cron.ParseStandard(config.Cron)
+	if err != nil {
+		return nil, fmt.Errorf("schedule.cron: %w", err)
+	}
+	return &cronSchedule{Spec: config.Cron, Location: loc, schedule: schedule}, nil
+}
+
+// Next returns the first fire time after t, in the schedule's timezone.
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	return s.schedule.Next(t.In(s.Location))
+}
+
+func printNext(w io.Writer, schedule *cronSchedule, now time.Time, count int) {
+	fmt.Fprintf(w, "%s (%s)\n", schedule.Spec, schedule.Location)
+	next := now
+	for range count {
+		if next = schedule.Next(next); next.IsZero() {
+			return
+		}
+		fmt.Fprintf(w, "  %s  in %s\n", next.Format("Mon 2006-01-02 15:04 MST"), remaining(next, now))
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_parseSchedule(t *testing.T) {
+	now := time.Date(2025, 7, 10, 12, 0, 0, 0, time.UTC) // 21:00 in Tokyo
+	tests := []struct {
+		name     string
+		config   ScheduleConfig
+		wantNext string
+		wantErr  string
+	}{
+		{name: "Tokyo by default", config: ScheduleConfig{Cron: "0 9 * * *"}, wantNext: "2025-07-11T09:00:00+09:00"},
+		{name: "Timezone", config: ScheduleConfig{Cron: "0 9 * * *", Timezone: "UTC"}, wantNext: "2025-07-11T09:00:00Z"},
+		{name: "Descriptor", config: ScheduleConfig{Cron: "@hourly"}, wantNext: "2025-07-10T22:00:00+09:00"},
+		{name: "Invalid expression", config: ScheduleConfig{Cron: "0 9 * *"}, wantErr: "schedule.cron"},
+		{name: "Invalid timezone", config: ScheduleConfig{Cron: "0 9 * * *", Timezone: "Mars/Base"}, wantErr: "schedule.timezone"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := parseSchedule(tt.config)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if next := schedule.Next(now).Format(time.RFC3339); next != tt.wantNext {
+				t.Errorf("expected %s, got %s", tt.wantNext, next)
+			}
+		})
+	}
+}
+
+func Test_printNext(t *testing.T) {
+	schedule, err := parseSchedule(ScheduleConfig{Cron: "0 9 * * 1-5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2025, 7, 11, 0, 30, 0, 0, schedule.Location) // Friday
+	var out bytes.Buffer
+	printNext(&out, schedule, now, 3)
+
+	want := "0 9 * * 1-5 (Asia/Tokyo)\n" +
+		"  Fri 2025-07-11 09:00 JST  in 8h30m\n" +
+		"  Mon 2025-07-14 09:00 JST  in 3d8h\n" +
+		"  Tue 2025-07-15 09:00 JST  in 4d8h\n"
+	if out.String() != want {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
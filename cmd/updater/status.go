@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"text/tabwriter"
+	"time"
+	"x-revalidate-bot/pkg/xserver"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	JSONOutput bool
+	FreeOnly   bool
+)
+
+func init() {
+	statusCmd.Flags().BoolVar(&JSONOutput, "json", false, "Print JSON instead of a table")
+	listCmd.Flags().BoolVar(&JSONOutput, "json", false, "Print JSON instead of a table")
+	listCmd.Flags().BoolVar(&FreeOnly, "free-only", false, "Only list servers on the free plan")
+	rootCmd.AddCommand(statusCmd, listCmd)
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the expiry of the servers in VPS_ID read-only (experimental)",
+	Long:  "Show the expiry of the servers in VPS_ID read-only." + experimentalNote,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		creds, err := loadCredentials()
+		if err != nil {
+			return err
+		}
+		xs, err := newClient(creds)
+		if err != nil {
+			return err
+		}
+
+		ctx := context.Background()
+		var statuses []xserver.ServerStatus
+		var errs []error
+		for _, vpsID := range creds.VPSIDs {
+			status, err := xs.GetServerStatus(ctx, vpsID)
+			if err != nil {
+				slog.Error("Error reading server status", "error", err, "error_code", xserver.ErrorCodeOf(err), "vps_id", vpsID)
+				errs = append(errs, fmt.Errorf("%s: %w", vpsID, err))
+				continue
+			}
+			statuses = append(statuses, status)
+		}
+		if JSONOutput {
+			if err := writeJSON(cmd.OutOrStdout(), statuses); err != nil {
+				return err
+			}
+		} else {
+			printStatuses(cmd.OutOrStdout(), statuses, time.Now())
+		}
+		return errors.Join(errs...)
+	},
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the servers of the account from the panel top page (experimental)",
+	Long:  "List the servers of the account from the panel top page." + experimentalNote,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		creds, err := loadSession()
+		if err != nil {
+			return err
+		}
+		xs, err := newClient(creds)
+		if err != nil {
+			return err
+		}
+
+		servers, err := xs.ListServers(context.Background())
+		if err != nil {
+			slog.Error("Error listing servers", "error", err, "error_code", xserver.ErrorCodeOf(err))
+			return err
+		}
+		if FreeOnly {
+			servers = freeServers(servers)
+		}
+		if JSONOutput {
+			return writeJSON(cmd.OutOrStdout(), servers)
+		}
+		printServers(cmd.OutOrStdout(), servers, time.Now())
+		return nil
+	},
+}
+
+func freeServers(servers []xserver.Server) []xserver.Server {
+	var free []xserver.Server
+	for _, server := range servers {
+		if server.Free {
+			free = append(free, server)
+		}
+	}
+	return free
+}
+
+func writeJSON(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func printStatuses(w io.Writer, statuses []xserver.ServerStatus, now time.Time) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VPS ID\tEXPIRY\tREMAINING")
+	for _, status := range statuses {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", status.VPSID, formatExpiry(status.Expiry), remaining(status.Expiry, now))
+	}
+	tw.Flush()
+}
+
+func printServers(w io.Writer, servers []xserver.Server, now time.Time) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VPS ID\tNAME\tPLAN\tFREE\tEXPIRY\tREMAINING")
+	for _, server := range servers {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%t\t%s\t%s\n", server.VPSID, server.Name, server.Plan, server.Free,
+			formatExpiry(server.Expiry), remaining(server.Expiry, now))
+	}
+	tw.Flush()
+}
+
+func formatExpiry(expiry time.Time) string {
+	if expiry.IsZero() {
+		return "-"
+	}
+	return expiry.Format("2006-01-02 15:04 MST")
+}
+
+// remaining formats the time left until expiry, rounded down to minutes.
+func remaining(expiry, now time.Time) string {
+	switch {
+	case expiry.IsZero():
+		return "-"
+	case !expiry.After(now):
+		return "expired"
+	}
+	left := expiry.Sub(now).Truncate(time.Minute)
+	days := left / (24 * time.Hour)
+	left -= days * 24 * time.Hour
+	if days > 0 {
+		return fmt.Sprintf("%dd%dh", days, left/time.Hour)
+	}
+	return fmt.Sprintf("%dh%dm", left/time.Hour, (left%time.Hour)/time.Minute)
+}
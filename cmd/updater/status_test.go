@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+	"x-revalidate-bot/pkg/xserver"
+)
+
+func Test_remaining(t *testing.T) {
+	now := time.Date(2025, 7, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		expiry   time.Time
+		expected string
+	}{
+		{expiry: time.Time{}, expected: "-"},
+		{expiry: now, expected: "expired"},
+		{expiry: now.Add(90*time.Minute + 30*time.Second), expected: "1h30m"},
+		{expiry: now.Add(50 * time.Hour), expected: "2d2h"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			if got := remaining(tt.expiry, now); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func Test_printServers(t *testing.T) {
+	jst := time.FixedZone("JST", 9*60*60)
+	now := time.Date(2025, 7, 10, 12, 0, 0, 0, jst)
+	servers := []xserver.Server{
+		{VPSID: "12345", Name: "vps-12345", Plan: "Free VPS", Free: true, Expiry: now.Add(26 * time.Hour)},
+		{VPSID: "67890", Name: "big", Plan: "2GB"},
+	}
+
+	var buf bytes.Buffer
+	printServers(&buf, servers, now)
+	expected := "VPS ID  NAME       PLAN      FREE   EXPIRY                REMAINING\n" +
+		"12345   vps-12345  Free VPS  true   2025-07-11 14:00 JST  1d2h\n" +
+		"67890   big        2GB       false  -                     -\n"
+	if buf.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, buf.String())
+	}
+	if free := freeServers(servers); len(free) != 1 || free[0].VPSID != "12345" {
+		t.Errorf("expected only the free server, got %+v", free)
+	}
+}
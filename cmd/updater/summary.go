@@ -0,0 +1,33 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"x-revalidate-bot/pkg/xserver"
+)
+
+// printSummary prints one row per server of a renewal run.
+func printSummary(w io.Writer, renewals []renewal) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VPS ID\tACCOUNT\tPREVIOUS EXPIRY\tNEW EXPIRY\tOUTCOME\tERROR CODE\tREASON")
+	renewed, failed, skipped := 0, 0, 0
+	for _, r := range renewals {
+		outcome, code, reason := "renewed", "-", "-"
+		switch {
+		case r.Err != nil:
+			outcome, code = "failed", string(xserver.ErrorCodeOf(r.Err))
+			failed++
+		case r.Skipped != "":
+			outcome, reason = "skipped", r.Skipped
+			skipped++
+		default:
+			renewed++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", cmp.Or(r.Item.VPSID.String(), "-"), r.Item.Account,
+			formatExpiry(r.Previous), formatExpiry(r.New), outcome, code, reason)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "%d renewed, %d failed, %d skipped\n", renewed, failed, skipped)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+	"x-revalidate-bot/pkg/xserver"
+)
+
+func Test_printSummary(t *testing.T) {
+	jst := time.FixedZone("JST", 9*60*60)
+	expiry := time.Date(2025, 7, 11, 14, 0, 0, 0, jst)
+	renewals := []renewal{
+		{Item: xserver.BatchItem{Account: "default", VPSID: "111"}, Previous: expiry, New: expiry.Add(24 * time.Hour)},
+		{Item: xserver.BatchItem{Account: "work", VPSID: "222"}, Err: xserver.ErrMaintenance},
+		{Item: xserver.BatchItem{Account: "work", VPSID: "333"}, Previous: expiry, Skipped: "matched by --exclude"},
+	}
+
+	var buf bytes.Buffer
+	printSummary(&buf, renewals)
+	expected := "VPS ID  ACCOUNT  PREVIOUS EXPIRY       NEW EXPIRY            OUTCOME  ERROR CODE   REASON\n" +
+		"111     default  2025-07-11 14:00 JST  2025-07-12 14:00 JST  renewed  -            -\n" +
+		"222     work     -                     -                     failed   maintenance  -\n" +
+		"333     work     2025-07-11 14:00 JST  -                     skipped  -            matched by --exclude\n" +
+		"1 renewed, 1 failed, 1 skipped\n"
+	if buf.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, buf.String())
+	}
+}
+
+func Test_runError(t *testing.T) {
+	renewed := renewal{}
+	failed := renewal{Err: xserver.ErrMaintenance}
+	skipped := renewal{Skipped: "not a free plan"}
+	tests := []struct {
+		name     string
+		renewals []renewal
+		expected int
+	}{
+		{name: "All succeeded", renewals: []renewal{renewed, skipped}, expected: 0},
+		{name: "Nothing to do", renewals: []renewal{skipped}, expected: 0},
+		{name: "Some succeeded", renewals: []renewal{renewed, failed}, expected: exitPartial},
+		{name: "None succeeded", renewals: []renewal{failed, skipped}, expected: exitTempFail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := runError(tt.renewals)
+			if tt.expected == 0 {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+				return
+			}
+			if got := exitCode(err); got != tt.expected {
+				t.Errorf("expected exit code %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
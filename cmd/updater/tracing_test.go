@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"x-revalidate-bot/pkg/xserver"
+	"x-revalidate-bot/pkg/xserver/xservertest"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func Test_tracingEnabled(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		expected bool
+	}{
+		{"Not configured", map[string]string{}, false},
+		{"Endpoint set", map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://localhost:4318"}, true},
+		{"Traces endpoint set", map[string]string{"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "http://localhost:4318/v1/traces"}, true},
+		{"SDK disabled", map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://localhost:4318", "OTEL_SDK_DISABLED": "true"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"OTEL_EXPORTER_OTLP_ENDPOINT", "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "OTEL_SDK_DISABLED", "OTEL_TRACES_EXPORTER"} {
+				t.Setenv(key, tt.env[key])
+			}
+			if got := tracingEnabled(); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func Test_runInternally_Span(t *testing.T) {
+	t.Setenv("VPS_ID", "")
+	t.Setenv("X2SESSID", "")
+	t.Setenv("XSERVER_DEVICEKEY", "")
+
+	exporter := tracetest.NewInMemoryExporter()
+	shutdown, err := installTracerProvider(sdktrace.WithSyncer(exporter))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shutdown(context.Background())
+
+	if err := runInternally(context.Background(), io.Discard); err == nil {
+		t.Fatal("expected error for missing credentials")
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 || spans[0].Name != "updater.run" {
+		t.Fatalf("expected a single updater.run span, got %v", spans)
+	}
+	if spans[0].Status.Code != codes.Error {
+		t.Errorf("expected error status, got %v", spans[0].Status)
+	}
+}
+
+func Test_runBatch_SpanPerServer(t *testing.T) {
+	withoutPacing(t)
+	panel := xservertest.NewPanel(xservertest.PanelOptions{
+		SessionID: "fake-session",
+		DeviceKey: "fake-devicekey",
+	})
+	panel.AddServer("111", time.Now().Add(6*time.Hour))
+	panel.AddServer("222", time.Now().Add(6*time.Hour))
+	srv := httptest.NewServer(panel)
+	defer srv.Close()
+
+	t.Setenv("VPS_ID", "111,222")
+	t.Setenv("X2SESSID", "fake-session")
+	t.Setenv("XSERVER_DEVICEKEY", "fake-devicekey")
+	t.Setenv("XSERVER_BASE_URL", srv.URL)
+
+	exporter := tracetest.NewInMemoryExporter()
+	shutdown, err := installTracerProvider(sdktrace.WithSyncer(exporter))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shutdown(context.Background())
+
+	if err := runInternally(context.Background(), io.Discard); err != nil {
+		t.Fatalf("runInternally failed: %v", err)
+	}
+	renewed := map[string]bool{}
+	for _, span := range exporter.GetSpans() {
+		if span.Name != "updater.renew" {
+			continue
+		}
+		var vpsID string
+		hasRetries := false
+		for _, attr := range span.Attributes {
+			switch attr.Key {
+			case xserver.AttributeVPSID:
+				vpsID = attr.Value.AsString()
+			case xserver.AttributeRetryCount:
+				hasRetries = true
+			}
+		}
+		if !hasRetries {
+			t.Errorf("expected the retry count on the span of %s", vpsID)
+		}
+		renewed[vpsID] = true
+	}
+	if !renewed["111"] || !renewed["222"] {
+		t.Errorf("expected an updater.renew span per server, got %v", renewed)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"x-revalidate-bot/pkg/xserver"
+	"x-revalidate-bot/pkg/xserver/chaos"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+const (
+	tlsProfileGo     = "go"
+	tlsProfileChrome = "chrome"
+)
+
+// newTransport builds the transport selected by --tls-fingerprint for the
+// panel at baseURL, wrapped in fault injection when --chaos is set. Nil means
+// http.DefaultTransport.
+func newTransport(baseURL *url.URL) (http.RoundTripper, error) {
+	var transport http.RoundTripper
+	switch TLSProfile {
+	case tlsProfileGo:
+	case tlsProfileChrome:
+		// The Chrome transport dials the panel itself, so a proxy from the
+		// environment would be silently bypassed.
+		if baseURL == nil {
+			baseURL = xserver.DefaultBaseURL
+		}
+		if proxy, err := httpproxy.FromEnvironment().ProxyFunc()(baseURL); err != nil || proxy != nil {
+			return nil, fmt.Errorf("--tls-fingerprint %s does not support proxies: unset HTTPS_PROXY or use --tls-fingerprint %s", tlsProfileChrome, tlsProfileGo)
+		}
+		transport = xserver.NewChromeTransport(xserver.ChromeTransportOptions{})
+	default:
+		return nil, fmt.Errorf("unknown TLS fingerprint %q: want %q or %q", TLSProfile, tlsProfileGo, tlsProfileChrome)
+	}
+
+	if Chaos != "" {
+		options, err := chaos.ParseSpec(Chaos)
+		if err != nil {
+			slog.Error("Error parsing chaos spec", "error", err)
+			return nil, err
+		}
+		slog.Warn("Injecting faults into panel requests", "chaos", Chaos)
+		options.Base = transport
+		transport = chaos.NewTransport(options)
+	}
+	return transport, nil
+}
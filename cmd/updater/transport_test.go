@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+	"x-revalidate-bot/pkg/xserver/chaos"
+)
+
+func Test_newTransport(t *testing.T) {
+	tests := []struct {
+		profile string
+		chaos   string
+		wantNil bool
+		wantErr bool
+	}{
+		{profile: tlsProfileGo, wantNil: true},
+		{profile: tlsProfileChrome},
+		{profile: tlsProfileChrome, chaos: "latency=0.1"},
+		{profile: "firefox", wantErr: true},
+		{profile: tlsProfileGo, chaos: "bogus=1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.profile+" "+tt.chaos, func(t *testing.T) {
+			defer func(profile, chaos string) { TLSProfile, Chaos = profile, chaos }(TLSProfile, Chaos)
+			TLSProfile, Chaos = tt.profile, tt.chaos
+
+			transport, err := newTransport(nil)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if (transport == nil) != tt.wantNil {
+				t.Errorf("expected nil transport %v, got %T", tt.wantNil, transport)
+			}
+			if _, ok := transport.(*chaos.Transport); ok != (tt.chaos != "") {
+				t.Errorf("expected chaos transport %v, got %T", tt.chaos != "", transport)
+			}
+		})
+	}
+}
+
+func Test_newTransport_ChromeProxy(t *testing.T) {
+	defer func(profile string) { TLSProfile = profile }(TLSProfile)
+	TLSProfile = tlsProfileChrome
+	t.Setenv("HTTPS_PROXY", "http://proxy.example:3128")
+
+	if _, err := newTransport(&url.URL{Scheme: "https", Host: "secure.xserver.ne.jp"}); err == nil {
+		t.Error("expected the Chrome fingerprint to refuse a proxy")
+	}
+	t.Setenv("NO_PROXY", "secure.xserver.ne.jp")
+	if _, err := newTransport(&url.URL{Scheme: "https", Host: "secure.xserver.ne.jp"}); err != nil {
+		t.Errorf("unexpected error for a host excluded by NO_PROXY: %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime/debug"
+
+	"github.com/spf13/cobra"
+)
+
+// version is set at build time with -ldflags "-X main.version=v1.2.3".
+var version = "dev"
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+}
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the version and build information",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		info, _ := debug.ReadBuildInfo()
+		printVersion(cmd.OutOrStdout(), version, info)
+	},
+}
+
+func printVersion(w io.Writer, version string, info *debug.BuildInfo) {
+	fmt.Fprintf(w, "updater %s\n", version)
+	if info == nil {
+		return
+	}
+	fmt.Fprintf(w, "go: %s\n", info.GoVersion)
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision", "vcs.time", "vcs.modified":
+			fmt.Fprintf(w, "%s: %s\n", setting.Key, setting.Value)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"runtime/debug"
+	"testing"
+)
+
+func Test_printVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		info     *debug.BuildInfo
+		expected string
+	}{
+		{name: "No build info", expected: "updater v1.0.0\n"},
+		{
+			name: "VCS settings",
+			info: &debug.BuildInfo{GoVersion: "go1.24.4", Settings: []debug.BuildSetting{
+				{Key: "-trimpath", Value: "true"},
+				{Key: "vcs.revision", Value: "abc123"},
+				{Key: "vcs.modified", Value: "false"},
+			}},
+			expected: "updater v1.0.0\ngo: go1.24.4\nvcs.revision: abc123\nvcs.modified: false\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			printVersion(&buf, "v1.0.0", tt.info)
+			if buf.String() != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, buf.String())
+			}
+		})
+	}
+}
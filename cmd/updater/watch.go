@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchConfig calls reload whenever one of the files listed by paths changes,
+// until ctx is done. Editors often replace a file instead of writing it, so
+// the directories are watched, and events settle for debounce before a
+// reload. paths is listed again after every reload.
+func watchConfig(ctx context.Context, paths func() []string, reload func() error, debounce time.Duration) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	files := map[string]bool{}
+	dirs := map[string]bool{}
+	sync := func() {
+		clear(files)
+		wanted := map[string]bool{}
+		list := paths()
+		for _, path := range list {
+			files[filepath.Clean(path)] = true
+			wanted[filepath.Dir(path)] = true
+		}
+		for dir := range wanted {
+			if dirs[dir] {
+				continue
+			}
+			if err := watcher.Add(dir); err != nil {
+				slog.Warn("Error watching config directory", "error", err, "path", dir)
+				continue
+			}
+			dirs[dir] = true
+		}
+		for dir := range dirs {
+			if !wanted[dir] {
+				_ = watcher.Remove(dir)
+				delete(dirs, dir)
+			}
+		}
+		slog.Debug("Watching config files", "paths", list)
+	}
+	sync()
+
+	var settled <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !files[filepath.Clean(event.Name)] || event.Op == fsnotify.Chmod {
+				continue
+			}
+			slog.Debug("Config file event", "path", event.Name, "op", event.Op.String())
+			settled = time.After(debounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			slog.Warn("Error watching config files", "error", err)
+		case <-settled:
+			settled = nil
+			slog.Info("Config files changed, reloading")
+			if err := reload(); err != nil {
+				slog.Error("Rejected config change, keeping the current config", "error", err)
+			}
+			sync()
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_watchConfig(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config.yaml")
+	headers := filepath.Join(dir, "headers.json")
+	for _, path := range []string{config, headers} {
+		if err := os.WriteFile(path, []byte("{}"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reloads := make(chan struct{}, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- watchConfig(ctx, func() []string { return []string{config, headers} }, func() error {
+			reloads <- struct{}{}
+			return nil
+		}, 50*time.Millisecond)
+	}()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}()
+
+	// The watcher starts in the background, so keep touching the file until it reloads.
+	expectReload := func(change func(attempt int)) {
+		t.Helper()
+		for attempt := 0; attempt < 50; attempt++ {
+			change(attempt)
+			select {
+			case <-reloads:
+				return
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+		t.Fatal("expected a reload")
+	}
+	write := func(path string) func(int) {
+		return func(attempt int) {
+			if err := os.WriteFile(path, []byte{byte('0' + attempt%10)}, 0o600); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	expectReload(write(config))
+	expectReload(write(headers))
+	// Editors save by renaming a new file over the old one.
+	expectReload(func(int) {
+		tmp := filepath.Join(dir, ".config.yaml.swp")
+		if err := os.WriteFile(tmp, []byte("{}"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, config); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	// Other files of the directory are left alone, and bursts settle into one reload.
+	if err := os.WriteFile(filepath.Join(dir, "other.yaml"), []byte("{}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for attempt := range 5 {
+		write(config)(attempt)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if len(reloads) != 1 {
+		t.Errorf("expected one reload for a burst of writes, got %d", len(reloads))
+	}
+}
@@ -0,0 +1,158 @@
+package xserver
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+)
+
+var ErrInvalidAccount = errors.New("invalid account")
+
+// Account is one XServer login whose servers are renewed with its own session.
+type Account struct {
+	// Name identifies the account in logs and results. It must be unique within a pool.
+	Name      string
+	SessionID string
+	DeviceKey string
+	// Headers is the header profile sent by this account. Nil means the pool's default headers.
+	Headers map[string]string
+	// Proxy routes this account's requests through an HTTP or SOCKS5 proxy. Nil means no proxy.
+	Proxy *url.URL
+	// Navigation is how this account reaches the extend page. Nil means the pool's navigation.
+	Navigation *Navigation
+}
+
+// AccountPool holds one isolated client per account. Every client has its own
+// cookie jar and headers, so sessions never leak between accounts. Clients
+// share the transport of the pool's options, and with it its connections and
+// pacing, except accounts with a proxy, which get a copy routed through it.
+type AccountPool struct {
+	accounts []Account
+	clients  map[string]Client
+}
+
+// NewAccountPool creates a client for every account. options is the template
+// shared by all clients; its SessionID and DeviceKey are ignored.
+func NewAccountPool(accounts []Account, options ClientOptions) (*AccountPool, error) {
+	return newAccountPool(accounts, options, nil)
+}
+
+// Rebuild returns a pool of accounts that keeps the client of every account
+// unchanged since p, so its session and cookies survive a config reload.
+// options must be the ones p was created with; create a new pool when they
+// changed.
+func (p *AccountPool) Rebuild(accounts []Account, options ClientOptions) (*AccountPool, error) {
+	previous := make(map[string]Account, len(p.accounts))
+	for _, account := range p.accounts {
+		previous[account.Name] = account
+	}
+	return newAccountPool(accounts, options, func(account Account) Client {
+		if old, ok := previous[account.Name]; ok && old.equal(account) {
+			return p.clients[account.Name]
+		}
+		return nil
+	})
+}
+
+// newAccountPool is NewAccountPool taking the client of an account from reuse
+// when it returns one.
+func newAccountPool(accounts []Account, options ClientOptions, reuse func(Account) Client) (*AccountPool, error) {
+	if len(accounts) == 0 {
+		return nil, fmt.Errorf("%w: no accounts", ErrInvalidAccount)
+	}
+
+	pool := &AccountPool{
+		clients: make(map[string]Client, len(accounts)),
+	}
+	for _, account := range accounts {
+		if account.Name == "" {
+			return nil, fmt.Errorf("%w: name must not be empty", ErrInvalidAccount)
+		}
+		if _, ok := pool.clients[account.Name]; ok {
+			return nil, fmt.Errorf("%w: duplicate name %q", ErrInvalidAccount, account.Name)
+		}
+
+		var client Client
+		if reuse != nil {
+			client = reuse(account)
+		}
+		if client == nil {
+			var err error
+			if client, err = newAccountClient(account, options); err != nil {
+				return nil, fmt.Errorf("account %q: %w", account.Name, err)
+			}
+		}
+		pool.accounts = append(pool.accounts, account)
+		pool.clients[account.Name] = client
+	}
+	return pool, nil
+}
+
+func (a Account) equal(b Account) bool {
+	return a.Name == b.Name && a.SessionID == b.SessionID && a.DeviceKey == b.DeviceKey &&
+		(a.Headers == nil) == (b.Headers == nil) && maps.Equal(a.Headers, b.Headers) &&
+		(a.Proxy == nil) == (b.Proxy == nil) && (a.Proxy == nil || a.Proxy.String() == b.Proxy.String()) &&
+		(a.Navigation == nil) == (b.Navigation == nil) && (a.Navigation == nil || *a.Navigation == *b.Navigation)
+}
+
+func newAccountClient(account Account, options ClientOptions) (Client, error) {
+	options.SessionID = account.SessionID
+	options.DeviceKey = account.DeviceKey
+	if account.Headers != nil {
+		options.Headers = account.Headers
+	}
+	options.Headers = maps.Clone(options.Headers)
+	if account.Navigation != nil {
+		options.Navigation = account.Navigation
+	}
+	if options.Logger != nil {
+		options.Logger = options.Logger.With("account", account.Name)
+	}
+
+	if account.Proxy != nil {
+		transport, err := proxyTransport(options.Transport, account.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		options.Transport = transport
+	}
+	return NewClient(options)
+}
+
+// proxyTransport clones base, which must be an *http.Transport or nil, and
+// routes it through proxy.
+func proxyTransport(base http.RoundTripper, proxy *url.URL) (http.RoundTripper, error) {
+	switch proxy.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("%w: unsupported proxy scheme %q", ErrInvalidAccount, proxy.Scheme)
+	}
+
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if _, ok := base.(*chromeTransport); ok {
+		return nil, fmt.Errorf("%w: proxies are not supported with the Chrome TLS fingerprint", ErrInvalidAccount)
+	}
+	transport, ok := base.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf("%w: a proxy cannot be set on a %T transport", ErrInvalidAccount, base)
+	}
+	transport = transport.Clone()
+	transport.Proxy = http.ProxyURL(proxy)
+	return transport, nil
+}
+
+// Accounts returns the accounts in the order they were added.
+func (p *AccountPool) Accounts() []Account {
+	return slices.Clone(p.accounts)
+}
+
+// Client returns the client of the named account.
+func (p *AccountPool) Client(name string) (Client, bool) {
+	client, ok := p.clients[name]
+	return client, ok
+}
@@ -0,0 +1,176 @@
+package xserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+)
+
+// sessionEcho serves an extend page whose uniqid is the session cookie and user agent of the request.
+func sessionEcho(w http.ResponseWriter, r *http.Request) {
+	session, _ := r.Cookie("X2SESSID")
+	fmt.Fprintf(w, `<html><body><input type="hidden" name="uniqid" value="%s/%s" /></body></html>`, session.Value, r.UserAgent())
+}
+
+func Test_AccountPool(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(sessionEcho))
+	defer srv.Close()
+
+	var proxied atomic.Int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied.Add(1)
+		sessionEcho(w, r)
+	}))
+	defer proxy.Close()
+
+	base, _ := url.Parse(srv.URL)
+	proxyURL, _ := url.Parse(proxy.URL)
+	pool, err := NewAccountPool([]Account{
+		{Name: "alice", SessionID: "alice-session", DeviceKey: "alice-device"},
+		{Name: "bob", SessionID: "bob-session", DeviceKey: "bob-device", Headers: map[string]string{"User-Agent": "bob-agent"}},
+		{Name: "carol", SessionID: "carol-session", DeviceKey: "carol-device", Proxy: proxyURL},
+	}, ClientOptions{
+		Headers: map[string]string{"User-Agent": "default-agent"},
+		BaseURL: base,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		account  string
+		expected UniqueID
+	}{
+		{account: "alice", expected: "alice-session/default-agent"},
+		{account: "bob", expected: "bob-session/bob-agent"},
+		{account: "carol", expected: "carol-session/default-agent"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.account, func(t *testing.T) {
+			client, ok := pool.Client(tt.account)
+			if !ok {
+				t.Fatalf("client for %s not found", tt.account)
+			}
+			got, err := client.GetCSRFTokenAsUniqueID(context.Background(), "12345")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+
+	if proxied.Load() != 1 {
+		t.Errorf("expected one proxied request, got %d", proxied.Load())
+	}
+	if len(pool.Accounts()) != 3 || pool.Accounts()[2].Name != "carol" {
+		t.Errorf("unexpected accounts %v", pool.Accounts())
+	}
+	if _, ok := pool.Client("dave"); ok {
+		t.Error("expected no client for an unknown account")
+	}
+}
+
+func Test_AccountPool_Rebuild(t *testing.T) {
+	proxy, _ := url.Parse("http://127.0.0.1:3128")
+	options := ClientOptions{Headers: map[string]string{"User-Agent": "default-agent"}}
+	accounts := []Account{
+		{Name: "alice", SessionID: "alice-session", DeviceKey: "alice-device"},
+		{Name: "bob", SessionID: "bob-session", DeviceKey: "bob-device", Proxy: proxy},
+		{Name: "carol", SessionID: "carol-session", DeviceKey: "carol-device"},
+	}
+	pool, err := NewAccountPool(accounts, options)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sameProxy, _ := url.Parse("http://127.0.0.1:3128")
+	rebuilt, err := pool.Rebuild([]Account{
+		{Name: "alice", SessionID: "alice-session", DeviceKey: "alice-device"},
+		{Name: "bob", SessionID: "bob-session", DeviceKey: "bob-device", Proxy: sameProxy},
+		{Name: "carol", SessionID: "carol-session", DeviceKey: "carol-device", Headers: map[string]string{"User-Agent": "carol-agent"}},
+		{Name: "dave", SessionID: "dave-session", DeviceKey: "dave-device"},
+	}, options)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		account string
+		reused  bool
+	}{
+		{account: "alice", reused: true},
+		{account: "bob", reused: true},
+		{account: "carol", reused: false},
+		{account: "dave", reused: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.account, func(t *testing.T) {
+			before, _ := pool.Client(tt.account)
+			after, ok := rebuilt.Client(tt.account)
+			if !ok {
+				t.Fatalf("client for %s not found", tt.account)
+			}
+			if reused := before != nil && before == after; reused != tt.reused {
+				t.Errorf("expected reused %t, got %t", tt.reused, reused)
+			}
+		})
+	}
+	if len(rebuilt.Accounts()) != 4 {
+		t.Errorf("unexpected accounts %v", rebuilt.Accounts())
+	}
+}
+
+func Test_NewAccountPool_Invalid(t *testing.T) {
+	socks, _ := url.Parse("socks5://127.0.0.1:1080")
+	ftp, _ := url.Parse("ftp://127.0.0.1")
+
+	tests := []struct {
+		name     string
+		accounts []Account
+		options  ClientOptions
+		expected error
+	}{
+		{name: "No accounts", expected: ErrInvalidAccount},
+		{name: "Empty name", accounts: []Account{{SessionID: "s", DeviceKey: "d"}}, expected: ErrInvalidAccount},
+		{
+			name:     "Duplicate name",
+			accounts: []Account{{Name: "a", SessionID: "s", DeviceKey: "d"}, {Name: "a", SessionID: "s2", DeviceKey: "d2"}},
+			expected: ErrInvalidAccount,
+		},
+		{name: "Missing credentials", accounts: []Account{{Name: "a"}}, expected: ErrInvalidClientOptions},
+		{name: "Unsupported proxy", accounts: []Account{{Name: "a", SessionID: "s", DeviceKey: "d", Proxy: ftp}}, expected: ErrInvalidAccount},
+		{
+			name:     "Proxy on a custom transport",
+			accounts: []Account{{Name: "a", SessionID: "s", DeviceKey: "d", Proxy: socks}},
+			options:  ClientOptions{Transport: roundTripFunc(http.DefaultTransport.RoundTrip)},
+			expected: ErrInvalidAccount,
+		},
+		{
+			name:     "Proxy with the Chrome fingerprint",
+			accounts: []Account{{Name: "a", SessionID: "s", DeviceKey: "d", Proxy: socks}},
+			options:  ClientOptions{Transport: NewChromeTransport(ChromeTransportOptions{})},
+			expected: ErrInvalidAccount,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAccountPool(tt.accounts, tt.options)
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,127 @@
+package xserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BatchItem is one server to renew with the client of an account.
+type BatchItem struct {
+	Account string
+	VPSID   VPSID
+}
+
+type BatchOptions struct {
+	// Concurrency is how many servers of one account are renewed at once. Defaults to 1.
+	Concurrency int
+	// Renew renews item with the client of its account. Nil means Renew.
+	// Limit the request rate per host with a HostLimiter in the client's
+	// pacing instead.
+	Renew func(ctx context.Context, client Client, item BatchItem) error
+	// OnResult is called as soon as each item finishes, possibly concurrently.
+	OnResult func(BatchResult)
+}
+
+type BatchResult struct {
+	Item     BatchItem
+	Err      error
+	Duration time.Duration
+}
+
+// BatchSummary holds the results of a batch in the order of its items.
+type BatchSummary struct {
+	Results   []BatchResult
+	Succeeded int
+	Failed    int
+}
+
+// Err joins the errors of every failed item, or returns nil if all succeeded.
+func (s BatchSummary) Err() error {
+	var errs []error
+	for _, result := range s.Results {
+		if result.Err != nil {
+			errs = append(errs, fmt.Errorf("%s/%s: %w", result.Item.Account, result.Item.VPSID, result.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Renew fetches a fresh token for vpsID and submits the extend form.
+func Renew(ctx context.Context, client Client, vpsID VPSID) error {
+	uniqueID, err := client.GetCSRFTokenAsUniqueID(ctx, vpsID)
+	if err != nil {
+		return err
+	}
+	return client.ExtendFreeVPSExpiration(ctx, vpsID, uniqueID)
+}
+
+// RenewBatch renews every item with the client of its account. A failed item
+// does not stop the others; each one gets its own result.
+func (p *AccountPool) RenewBatch(ctx context.Context, items []BatchItem, options BatchOptions) BatchSummary {
+	if options.Concurrency <= 0 {
+		options.Concurrency = 1
+	}
+	if options.Renew == nil {
+		options.Renew = func(ctx context.Context, client Client, item BatchItem) error {
+			return Renew(ctx, client, item.VPSID)
+		}
+	}
+
+	slots := make(map[string]chan struct{}, len(p.accounts))
+	for _, account := range p.accounts {
+		slots[account.Name] = make(chan struct{}, options.Concurrency)
+	}
+
+	results := make([]BatchResult, len(items))
+	var wg sync.WaitGroup
+	for i, item := range items {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = p.renewItem(ctx, item, slots[item.Account], options.Renew)
+			if options.OnResult != nil {
+				options.OnResult(results[i])
+			}
+		}()
+	}
+	wg.Wait()
+
+	summary := BatchSummary{Results: results}
+	for _, result := range results {
+		if result.Err == nil {
+			summary.Succeeded++
+		} else {
+			summary.Failed++
+		}
+	}
+	return summary
+}
+
+func (p *AccountPool) renewItem(ctx context.Context, item BatchItem, slot chan struct{}, renew func(context.Context, Client, BatchItem) error) BatchResult {
+	result := BatchResult{Item: item}
+	client, ok := p.clients[item.Account]
+	if !ok {
+		result.Err = fmt.Errorf("%w: unknown account %q", ErrInvalidAccount, item.Account)
+		return result
+	}
+
+	if err := ctx.Err(); err != nil {
+		result.Err = err
+		return result
+	}
+	select {
+	case slot <- struct{}{}:
+		defer func() { <-slot }()
+	case <-ctx.Done():
+		result.Err = ctx.Err()
+		return result
+	}
+
+	start := time.Now()
+	result.Err = renew(ctx, client, item)
+	result.Duration = time.Since(start)
+	return result
+}
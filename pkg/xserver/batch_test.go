@@ -0,0 +1,100 @@
+package xserver
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_AccountPool_RenewBatch(t *testing.T) {
+	pool, err := NewAccountPool([]Account{
+		{Name: "alice", SessionID: "alice-session", DeviceKey: "alice-device"},
+		{Name: "bob", SessionID: "bob-session", DeviceKey: "bob-device"},
+	}, ClientOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice, _ := pool.Client("alice")
+
+	var mu sync.Mutex
+	running := map[Client]int{}
+	peak := map[Client]int{}
+	renew := func(ctx context.Context, client Client, item BatchItem) error {
+		if own, _ := pool.Client(item.Account); own != client {
+			t.Errorf("expected the client of %s for %s", item.Account, item.VPSID)
+		}
+		mu.Lock()
+		running[client]++
+		peak[client] = max(peak[client], running[client])
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		running[client]--
+		mu.Unlock()
+		if item.VPSID == "bad" {
+			return NewPanelError(ErrorCodeNotYetRenewable, "利用期限の更新はまだできません。")
+		}
+		return nil
+	}
+
+	items := []BatchItem{
+		{Account: "alice", VPSID: "1"},
+		{Account: "alice", VPSID: "2"},
+		{Account: "alice", VPSID: "bad"},
+		{Account: "alice", VPSID: "4"},
+		{Account: "bob", VPSID: "5"},
+		{Account: "carol", VPSID: "6"},
+	}
+	var reported int
+	summary := pool.RenewBatch(context.Background(), items, BatchOptions{
+		Concurrency: 2,
+		Renew:       renew,
+		OnResult: func(BatchResult) {
+			mu.Lock()
+			reported++
+			mu.Unlock()
+		},
+	})
+
+	if summary.Succeeded != 4 || summary.Failed != 2 {
+		t.Errorf("expected 4 succeeded and 2 failed, got %d and %d", summary.Succeeded, summary.Failed)
+	}
+	if reported != len(items) {
+		t.Errorf("expected %d reported results, got %d", len(items), reported)
+	}
+	for i, result := range summary.Results {
+		if result.Item != items[i] {
+			t.Errorf("result %d is for %v, expected %v", i, result.Item, items[i])
+		}
+	}
+	if ErrorCodeOf(summary.Results[2].Err) != ErrorCodeNotYetRenewable {
+		t.Errorf("expected not_yet_renewable, got %v", summary.Results[2].Err)
+	}
+	if !errors.Is(summary.Results[5].Err, ErrInvalidAccount) {
+		t.Errorf("expected ErrInvalidAccount for an unknown account, got %v", summary.Results[5].Err)
+	}
+	if peak[alice] != 2 {
+		t.Errorf("expected exactly 2 concurrent renewals for alice, got %d", peak[alice])
+	}
+	if err := summary.Err(); err == nil || !errors.Is(err, ErrInvalidAccount) {
+		t.Errorf("expected joined errors, got %v", err)
+	}
+}
+
+func Test_AccountPool_RenewBatch_Canceled(t *testing.T) {
+	pool, err := NewAccountPool([]Account{{Name: "alice", SessionID: "s", DeviceKey: "d"}}, ClientOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	summary := pool.RenewBatch(ctx, []BatchItem{{Account: "alice", VPSID: "1"}}, BatchOptions{})
+	if !errors.Is(summary.Results[0].Err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", summary.Results[0].Err)
+	}
+}
@@ -0,0 +1,267 @@
+// Package chaos injects network and server failures into panel requests for
+// resilience and soak testing.
+package chaos
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/transform"
+)
+
+// Fault is a kind of failure injected by Transport.
+type Fault string
+
+const (
+	FaultNone         Fault = "none"
+	FaultLatency      Fault = "latency"
+	FaultReset        Fault = "reset"
+	FaultServerError  Fault = "5xx"
+	FaultTruncate     Fault = "truncate"
+	FaultMisencode    Fault = "misencode"
+	FaultRedirectLoop Fault = "redirect"
+)
+
+var Faults = []Fault{
+	FaultLatency,
+	FaultReset,
+	FaultServerError,
+	FaultTruncate,
+	FaultMisencode,
+	FaultRedirectLoop,
+}
+
+type Options struct {
+	// Base sends requests that are not failed outright. Nil means http.DefaultTransport.
+	Base http.RoundTripper
+	// Script lists the faults for the first requests, in order. FaultNone
+	// passes a request through. Once the script runs out, Probabilities apply.
+	Script []Fault
+	// Probabilities is the chance of each fault per request, checked in the
+	// order of Faults.
+	Probabilities map[Fault]float64
+	// MaxLatency bounds the delay added by FaultLatency. Defaults to 2 seconds.
+	MaxLatency time.Duration
+	// Seed makes the random choices reproducible.
+	Seed uint64
+}
+
+// Transport is an http.RoundTripper that injects network and server
+// failures for resilience testing.
+type Transport struct {
+	options Options
+
+	mu       sync.Mutex
+	rand     *rand.Rand
+	script   []Fault
+	injected map[Fault]int
+}
+
+func NewTransport(options Options) *Transport {
+	if options.MaxLatency == 0 {
+		options.MaxLatency = 2 * time.Second
+	}
+	return &Transport{
+		options:  options,
+		rand:     rand.New(rand.NewPCG(options.Seed, options.Seed^0x9e3779b97f4a7c15)),
+		script:   slices.Clone(options.Script),
+		injected: map[Fault]int{},
+	}
+}
+
+// Injected returns how many times each fault was injected.
+func (c *Transport) Injected() map[Fault]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	injected := make(map[Fault]int, len(c.injected))
+	for fault, n := range c.injected {
+		injected[fault] = n
+	}
+	return injected
+}
+
+func (c *Transport) next() (Fault, time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fault := FaultNone
+	if len(c.script) > 0 {
+		fault, c.script = c.script[0], c.script[1:]
+	} else {
+		for _, candidate := range Faults {
+			if c.rand.Float64() < c.options.Probabilities[candidate] {
+				fault = candidate
+				break
+			}
+		}
+	}
+	c.injected[fault]++
+	return fault, time.Duration(c.rand.Int64N(int64(c.options.MaxLatency) + 1))
+}
+
+// redirectLoopParam marks the URLs a redirect loop sends the client to, so
+// following the redirect loops again.
+const redirectLoopParam = "chaos-redirect"
+
+func (c *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if hop := req.URL.Query().Get(redirectLoopParam); hop != "" {
+		return redirectLoop(req, hop), nil
+	}
+	fault, latency := c.next()
+
+	switch fault {
+	case FaultLatency:
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(latency):
+		}
+	case FaultReset:
+		return nil, &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+	case FaultServerError:
+		return syntheticResponse(req, http.StatusBadGateway, nil, "Bad Gateway"), nil
+	case FaultRedirectLoop:
+		return redirectLoop(req, "0"), nil
+	}
+
+	resp, err := c.base().RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch fault {
+	case FaultTruncate:
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body[:len(body)/2]), errReader{io.ErrUnexpectedEOF}))
+		resp.ContentLength = -1
+		resp.Header.Del("Content-Length")
+	case FaultMisencode:
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(misencode(body)))
+		resp.ContentLength = -1
+		resp.Header.Del("Content-Length")
+	}
+	return resp, nil
+}
+
+func (c *Transport) base() http.RoundTripper {
+	if c.options.Base == nil {
+		return http.DefaultTransport
+	}
+	return c.options.Base
+}
+
+// misencode reinterprets an EUC-JP body as Shift_JIS, the mojibake a panel
+// serving the wrong charset would produce.
+func misencode(body []byte) []byte {
+	decoded, _, err := transform.Bytes(japanese.ShiftJIS.NewDecoder(), body)
+	if err != nil {
+		return append([]byte{0xff, 0xfe}, body...)
+	}
+	return decoded
+}
+
+func redirectLoop(req *http.Request, hop string) *http.Response {
+	n, _ := strconv.Atoi(hop)
+	location := *req.URL
+	q := location.Query()
+	q.Set(redirectLoopParam, strconv.Itoa(n+1))
+	location.RawQuery = q.Encode()
+	header := http.Header{"Location": []string{location.String()}}
+	return syntheticResponse(req, http.StatusFound, header, "")
+}
+
+func syntheticResponse(req *http.Request, status int, header http.Header, body string) *http.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+// ParseSpec parses a comma-separated list of FAULT=PROBABILITY pairs, plus
+// optional seed=N, max-latency=DURATION and script=FAULT|FAULT|... entries,
+// for example "latency=0.2,reset=0.05,5xx=0.1,seed=42".
+func ParseSpec(spec string) (Options, error) {
+	options := Options{Probabilities: map[Fault]float64{}}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return Options{}, fmt.Errorf("invalid chaos entry %q: want KEY=VALUE", part)
+		}
+		switch key {
+		case "seed":
+			seed, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return Options{}, fmt.Errorf("invalid chaos seed %q: %w", value, err)
+			}
+			options.Seed = seed
+		case "max-latency":
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return Options{}, fmt.Errorf("invalid chaos max-latency %q: %w", value, err)
+			}
+			options.MaxLatency = d
+		case "script":
+			for _, name := range strings.Split(value, "|") {
+				fault := Fault(name)
+				if fault != FaultNone && !slices.Contains(Faults, fault) {
+					return Options{}, fmt.Errorf("unknown fault %q in chaos script", name)
+				}
+				options.Script = append(options.Script, fault)
+			}
+		default:
+			fault := Fault(key)
+			if !slices.Contains(Faults, fault) {
+				return Options{}, fmt.Errorf("unknown fault %q", key)
+			}
+			p, err := strconv.ParseFloat(value, 64)
+			if err != nil || p < 0 || p > 1 {
+				return Options{}, fmt.Errorf("invalid probability %q for %s: want 0..1", value, key)
+			}
+			options.Probabilities[fault] = p
+		}
+	}
+	return options, nil
+}
@@ -0,0 +1,150 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+	"x-revalidate-bot/pkg/xserver"
+	"x-revalidate-bot/pkg/xserver/xservertest"
+)
+
+func renew(ctx context.Context, xs xserver.Client, id xserver.VPSID) error {
+	uniqueID, err := xs.GetCSRFTokenAsUniqueID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return xs.ExtendFreeVPSExpiration(ctx, id, uniqueID)
+}
+
+func Test_Transport(t *testing.T) {
+	panel := xservertest.NewPanel(xservertest.PanelOptions{SessionID: "session", DeviceKey: "device"})
+	panel.AddServer("12345", time.Now().Add(time.Hour))
+	srv := httptest.NewServer(panel)
+	defer srv.Close()
+	base, _ := url.Parse(srv.URL)
+
+	tests := []struct {
+		name    string
+		script  []Fault
+		wantErr string
+		check   func(t *testing.T, err error)
+	}{
+		{
+			name:   "Latency only delays",
+			script: []Fault{FaultLatency, FaultLatency},
+		},
+		{
+			name:   "Connection reset",
+			script: []Fault{FaultReset},
+			check: func(t *testing.T, err error) {
+				if !errors.Is(err, syscall.ECONNRESET) {
+					t.Errorf("expected ECONNRESET, got %v", err)
+				}
+			},
+		},
+		{
+			name:    "Server error",
+			script:  []Fault{FaultServerError},
+			wantErr: "unexpected status code: 502",
+		},
+		{
+			name:    "Truncated body",
+			script:  []Fault{FaultTruncate},
+			wantErr: "unexpected EOF",
+		},
+		{
+			name:    "Redirect loop",
+			script:  []Fault{FaultRedirectLoop},
+			wantErr: "stopped after 10 redirects",
+		},
+		{
+			name:   "Mis-encoded renewal response",
+			script: []Fault{FaultNone, FaultMisencode},
+			check: func(t *testing.T, err error) {
+				if xserver.ErrorCodeOf(err) != xserver.ErrorCodeUnknown {
+					t.Errorf("expected unknown error code, got %v", err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chaos := NewTransport(Options{Script: tt.script, MaxLatency: 10 * time.Millisecond})
+			xs, err := xserver.NewClient(xserver.ClientOptions{
+				SessionID: "session",
+				DeviceKey: "device",
+				Logger:    slog.Default(),
+				BaseURL:   base,
+				Transport: chaos,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = renew(context.Background(), xs, "12345")
+			switch {
+			case tt.check != nil:
+				tt.check(t, err)
+			case tt.wantErr != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+			case err != nil:
+				t.Errorf("unexpected error: %v", err)
+			}
+			if chaos.Injected()[tt.script[0]] == 0 {
+				t.Errorf("expected %s to be injected", tt.script[0])
+			}
+		})
+	}
+}
+
+func Test_Transport_Probabilities(t *testing.T) {
+	newChaos := func() *Transport {
+		return NewTransport(Options{
+			Probabilities: map[Fault]float64{FaultServerError: 0.5},
+			Seed:          42,
+		})
+	}
+	a, b := newChaos(), newChaos()
+	for range 100 {
+		fa, _ := a.next()
+		fb, _ := b.next()
+		if fa != fb {
+			t.Fatal("expected the same seed to give the same faults")
+		}
+	}
+	injected := a.Injected()
+	if injected[FaultServerError] == 0 || injected[FaultNone] == 0 {
+		t.Errorf("expected a mix of faults, got %v", injected)
+	}
+}
+
+func Test_ParseSpec(t *testing.T) {
+	options, err := ParseSpec("latency=0.2, 5xx=0.1,seed=7,max-latency=500ms,script=reset|none")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if options.Probabilities[FaultLatency] != 0.2 || options.Probabilities[FaultServerError] != 0.1 {
+		t.Errorf("unexpected probabilities: %v", options.Probabilities)
+	}
+	if options.Seed != 7 || options.MaxLatency != 500*time.Millisecond {
+		t.Errorf("unexpected seed or latency: %d %v", options.Seed, options.MaxLatency)
+	}
+	if len(options.Script) != 2 || options.Script[0] != FaultReset || options.Script[1] != FaultNone {
+		t.Errorf("unexpected script: %v", options.Script)
+	}
+
+	for _, spec := range []string{"meteor=0.1", "reset=2", "reset", "script=reset|meteor", "seed=x"} {
+		if _, err := ParseSpec(spec); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+}
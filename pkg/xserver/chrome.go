@@ -0,0 +1,201 @@
+package xserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	utls "github.com/refraction-networking/utls"
+	"golang.org/x/net/http2"
+)
+
+type ChromeTransportOptions struct {
+	// RootCAs verifies server certificates. Nil means the system roots.
+	RootCAs *x509.CertPool
+}
+
+// NewChromeTransport returns a transport that looks like Chrome on the wire.
+// Its TLS ClientHello matches Chrome's: cipher suites, extensions and their
+// order, GREASE values and ALPN (h2, http/1.1). Over HTTP/2 it sends Chrome's
+// SETTINGS and connection WINDOW_UPDATE, HEADERS with Chrome's priority, and
+// the pseudo-headers and headers in Chrome's order. Pair it with a Chrome
+// header profile.
+//
+// HTTP/2 connections carry one request at a time and response bodies are
+// read whole before RoundTrip returns, which suits the panel pages but not
+// large downloads.
+//
+// Requests always go straight to the server: the transport cannot be used
+// with a proxy, and HTTPS_PROXY and friends are not consulted.
+func NewChromeTransport(options ChromeTransportOptions) http.RoundTripper {
+	t := &chromeTransport{
+		rootCAs:    options.RootCAs,
+		dialer:     &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+		http1Hosts: map[string]bool{},
+		idle:       map[string][]*h2Conn{},
+	}
+	t.h1 = &http.Transport{
+		DialContext: t.dialer.DialContext,
+		DialTLSContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return t.dialTLS(ctx, network, addr)
+		},
+		MaxIdleConns:        100,
+		IdleConnTimeout:     idleConnTimeout,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+	return t
+}
+
+const idleConnTimeout = 90 * time.Second
+
+// errHTTP1Only is returned by the HTTP/2 dialer when the server picked HTTP/1.1.
+var errHTTP1Only = errors.New("server does not support HTTP/2")
+
+type chromeTransport struct {
+	rootCAs *x509.CertPool
+	dialer  *net.Dialer
+	h1      *http.Transport
+
+	mu         sync.Mutex
+	http1Hosts map[string]bool
+	idle       map[string][]*h2Conn
+}
+
+func (t *chromeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	addr := canonicalAddr(req)
+	if req.URL.Scheme != "https" || t.isHTTP1(addr) {
+		return t.h1.RoundTrip(req)
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	for attempt := 0; ; attempt++ {
+		conn, reused, err := t.getConn(req.Context(), addr)
+		if errors.Is(err, errHTTP1Only) {
+			// Nothing was sent, so the request can go over HTTP/1.1.
+			req = req.Clone(req.Context())
+			if req.Body != nil {
+				req.Body = io.NopCloser(bytes.NewReader(body))
+			}
+			return t.h1.RoundTrip(req)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := conn.roundTrip(req, body)
+		t.putConn(addr, conn)
+		if err == nil {
+			return resp, nil
+		}
+		// A connection closed while idle is retried the way http.Transport
+		// does: always when the server says it did not process the request,
+		// and for GET and HEAD when it closed without answering.
+		idempotent := req.Method == "" || req.Method == http.MethodGet || req.Method == http.MethodHead
+		retry := errors.Is(err, errUnprocessed) || reused && idempotent && errors.Is(err, errNoResponse)
+		if attempt > 0 || !retry || req.Context().Err() != nil {
+			return nil, err
+		}
+	}
+}
+
+// getConn returns an idle HTTP/2 connection to addr or dials a new one.
+func (t *chromeTransport) getConn(ctx context.Context, addr string) (conn *h2Conn, reused bool, err error) {
+	t.mu.Lock()
+	for conns := t.idle[addr]; len(conns) > 0; conns = t.idle[addr] {
+		conn, t.idle[addr] = conns[len(conns)-1], conns[:len(conns)-1]
+		if time.Since(conn.idleSince) < idleConnTimeout {
+			t.mu.Unlock()
+			return conn, true, nil
+		}
+		conn.conn.Close()
+	}
+	t.mu.Unlock()
+
+	tlsConn, err := t.dialTLS(ctx, "tcp", addr)
+	if err != nil {
+		return nil, false, err
+	}
+	if tlsConn.ConnectionState().NegotiatedProtocol != http2.NextProtoTLS {
+		tlsConn.Close()
+		t.markHTTP1(addr)
+		return nil, false, errHTTP1Only
+	}
+	return newH2Conn(tlsConn), false, nil
+}
+
+// putConn keeps conn for the next request to addr, or closes it when it
+// cannot take one.
+func (t *chromeTransport) putConn(addr string, conn *h2Conn) {
+	if conn.closing || conn.nextStreamID > 1<<31-1 {
+		conn.conn.Close()
+		return
+	}
+	conn.idleSince = time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.idle[addr] = append(t.idle[addr], conn)
+}
+
+func (t *chromeTransport) dialTLS(ctx context.Context, network, addr string) (*utls.UConn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := t.dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	conn := utls.UClient(raw, &utls.Config{ServerName: host, RootCAs: t.rootCAs}, utls.HelloChrome_Auto)
+	if err := conn.HandshakeContext(ctx); err != nil {
+		raw.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (t *chromeTransport) markHTTP1(addr string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.http1Hosts[addr] = true
+}
+
+func (t *chromeTransport) isHTTP1(addr string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.http1Hosts[addr]
+}
+
+// canonicalAddr returns the host:port the transports dial for req.
+func canonicalAddr(req *http.Request) string {
+	if port := req.URL.Port(); port != "" {
+		return net.JoinHostPort(req.URL.Hostname(), port)
+	}
+	return net.JoinHostPort(req.URL.Hostname(), "443")
+}
+
+// CloseIdleConnections closes idle connections of both protocols.
+func (t *chromeTransport) CloseIdleConnections() {
+	t.h1.CloseIdleConnections()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for addr, conns := range t.idle {
+		for _, conn := range conns {
+			conn.conn.Close()
+		}
+		delete(t.idle, addr)
+	}
+}
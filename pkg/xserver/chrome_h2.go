@@ -0,0 +1,406 @@
+package xserver
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// The connection preface of Chrome 120 and later: its SETTINGS, in this
+// order, and the WINDOW_UPDATE growing the connection window to 15 MiB.
+var chromeSettings = []http2.Setting{
+	{ID: http2.SettingHeaderTableSize, Val: 65536},
+	{ID: http2.SettingEnablePush, Val: 0},
+	{ID: http2.SettingInitialWindowSize, Val: chromeStreamWindow},
+	{ID: http2.SettingMaxHeaderListSize, Val: 262144},
+}
+
+const (
+	chromeWindowUpdate = 15663105
+	chromeStreamWindow = 6291456
+	chromeConnWindow   = 65535 + chromeWindowUpdate
+)
+
+// chromePriority is the priority Chrome sends with the HEADERS of a document
+// request: exclusive on the root with weight 256.
+var chromePriority = http2.PriorityParam{StreamDep: 0, Exclusive: true, Weight: 255}
+
+// chromeHeaderOrder is the order Chrome sends the headers of navigations and
+// form posts in. Other headers follow in alphabetical order.
+var chromeHeaderOrder = []string{
+	"content-length",
+	"cache-control",
+	"sec-ch-ua",
+	"sec-ch-ua-mobile",
+	"sec-ch-ua-platform",
+	"origin",
+	"content-type",
+	"upgrade-insecure-requests",
+	"user-agent",
+	"accept",
+	"sec-fetch-site",
+	"sec-fetch-mode",
+	"sec-fetch-user",
+	"sec-fetch-dest",
+	"referer",
+	"accept-encoding",
+	"accept-language",
+	"cookie",
+	"priority",
+}
+
+// connectionHeaders are not allowed in HTTP/2 requests.
+var connectionHeaders = map[string]bool{
+	"connection":        true,
+	"host":              true,
+	"keep-alive":        true,
+	"proxy-connection":  true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+}
+
+// errUnprocessed is returned when the server did not process a request, so it
+// is safe to send again on another connection.
+var errUnprocessed = errors.New("http2: request was not processed by the server")
+
+// errNoResponse is returned when the connection closed before the server
+// answered. The server may be closing an idle connection, or may have failed
+// after processing the request.
+var errNoResponse = errors.New("http2: connection closed before the response")
+
+// h2Conn is an HTTP/2 client connection that frames requests the way Chrome
+// does. It carries one request at a time and buffers the response body, which
+// is enough for the panel pages.
+type h2Conn struct {
+	conn   net.Conn
+	bw     *bufio.Writer
+	framer *http2.Framer
+	hbuf   bytes.Buffer
+	henc   *hpack.Encoder
+
+	nextStreamID uint32
+	maxFrameSize uint32
+	// initialWindow is the send window of new streams set by the server.
+	initialWindow int32
+	sendWindow    int32
+	// unacked is the received data not yet given back with a WINDOW_UPDATE.
+	unacked int32
+	// closing is set once the connection must not take new requests.
+	closing   bool
+	idleSince time.Time
+}
+
+// newH2Conn writes Chrome's connection preface to conn. It is sent with the
+// first request.
+func newH2Conn(conn net.Conn) *h2Conn {
+	c := &h2Conn{
+		conn:          conn,
+		bw:            bufio.NewWriter(conn),
+		nextStreamID:  1,
+		maxFrameSize:  16384,
+		initialWindow: 65535,
+		sendWindow:    65535,
+	}
+	c.framer = http2.NewFramer(c.bw, bufio.NewReader(conn))
+	c.framer.ReadMetaHeaders = hpack.NewDecoder(65536, nil)
+	c.framer.MaxHeaderListSize = 262144
+	c.henc = hpack.NewEncoder(&c.hbuf)
+
+	c.bw.WriteString(http2.ClientPreface)
+	c.framer.WriteSettings(chromeSettings...)
+	c.framer.WriteWindowUpdate(0, chromeWindowUpdate)
+	return c
+}
+
+// h2Stream is the state of the request in flight.
+type h2Stream struct {
+	id         uint32
+	sendWindow int32
+	unacked    int32
+	// received is set once any frame of the stream arrived.
+	received bool
+	done     bool
+	status   int
+	header   http.Header
+	body     bytes.Buffer
+}
+
+// roundTrip sends req with body and reads the whole response. Any error
+// leaves the connection unusable.
+func (c *h2Conn) roundTrip(req *http.Request, body []byte) (*http.Response, error) {
+	ctx := req.Context()
+	stop := context.AfterFunc(ctx, func() { c.conn.SetDeadline(time.Unix(1, 0)) })
+	resp, err := c.exchange(req, body)
+	if !stop() {
+		c.closing = true
+		if err != nil {
+			err = ctx.Err()
+		}
+	}
+	if err != nil {
+		c.closing = true
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *h2Conn) exchange(req *http.Request, body []byte) (*http.Response, error) {
+	s := &h2Stream{id: c.nextStreamID, sendWindow: c.initialWindow}
+	c.nextStreamID += 2
+
+	fields, gzipped := chromeHeaderFields(req, body)
+	c.hbuf.Reset()
+	for _, field := range fields {
+		c.henc.WriteField(field)
+	}
+	if err := c.writeHeaders(s.id, c.hbuf.Bytes(), len(body) == 0); err != nil {
+		return nil, err
+	}
+
+	for len(body) > 0 && !s.done {
+		n := min(len(body), int(c.maxFrameSize), int(s.sendWindow), int(c.sendWindow))
+		if n <= 0 {
+			if err := c.bw.Flush(); err != nil {
+				return nil, err
+			}
+			if err := c.readFrame(s); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := c.framer.WriteData(s.id, n == len(body), body[:n]); err != nil {
+			return nil, err
+		}
+		s.sendWindow -= int32(n)
+		c.sendWindow -= int32(n)
+		body = body[n:]
+	}
+	if len(body) > 0 {
+		// The server answered before reading the whole body.
+		c.framer.WriteRSTStream(s.id, http2.ErrCodeNo)
+	}
+	if err := c.bw.Flush(); err != nil {
+		return nil, fmt.Errorf("%w: %w", errNoResponse, err)
+	}
+
+	for !s.done {
+		if err := c.readFrame(s); err != nil {
+			return nil, err
+		}
+	}
+	return s.response(req, gzipped)
+}
+
+// writeHeaders writes a HEADERS frame with Chrome's priority, continued in
+// CONTINUATION frames when block does not fit.
+func (c *h2Conn) writeHeaders(streamID uint32, block []byte, endStream bool) error {
+	first := block[:min(len(block), int(c.maxFrameSize))]
+	block = block[len(first):]
+	err := c.framer.WriteHeaders(http2.HeadersFrameParam{
+		StreamID:      streamID,
+		BlockFragment: first,
+		EndStream:     endStream,
+		EndHeaders:    len(block) == 0,
+		Priority:      chromePriority,
+	})
+	for err == nil && len(block) > 0 {
+		chunk := block[:min(len(block), int(c.maxFrameSize))]
+		block = block[len(chunk):]
+		err = c.framer.WriteContinuation(streamID, len(block) == 0, chunk)
+	}
+	return err
+}
+
+// readFrame reads and handles one frame for the connection or s.
+func (c *h2Conn) readFrame(s *h2Stream) error {
+	frame, err := c.framer.ReadFrame()
+	if err != nil {
+		if !s.received && (errors.Is(err, io.EOF) || errors.Is(err, syscall.ECONNRESET)) {
+			return errNoResponse
+		}
+		return err
+	}
+	if frame.Header().StreamID == s.id {
+		s.received = true
+	}
+
+	switch f := frame.(type) {
+	case *http2.SettingsFrame:
+		if f.IsAck() {
+			return nil
+		}
+		f.ForeachSetting(func(setting http2.Setting) error {
+			switch setting.ID {
+			case http2.SettingHeaderTableSize:
+				c.henc.SetMaxDynamicTableSizeLimit(setting.Val)
+			case http2.SettingInitialWindowSize:
+				s.sendWindow += int32(setting.Val) - c.initialWindow
+				c.initialWindow = int32(setting.Val)
+			case http2.SettingMaxFrameSize:
+				c.maxFrameSize = setting.Val
+			}
+			return nil
+		})
+		c.framer.WriteSettingsAck()
+	case *http2.PingFrame:
+		if f.IsAck() {
+			return nil
+		}
+		c.framer.WritePing(true, f.Data)
+	case *http2.WindowUpdateFrame:
+		switch f.StreamID {
+		case 0:
+			c.sendWindow += int32(f.Increment)
+		case s.id:
+			s.sendWindow += int32(f.Increment)
+		}
+		return nil
+	case *http2.GoAwayFrame:
+		c.closing = true
+		if f.LastStreamID < s.id {
+			return fmt.Errorf("%w: GOAWAY %v", errUnprocessed, f.ErrCode)
+		}
+		return nil
+	case *http2.RSTStreamFrame:
+		if f.StreamID != s.id {
+			return nil
+		}
+		if f.ErrCode == http2.ErrCodeRefusedStream {
+			return fmt.Errorf("%w: stream refused", errUnprocessed)
+		}
+		return fmt.Errorf("http2: stream reset by the server: %v", f.ErrCode)
+	case *http2.MetaHeadersFrame:
+		if f.StreamID != s.id {
+			return nil
+		}
+		if s.header == nil {
+			status, err := strconv.Atoi(f.PseudoValue("status"))
+			if err != nil {
+				return fmt.Errorf("http2: malformed response status %q", f.PseudoValue("status"))
+			}
+			// Informational responses precede the real one.
+			if status >= 200 {
+				s.status = status
+				s.header = make(http.Header, len(f.Fields))
+				for _, field := range f.RegularFields() {
+					s.header.Add(http.CanonicalHeaderKey(field.Name), field.Value)
+				}
+			}
+		}
+		s.done = f.StreamEnded()
+		return nil
+	case *http2.DataFrame:
+		// Padding counts against the windows too.
+		length := int32(f.Header().Length)
+		if c.unacked += length; c.unacked >= chromeConnWindow/2 {
+			c.framer.WriteWindowUpdate(0, uint32(c.unacked))
+			c.unacked = 0
+		}
+		if f.StreamID == s.id {
+			if s.header == nil {
+				return errors.New("http2: DATA before the response headers")
+			}
+			s.body.Write(f.Data())
+			s.done = f.StreamEnded()
+			if s.unacked += length; !s.done && s.unacked >= chromeStreamWindow/2 {
+				c.framer.WriteWindowUpdate(s.id, uint32(s.unacked))
+				s.unacked = 0
+			}
+		}
+	default:
+		return nil
+	}
+	return c.bw.Flush()
+}
+
+func (s *h2Stream) response(req *http.Request, gzipped bool) (*http.Response, error) {
+	if s.header == nil {
+		return nil, errors.New("http2: stream ended without a response")
+	}
+	body := s.body.Bytes()
+	resp := &http.Response{
+		Status:        strconv.Itoa(s.status) + " " + http.StatusText(s.status),
+		StatusCode:    s.status,
+		Proto:         "HTTP/2.0",
+		ProtoMajor:    2,
+		Header:        s.header,
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+	if gzipped && len(body) > 0 && strings.EqualFold(s.header.Get("Content-Encoding"), "gzip") {
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if body, err = io.ReadAll(zr); err != nil {
+			return nil, err
+		}
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = int64(len(body))
+		resp.Uncompressed = true
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// chromeHeaderFields returns the header block of req: the pseudo-headers in
+// Chrome's order, :method, :authority, :scheme, :path, then the headers in
+// chromeHeaderOrder. Like http.Transport, it asks for gzip when req does not
+// pick an encoding, and reports so the response can be decompressed.
+func chromeHeaderFields(req *http.Request, body []byte) (fields []hpack.HeaderField, gzipped bool) {
+	fields = []hpack.HeaderField{
+		{Name: ":method", Value: cmp.Or(req.Method, http.MethodGet)},
+		{Name: ":authority", Value: cmp.Or(req.Host, req.URL.Host)},
+		{Name: ":scheme", Value: "https"},
+		{Name: ":path", Value: req.URL.RequestURI()},
+	}
+
+	header := make(map[string][]string, len(req.Header)+2)
+	for key, values := range req.Header {
+		// Header profiles blank out the headers left to the transport.
+		values = slices.DeleteFunc(slices.Clone(values), func(v string) bool { return v == "" })
+		if name := strings.ToLower(key); !connectionHeaders[name] && len(values) > 0 {
+			header[name] = values
+		}
+	}
+	if len(body) > 0 || req.Method == http.MethodPost || req.Method == http.MethodPut || req.Method == http.MethodPatch {
+		header["content-length"] = []string{strconv.Itoa(len(body))}
+	}
+	if _, ok := header["accept-encoding"]; !ok && req.Method != http.MethodHead {
+		header["accept-encoding"] = []string{"gzip"}
+		gzipped = true
+	}
+
+	rank := func(name string) int {
+		if i := slices.Index(chromeHeaderOrder, name); i >= 0 {
+			return i
+		}
+		return len(chromeHeaderOrder)
+	}
+	names := slices.SortedFunc(maps.Keys(header), func(a, b string) int {
+		return cmp.Or(cmp.Compare(rank(a), rank(b)), strings.Compare(a, b))
+	})
+	for _, name := range names {
+		for _, value := range header[name] {
+			fields = append(fields, hpack.HeaderField{Name: name, Value: value})
+		}
+	}
+	return fields, gzipped
+}
@@ -0,0 +1,129 @@
+package xserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func isGREASE(v uint16) bool {
+	return v&0x0f0f == 0x0a0a && v>>8 == v&0xff
+}
+
+func Test_ChromeTransport(t *testing.T) {
+	tests := []struct {
+		name      string
+		http2     bool
+		body      string
+		wantProto string
+	}{
+		{name: "HTTP/2", http2: true, body: "form", wantProto: "HTTP/2.0"},
+		// Larger than the flow control windows of both sides.
+		{name: "HTTP/2 flow control", http2: true, body: strings.Repeat("form", 4<<20), wantProto: "HTTP/2.0"},
+		{name: "HTTP/1.1 fallback", http2: false, body: "form", wantProto: "HTTP/1.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var hello *tls.ClientHelloInfo
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				w.Write([]byte(r.Proto + " " + string(body)))
+			}))
+			srv.EnableHTTP2 = tt.http2
+			srv.TLS = &tls.Config{GetConfigForClient: func(info *tls.ClientHelloInfo) (*tls.Config, error) {
+				mu.Lock()
+				hello = info
+				mu.Unlock()
+				return nil, nil
+			}}
+			srv.StartTLS()
+			defer srv.Close()
+
+			roots := x509.NewCertPool()
+			roots.AddCert(srv.Certificate())
+			client := &http.Client{Transport: NewChromeTransport(ChromeTransportOptions{RootCAs: roots})}
+
+			for range 2 {
+				resp, err := client.Post(srv.URL, "text/plain", strings.NewReader(tt.body))
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				body, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				if resp.Proto != tt.wantProto || string(body) != tt.wantProto+" "+tt.body {
+					t.Errorf("expected %s with the body echoed, got %s and %d bytes", tt.wantProto, resp.Proto, len(body))
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if hello == nil {
+				t.Fatal("no ClientHello was recorded")
+			}
+			if !slices.Equal(hello.SupportedProtos, []string{"h2", "http/1.1"}) {
+				t.Errorf("expected ALPN h2, http/1.1, got %v", hello.SupportedProtos)
+			}
+			if len(hello.CipherSuites) == 0 || !isGREASE(hello.CipherSuites[0]) {
+				t.Errorf("expected a GREASE cipher suite first, got %x", hello.CipherSuites)
+			}
+			if !slices.ContainsFunc(hello.Extensions, isGREASE) {
+				t.Errorf("expected GREASE extensions, got %x", hello.Extensions)
+			}
+		})
+	}
+}
+
+func Test_ChromeTransport_ClosedConnection(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	client := &http.Client{Transport: NewChromeTransport(ChromeTransportOptions{RootCAs: roots})}
+	for i := range 2 {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("request %d: unexpected error: %v", i, err)
+		}
+		resp.Body.Close()
+		// The idle connection is gone by the second request, which is sent
+		// again on a new one.
+		srv.CloseClientConnections()
+	}
+}
+
+func Test_chromeHeaderFields(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "https://secure.xserver.ne.jp/xapanel/login", strings.NewReader("a=b"))
+	for _, key := range []string{"User-Agent", "X-Custom", "Cookie", "Sec-Fetch-Site", "Content-Type", "Origin", "Sec-Ch-Ua", "Connection", "Accept-Encoding"} {
+		req.Header.Set(key, "v")
+	}
+	req.Header.Set("Accept-Encoding", "")
+
+	fields, gzipped := chromeHeaderFields(req, []byte("a=b"))
+	var names []string
+	for _, field := range fields {
+		names = append(names, field.Name)
+	}
+	want := []string{
+		":method", ":authority", ":scheme", ":path",
+		"content-length", "sec-ch-ua", "origin", "content-type", "user-agent", "sec-fetch-site", "accept-encoding", "cookie", "x-custom",
+	}
+	if !slices.Equal(names, want) {
+		t.Errorf("expected headers %v, got %v", want, names)
+	}
+	if !gzipped || fields[10].Value != "gzip" {
+		t.Errorf("expected gzip to be asked for in place of the blank Accept-Encoding, got %q", fields[10].Value)
+	}
+}
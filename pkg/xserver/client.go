@@ -11,8 +11,11 @@ import (
 	"net/url"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/PuerkitoBio/goquery"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/text/encoding/japanese"
 	"golang.org/x/text/transform"
 )
@@ -23,13 +26,22 @@ const (
 
 var (
 	ErrInvalidClientOptions = fmt.Errorf("invalid client options: sessionID and deviceKey must not be empty")
+	ErrLoginRequired        = fmt.Errorf("session is not logged in: the panel returned the login page")
 )
 
 type Client interface {
 	// GetCSRFTokenAsUniqueID retrieves the unique ID for a given VPS ID to be used in extending the VPS expiration.
 	GetCSRFTokenAsUniqueID(ctx context.Context, vpsID VPSID) (UniqueID, error)
+	// GetRenewalForm reads the unique ID and, when shown, the expiry of a VPS from one fetch of its extend page.
+	GetRenewalForm(ctx context.Context, vpsID VPSID) (RenewalForm, error)
 	// ExtendFreeVPSExpiration extends the expiration of a free VPS.
 	ExtendFreeVPSExpiration(ctx context.Context, vpsID VPSID, uniqueID UniqueID) error
+	// CheckPanel fetches the extend page without submitting anything and compares its structure to the baseline.
+	CheckPanel(ctx context.Context, vpsID VPSID) (DriftReport, error)
+	// GetServerStatus reads the current expiry of a VPS from its extend page.
+	GetServerStatus(ctx context.Context, vpsID VPSID) (ServerStatus, error)
+	// ListServers lists the servers on the panel top page.
+	ListServers(ctx context.Context) ([]Server, error)
 }
 
 type ClientOptions struct {
@@ -37,12 +49,43 @@ type ClientOptions struct {
 	DeviceKey string
 	Headers   map[string]string
 	Logger    *slog.Logger
+	// PanelSchema overrides the embedded selectors and phrases. Nil means DefaultPanelSchema.
+	PanelSchema *PanelSchema
+	// PanelBaseline is compared against the fingerprint of every parsed page.
+	PanelBaseline PanelBaseline
+	// OnDrift is called when a parsed page no longer matches PanelBaseline.
+	OnDrift func(DriftReport)
+	// PageDumpDir is where maintenance and challenge pages are saved. Empty disables saving.
+	PageDumpDir string
+	// TracerProvider creates spans for every method and HTTP request. Nil means the global provider.
+	TracerProvider trace.TracerProvider
+	// Hooks run around every request, in order.
+	Hooks []Hooks
+	// BaseURL points the client at another panel origin, such as a fake panel in tests.
+	BaseURL *url.URL
+	// Transport sends the HTTP requests. Nil means http.DefaultTransport.
+	Transport http.RoundTripper
+	// Pacing spaces out navigations. Nil sends requests as soon as they are made.
+	Pacing *Pacing
+	// Navigation visits the top and server pages before the extend page. Nil
+	// requests the extend page directly.
+	Navigation *Navigation
 }
 
 type client struct {
-	Logger  *slog.Logger
-	Client  *http.Client
-	Headers map[string]string
+	Logger   *slog.Logger
+	Client   *http.Client
+	Headers  map[string]string
+	Schema   *PanelSchema
+	Baseline PanelBaseline
+	OnDrift  func(DriftReport)
+
+	PageDumpDir string
+	Tracer      trace.Tracer
+	Hooks       []Hooks
+	BaseURL     *url.URL
+	Pacer       *pacer
+	Navigation  *Navigation
 }
 
 var _ Client = (*client)(nil)
@@ -54,29 +97,66 @@ func NewClient(options ClientOptions) (Client, error) {
 	if options.Logger == nil {
 		options.Logger = slog.Default()
 	}
+	if options.PanelSchema == nil {
+		options.PanelSchema = DefaultPanelSchema()
+	} else {
+		if err := options.PanelSchema.Validate(); err != nil {
+			return nil, err
+		}
+		options.PanelSchema = options.PanelSchema.clone()
+		options.PanelSchema.compile()
+	}
 
 	// Set credentials
 	jar, err := cookiejar.New(nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create cookie jar: %w", err)
 	}
-	jar.SetCookies(&url.URL{
-		Scheme: "https",
-		Host:   XServerHost,
-	}, []*http.Cookie{
+	if options.BaseURL == nil {
+		options.BaseURL = DefaultBaseURL
+	}
+	cookies := []*http.Cookie{
 		newCookie("X2SESSID", options.SessionID),
 		newCookie("XSERVER_DEVICEKEY", options.DeviceKey),
-	})
+	}
+	if options.BaseURL.Hostname() != XServerHost {
+		for _, cookie := range cookies {
+			cookie.Domain = ""
+			cookie.Secure = options.BaseURL.Scheme == "https"
+		}
+	}
+	jar.SetCookies(options.BaseURL, cookies)
+
+	if options.TracerProvider == nil {
+		options.TracerProvider = otel.GetTracerProvider()
+	}
+	tracer := options.TracerProvider.Tracer(tracerName)
 
 	// Create HTTP client with the cookie jar
 	httpClient := &http.Client{
-		Jar: jar,
+		Jar:       jar,
+		Transport: &tracingTransport{Base: options.Transport, Tracer: tracer},
+	}
+
+	var p *pacer
+	if options.Pacing != nil {
+		p = &pacer{Pacing: *options.Pacing}
 	}
 
 	return &client{
-		Client:  httpClient,
-		Logger:  options.Logger,
-		Headers: options.Headers,
+		Client:   httpClient,
+		Logger:   options.Logger,
+		Headers:  options.Headers,
+		Schema:   options.PanelSchema,
+		Baseline: options.PanelBaseline,
+		OnDrift:  options.OnDrift,
+
+		PageDumpDir: options.PageDumpDir,
+		Tracer:      tracer,
+		Hooks:       options.Hooks,
+		BaseURL:     options.BaseURL,
+		Pacer:       p,
+		Navigation:  options.Navigation,
 	}, nil
 }
 
@@ -90,41 +170,172 @@ func newCookie(name, value string) *http.Cookie {
 	}
 }
 
-func (c *client) GetCSRFTokenAsUniqueID(ctx context.Context, vpsID VPSID) (UniqueID, error) {
+func (c *client) GetCSRFTokenAsUniqueID(ctx context.Context, vpsID VPSID) (_ UniqueID, err error) {
+	ctx, info, end := c.startOperation(ctx, OperationGetCSRFToken, vpsID)
+	defer func() { end(err) }()
+
 	c.Logger.Info("Retrieving CSRF token for VPS ID", "vpsID", vpsID)
+	doc, err := c.renewalPage(ctx, info, vpsID)
+	if err != nil {
+		return UniqueID(""), err
+	}
+
+	c.Logger.Debug("Parsing response to find unique ID")
+	uniqueID, err := findUniqueIdInDocument(c.panelSchema(), doc)
+	if err != nil {
+		return UniqueID(""), err
+	}
+	c.parsed(ctx, info, uniqueID)
+	return uniqueID, nil
+}
+
+// RenewalForm is what the extend page of a VPS shows before renewing it.
+type RenewalForm struct {
+	VPSID    VPSID
+	UniqueID UniqueID
+	// Expiry is zero when the page does not show it.
+	Expiry time.Time
+}
+
+func (c *client) GetRenewalForm(ctx context.Context, vpsID VPSID) (_ RenewalForm, err error) {
+	ctx, info, end := c.startOperation(ctx, OperationGetRenewalForm, vpsID)
+	defer func() { end(err) }()
+
+	c.Logger.Info("Reading renewal form for VPS ID", "vpsID", vpsID)
+	doc, err := c.renewalPage(ctx, info, vpsID)
+	if err != nil {
+		return RenewalForm{}, err
+	}
+
+	schema := c.panelSchema()
+	uniqueID, err := findUniqueIdInDocument(schema, doc)
+	if err != nil {
+		return RenewalForm{}, err
+	}
+	form := RenewalForm{VPSID: vpsID, UniqueID: uniqueID}
+	if schema.Selectors.Expiry != "" {
+		form.Expiry, _ = schema.parseExpiry(doc.Find(schema.Selectors.Expiry).First().Text())
+	}
+	c.parsed(ctx, info, form)
+	return form, nil
+}
+
+// renewalPage fetches the extend page of vpsID the way a renewal does, through
+// the panel when navigating.
+func (c *client) renewalPage(ctx context.Context, info HookInfo, vpsID VPSID) (*goquery.Document, error) {
+	target, referer := freeVPSExtendURL(c.baseURL(), vpsID), (*url.URL)(nil)
+	if c.Navigation != nil {
+		var err error
+		target, referer, err = c.navigateToExtendPage(ctx, info)
+		if err != nil {
+			return nil, err
+		}
+	}
+	doc, report, err := c.fetchExtendPage(ctx, info, target, referer)
+	if err != nil {
+		return nil, err
+	}
+	c.reportDrift(report)
+	return doc, nil
+}
+
+func (c *client) CheckPanel(ctx context.Context, vpsID VPSID) (_ DriftReport, err error) {
+	ctx, info, end := c.startOperation(ctx, OperationCheckPanel, vpsID)
+	defer func() { end(err) }()
+
+	c.Logger.Info("Checking panel page structure", "vpsID", vpsID)
+	_, report, err := c.fetchExtendPage(ctx, info, freeVPSExtendURL(c.baseURL(), vpsID), nil)
+	if err != nil {
+		return DriftReport{}, err
+	}
+	c.reportDrift(report)
+	c.parsed(ctx, info, report)
+	return report, nil
+}
+
+func (c *client) fetchExtendPage(ctx context.Context, info HookInfo, target, referer *url.URL) (*goquery.Document, DriftReport, error) {
+	// Pace before the timeout starts, so think time does not eat into it.
+	if err := c.Pacer.wait(ctx, c.baseURL().Host); err != nil {
+		return nil, DriftReport{}, err
+	}
 	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, FreeVPSExtendURL(vpsID).String(), nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
 	if err != nil {
-		return UniqueID(""), fmt.Errorf("failed to create request: %w", err)
-	}
-	for key, value := range c.Headers {
-		req.Header.Set(key, value)
+		return nil, DriftReport{}, fmt.Errorf("failed to create request: %w", err)
 	}
+	c.setHeaders(req, referer)
 
 	c.Logger.Debug("Sending request to get CSRF token", "url", req.URL.String())
-	resp, err := c.Client.Do(req)
+	resp, err := c.send(info, req)
 	if err != nil {
-		return UniqueID(""), fmt.Errorf("failed to get CSRF token: %w", err)
+		return nil, DriftReport{}, fmt.Errorf("failed to get CSRF token: %w", err)
 	}
 	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, DriftReport{}, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	schema := c.panelSchema()
+	content := decodeEUCJP(string(body))
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return nil, DriftReport{}, fmt.Errorf("failed to parse response body: %w", err)
+	}
+	if err := c.detectInterstitial(resp.StatusCode, body, content, doc); err != nil {
+		return nil, DriftReport{}, err
+	}
 	if resp.StatusCode != http.StatusOK {
-		return UniqueID(""), fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, DriftReport{}, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	if schema.isLoginPage(doc) {
+		return nil, DriftReport{}, ErrLoginRequired
 	}
 
-	c.Logger.Debug("Parsing response to find unique ID")
-	return findUniqueIdInResponse(resp.Body)
+	report := c.Baseline.Compare(computeFingerprint(PageExtendIndex, schema, doc))
+	return doc, report, nil
+}
+
+func (c *client) reportDrift(report DriftReport) {
+	if !report.Drifted() {
+		return
+	}
+	c.Logger.Warn("Panel page structure differs from baseline", "page", report.Page, "changes", report.Changes)
+	if c.OnDrift != nil {
+		c.OnDrift(report)
+	}
 }
 
-func findUniqueIdInResponse(body io.Reader) (UniqueID, error) {
+func (c *client) baseURL() *url.URL {
+	if c.BaseURL == nil {
+		return DefaultBaseURL
+	}
+	return c.BaseURL
+}
+
+func (c *client) panelSchema() *PanelSchema {
+	if c.Schema == nil {
+		return defaultPanelSchema
+	}
+	return c.Schema
+}
+
+func findUniqueIdInResponse(schema *PanelSchema, body io.Reader) (UniqueID, error) {
 	doc, err := goquery.NewDocumentFromReader(body)
 	if err != nil {
 		return UniqueID(""), fmt.Errorf("failed to parse response body: %w", err)
 	}
+	if schema.isLoginPage(doc) {
+		return UniqueID(""), ErrLoginRequired
+	}
+	return findUniqueIdInDocument(schema, doc)
+}
 
+func findUniqueIdInDocument(schema *PanelSchema, doc *goquery.Document) (UniqueID, error) {
 	var uniqid string
-	doc.Find("input[type=hidden][name=uniqid]").Each(func(i int, s *goquery.Selection) {
+	doc.Find(schema.Selectors.UniqueID).Each(func(i int, s *goquery.Selection) {
 		id, exists := s.Attr("value")
 		if exists {
 			uniqid = id
@@ -137,73 +348,106 @@ func findUniqueIdInResponse(body io.Reader) (UniqueID, error) {
 	return UniqueID(uniqid), nil
 }
 
-func (c *client) ExtendFreeVPSExpiration(ctx context.Context, vpsID VPSID, uniqueID UniqueID) error {
+// ExtendForm returns the form body ExtendFreeVPSExpiration posts.
+func ExtendForm(vpsID VPSID, uniqueID UniqueID) string {
+	return fmt.Sprintf("uniqid=%s&ethna_csrf=&id_vps=%s", uniqueID, vpsID)
+}
+
+func (c *client) ExtendFreeVPSExpiration(ctx context.Context, vpsID VPSID, uniqueID UniqueID) (err error) {
+	ctx, info, end := c.startOperation(ctx, OperationExtend, vpsID)
+	defer func() { end(err) }()
+
 	c.Logger.Info("Extending free VPS expiration", "vpsID", vpsID, "uniqueID", uniqueID)
+	if err := c.Pacer.wait(ctx, c.baseURL().Host); err != nil {
+		return err
+	}
 	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
 	defer cancel()
 
-	formData := fmt.Sprintf("uniqid=%s&ethna_csrf=&id_vps=%s", uniqueID, vpsID)
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, doFreeVPSExtendURL.String(), strings.NewReader(formData))
+	formData := ExtendForm(vpsID, uniqueID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, doFreeVPSExtendURL(c.baseURL()).String(), strings.NewReader(formData))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
-	for key, value := range c.Headers {
-		req.Header.Set(key, value)
+	if c.Navigation != nil {
+		// The form is submitted from the extend page.
+		c.setHeaders(req, freeVPSExtendURL(c.baseURL(), vpsID))
+		req.Header.Set("Origin", c.baseURL().Scheme+"://"+c.baseURL().Host)
+	} else {
+		c.setHeaders(req, nil)
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	c.Logger.Debug("Sending request to extend VPS expiration", "url", req.URL.String(), "formData", formData)
-	resp, err := c.Client.Do(req)
+	resp, err := c.send(info, req)
 	if err != nil {
 		return fmt.Errorf("failed to extend VPS expiration: %w", err)
 	}
 	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, body)
-	}
-
-	c.Logger.Debug("Parsing response to confirm extension")
 	body, _ := io.ReadAll(resp.Body)
 	translated := translate(string(body))
-	if strings.Contains(translated, "利用期限の更新手続きが完了しました。") {
+	schema := c.panelSchema()
+	if resp.StatusCode == http.StatusOK && schema.isSuccess(translated) {
 		c.Logger.Info("VPS expiration extended successfully", "vpsID", vpsID, "uniqueID", uniqueID)
+		c.parsed(ctx, info, ExtendResult{VPSID: vpsID})
 		return nil
 	}
-	errorMessages, err := findErrorMessageFromResponse(strings.NewReader(translated))
+
+	// A known failure is reported as is, even when the page also carries a
+	// notice about upcoming maintenance.
+	c.Logger.Debug("Parsing response to confirm extension")
+	if code, phrase, ok := schema.findErrorPhrase(translated); ok && resp.StatusCode == http.StatusOK {
+		c.Logger.Error("VPS renewal failed", "vpsID", vpsID, "uniqueID", uniqueID, "error_code", code, "error_message", phrase)
+		return NewPanelError(code, phrase)
+	}
+	if doc, err := goquery.NewDocumentFromReader(strings.NewReader(translated)); err == nil {
+		if err := c.detectInterstitial(resp.StatusCode, body, translated, doc); err != nil {
+			return err
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, body)
+	}
+
+	errorMessages, err := findErrorMessageFromResponse(schema, strings.NewReader(translated))
 	if err != nil {
 		c.Logger.Error("Failed to find error message in response", "error", err, "vpsID", vpsID, "uniqueID", uniqueID)
-		return fmt.Errorf("VPS renewal failed: %s", err)
+		return fmt.Errorf("VPS renewal failed: %w", err)
 	}
 	errorMessage := strings.Join(errorMessages, " ")
-	c.Logger.Error("VPS renewal failed", "vpsID", vpsID, "uniqueID", uniqueID, "error_message", errorMessage)
-	return fmt.Errorf("VPS renewal failed: %s", errorMessage)
+	c.Logger.Error("VPS renewal failed", "vpsID", vpsID, "uniqueID", uniqueID, "error_code", ErrorCodeUnknown, "error_message", errorMessage)
+	return NewPanelError(ErrorCodeUnknown, errorMessage)
 }
 
 func translate(content string) string {
-	// EUC-JP to UTF-8
+	decoded := html.UnescapeString(decodeEUCJP(content))
+	return decoded
+}
+
+func decodeEUCJP(content string) string {
+	// EUC-JP to UTF-8, unless the panel already answered in UTF-8
+	if utf8.ValidString(content) {
+		return content
+	}
 	decoder := japanese.EUCJP.NewDecoder()
 	utf8Content, _, err := transform.String(decoder, content)
 	if err != nil {
 		utf8Content = content
 	}
-
-	decoded := html.UnescapeString(utf8Content)
-	return decoded
+	return utf8Content
 }
 
-func findErrorMessageFromResponse(body io.Reader) ([]string, error) {
+func findErrorMessageFromResponse(schema *PanelSchema, body io.Reader) ([]string, error) {
 	doc, err := goquery.NewDocumentFromReader(body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse response body: %w", err)
 	}
+	if schema.isLoginPage(doc) {
+		return nil, ErrLoginRequired
+	}
 
 	var errorMessage []string
-	queries := []string{
-		"main .contents",
-		"main",
-	}
-	for _, query := range queries {
+	for _, query := range schema.Selectors.ErrorMessage {
 		doc.Find(query).Each(func(i int, s *goquery.Selection) {
 			text := strings.TrimSpace(s.Text())
 			text = strings.ReplaceAll(text, "\n", " ")
@@ -2,12 +2,14 @@ package xserver
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/h2non/gock"
 	"golang.org/x/text/encoding/japanese"
@@ -156,7 +158,7 @@ func Test_findUniqueIdInResponse(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			reader := strings.NewReader(tt.htmlBody)
-			result, err := findUniqueIdInResponse(reader)
+			result, err := findUniqueIdInResponse(DefaultPanelSchema(), reader)
 
 			if tt.wantErr {
 				if err == nil {
@@ -309,7 +311,7 @@ func Test_findErrorMessageFromResponse(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			reader := strings.NewReader(tt.htmlBody)
-			result, err := findErrorMessageFromResponse(reader)
+			result, err := findErrorMessageFromResponse(DefaultPanelSchema(), reader)
 
 			if tt.wantErr {
 				if err == nil {
@@ -365,6 +367,49 @@ func Test_GetCSRFTokenAsUniqueID(t *testing.T) {
 	})
 }
 
+func Test_GetRenewalForm(t *testing.T) {
+	defer gock.Off()
+
+	tests := []struct {
+		name       string
+		body       string
+		wantExpiry time.Time
+	}{
+		{
+			name:       "Unique ID and expiry",
+			body:       `<form><input type="hidden" name="uniqid" value="csrf1234567890" /><span class="expiry">2025年7月10日 15:00</span></form>`,
+			wantExpiry: time.Date(2025, 7, 10, 15, 0, 0, 0, panelLocation),
+		},
+		{
+			name: "Expiry not shown",
+			body: `<form><input type="hidden" name="uniqid" value="csrf1234567890" /></form>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gock.New("https://secure.xserver.ne.jp").
+				Get("/xapanel/xvps/server/freevps/extend/index").
+				Reply(200).
+				BodyString("<html><body>" + tt.body + "</body></html>")
+			c := &client{Client: &http.Client{}, Logger: slog.Default()}
+
+			form, err := c.GetRenewalForm(context.Background(), "12345")
+			if err != nil {
+				t.Fatalf("GetRenewalForm failed: %v", err)
+			}
+			if form.UniqueID != "csrf1234567890" || form.VPSID != "12345" {
+				t.Errorf("unexpected form %+v", form)
+			}
+			if !form.Expiry.Equal(tt.wantExpiry) {
+				t.Errorf("expected expiry %v, got %v", tt.wantExpiry, form.Expiry)
+			}
+			if !gock.IsDone() {
+				t.Error("expected the extend page to be fetched once")
+			}
+		})
+	}
+}
+
 func Test_ExtendFreeVPSExpiration(t *testing.T) {
 	defer gock.Off()
 
@@ -448,3 +493,89 @@ func Test_ExtendFreeVPSExpiration(t *testing.T) {
 		}
 	})
 }
+
+func Test_ExtendFreeVPSExpiration_KnownErrorPhrase(t *testing.T) {
+	defer gock.Off()
+
+	vpsID := VPSID("test-vps-id")
+	uniqueID := UniqueID("csrf1234567890")
+
+	errorHTML := `<html>
+		<body>
+			<main>
+				<div class="contents">
+					<p>お知らせ</p>
+					<p>利用期限の更新はまだできません。</p>
+				</div>
+			</main>
+		</body>
+	</html>`
+	eucjpErrorBody, err := encodeToEUCJP(errorHTML)
+	if err != nil {
+		t.Fatalf("Failed to encode to EUC-JP: %v", err)
+	}
+
+	gock.New("https://" + XServerHost).
+		Post(DoFreeVPSExtendPath).
+		Reply(200).
+		BodyString(eucjpErrorBody)
+
+	c := &client{
+		Client: &http.Client{},
+		Logger: slog.Default(),
+		Schema: DefaultPanelSchema(),
+	}
+
+	err = c.ExtendFreeVPSExpiration(context.Background(), vpsID, uniqueID)
+	if err == nil {
+		t.Fatal("expected error but got nil")
+	}
+	var panelErr *PanelError
+	if !errors.As(err, &panelErr) {
+		t.Fatalf("expected *PanelError, got %T", err)
+	}
+	if panelErr.Code != ErrorCodeNotYetRenewable {
+		t.Errorf("expected code %s, got %s", ErrorCodeNotYetRenewable, panelErr.Code)
+	}
+	if panelErr.Message != "利用期限の更新はまだできません。" {
+		t.Errorf("expected verbatim message, got %s", panelErr.Message)
+	}
+	expectedError := "VPS renewal failed [not_yet_renewable]: " + panelErr.English + " (利用期限の更新はまだできません。)"
+	if err.Error() != expectedError {
+		t.Errorf("expected %s, got %s", expectedError, err.Error())
+	}
+}
+
+func Test_ExtendFreeVPSExpiration_MaintenanceNotice(t *testing.T) {
+	defer gock.Off()
+
+	// Renewal failures are served with the usual panel layout, which may
+	// announce upcoming maintenance.
+	errorHTML := `<html>
+		<body>
+			<div class="notice">2025年7月20日 10:00 ～ 15:00 の間、メンテナンスを実施いたします。</div>
+			<main>
+				<div class="contents">
+					<p>利用期限の更新はまだできません。</p>
+				</div>
+			</main>
+		</body>
+	</html>`
+	eucjpErrorBody, err := encodeToEUCJP(errorHTML)
+	if err != nil {
+		t.Fatalf("Failed to encode to EUC-JP: %v", err)
+	}
+	gock.New("https://" + XServerHost).
+		Post(DoFreeVPSExtendPath).
+		Reply(200).
+		BodyString(eucjpErrorBody)
+
+	c := &client{Client: &http.Client{}, Logger: slog.Default(), Schema: DefaultPanelSchema()}
+	err = c.ExtendFreeVPSExpiration(context.Background(), VPSID("12345"), UniqueID("csrf1234567890"))
+	if errors.Is(err, ErrMaintenance) {
+		t.Fatalf("expected the renewal error, got %v", err)
+	}
+	if code := ErrorCodeOf(err); code != ErrorCodeNotYetRenewable {
+		t.Errorf("expected code %s, got %s (%v)", ErrorCodeNotYetRenewable, code, err)
+	}
+}
@@ -0,0 +1,138 @@
+package xserver
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ErrorCode is a stable identifier for a failure message shown by the panel.
+type ErrorCode string
+
+const (
+	ErrorCodeUnknown         ErrorCode = "unknown"
+	ErrorCodeNotYetRenewable ErrorCode = "not_yet_renewable"
+	ErrorCodeAlreadyRenewed  ErrorCode = "already_renewed"
+	ErrorCodeServerNotFound  ErrorCode = "server_not_found"
+	ErrorCodeContractEnded   ErrorCode = "contract_ended"
+	ErrorCodeInvalidRequest  ErrorCode = "invalid_request"
+
+	ErrorCodeLoginRequired     ErrorCode = "login_required"
+	ErrorCodeMaintenance       ErrorCode = "maintenance"
+	ErrorCodeChallengeRequired ErrorCode = "challenge_required"
+)
+
+// CatalogEntry describes a known panel error in both languages.
+type CatalogEntry struct {
+	Code     ErrorCode
+	English  string
+	Japanese string
+}
+
+var errorCatalog = []CatalogEntry{
+	{
+		Code:     ErrorCodeNotYetRenewable,
+		English:  "The expiration date cannot be extended yet; try again closer to expiry.",
+		Japanese: "利用期限の更新はまだできません。期限が近づいてから再度お試しください。",
+	},
+	{
+		Code:     ErrorCodeAlreadyRenewed,
+		English:  "The expiration date has already been extended.",
+		Japanese: "既に利用期限の更新手続きが完了しています。",
+	},
+	{
+		Code:     ErrorCodeServerNotFound,
+		English:  "The server does not exist or does not belong to this account.",
+		Japanese: "対象のサーバーが見つかりません。",
+	},
+	{
+		Code:     ErrorCodeContractEnded,
+		English:  "The contract for this server has ended.",
+		Japanese: "契約が終了しています。",
+	},
+	{
+		Code:     ErrorCodeInvalidRequest,
+		English:  "The panel rejected the request, usually because the uniqid token was stale or reused.",
+		Japanese: "不正なリクエストです。",
+	},
+	{
+		Code:     ErrorCodeLoginRequired,
+		English:  "The session cookies are no longer valid and the panel asked to log in again.",
+		Japanese: "セッションが無効です。再度ログインしてください。",
+	},
+	{
+		Code:     ErrorCodeMaintenance,
+		English:  "The panel is under maintenance.",
+		Japanese: "コントロールパネルはメンテナンス中です。",
+	},
+	{
+		Code:     ErrorCodeChallengeRequired,
+		English:  "The panel served a bot challenge or CAPTCHA instead of the page.",
+		Japanese: "画像認証などのボット確認が表示されました。",
+	},
+}
+
+// ErrorCatalog returns every known panel error.
+func ErrorCatalog() []CatalogEntry {
+	return slices.Clone(errorCatalog)
+}
+
+// LookupErrorCode returns the catalog entry for code.
+func LookupErrorCode(code ErrorCode) (CatalogEntry, bool) {
+	for _, entry := range errorCatalog {
+		if entry.Code == code {
+			return entry, true
+		}
+	}
+	return CatalogEntry{}, false
+}
+
+// PanelError is returned when the panel refuses a renewal. Message is the text
+// shown by the panel, kept verbatim; Code is ErrorCodeUnknown when the message
+// is not in the catalog.
+type PanelError struct {
+	Code     ErrorCode
+	Message  string
+	English  string
+	Japanese string
+}
+
+// NewPanelError returns the error for message, described by the catalog entry for code.
+func NewPanelError(code ErrorCode, message string) *PanelError {
+	entry, ok := LookupErrorCode(code)
+	if !ok {
+		return &PanelError{Code: ErrorCodeUnknown, Message: message}
+	}
+	return &PanelError{
+		Code:     entry.Code,
+		Message:  message,
+		English:  entry.English,
+		Japanese: entry.Japanese,
+	}
+}
+
+func (e *PanelError) Error() string {
+	if e.Code == ErrorCodeUnknown {
+		return fmt.Sprintf("VPS renewal failed: %s", e.Message)
+	}
+	return fmt.Sprintf("VPS renewal failed [%s]: %s (%s)", e.Code, e.English, e.Message)
+}
+
+// ErrorCodeOf returns the code describing err, or an empty code for nil.
+func ErrorCodeOf(err error) ErrorCode {
+	var panelErr *PanelError
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &panelErr):
+		return panelErr.Code
+	case errors.Is(err, ErrLoginRequired):
+		return ErrorCodeLoginRequired
+	case errors.Is(err, ErrMaintenance):
+		return ErrorCodeMaintenance
+	case errors.Is(err, ErrChallengeRequired):
+		return ErrorCodeChallengeRequired
+	default:
+		return ErrorCodeUnknown
+	}
+}
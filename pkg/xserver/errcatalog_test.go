@@ -0,0 +1,89 @@
+package xserver
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func Test_ErrorCatalog(t *testing.T) {
+	seen := map[ErrorCode]bool{}
+	for _, entry := range ErrorCatalog() {
+		if seen[entry.Code] {
+			t.Errorf("duplicate code %s", entry.Code)
+		}
+		seen[entry.Code] = true
+		if entry.English == "" || entry.Japanese == "" {
+			t.Errorf("code %s is missing a description", entry.Code)
+		}
+	}
+
+	for code := range DefaultPanelSchema().ErrorPhrases {
+		if !seen[code] {
+			t.Errorf("default schema uses code %s which is not in the catalog", code)
+		}
+	}
+}
+
+func Test_NewPanelError(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     ErrorCode
+		message  string
+		expected string
+	}{
+		{
+			name:     "Known code",
+			code:     ErrorCodeAlreadyRenewed,
+			message:  "既に利用期限の更新手続きが完了しています。",
+			expected: "VPS renewal failed [already_renewed]: The expiration date has already been extended. (既に利用期限の更新手続きが完了しています。)",
+		},
+		{
+			name:     "Unknown message is kept verbatim",
+			code:     ErrorCodeUnknown,
+			message:  "想定外 のエラー",
+			expected: "VPS renewal failed: 想定外 のエラー",
+		},
+		{
+			name:     "Code missing from catalog",
+			code:     ErrorCode("something_else"),
+			message:  "何か",
+			expected: "VPS renewal failed: 何か",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewPanelError(tt.code, tt.message)
+			if err.Error() != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, err.Error())
+			}
+			if err.Message != tt.message {
+				t.Errorf("expected message %s, got %s", tt.message, err.Message)
+			}
+		})
+	}
+}
+
+func Test_ErrorCodeOf(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected ErrorCode
+	}{
+		{"Nil", nil, ""},
+		{"Panel error", fmt.Errorf("wrapped: %w", NewPanelError(ErrorCodeContractEnded, "契約が終了しています。")), ErrorCodeContractEnded},
+		{"Login required", ErrLoginRequired, ErrorCodeLoginRequired},
+		{"Maintenance", &PageError{Err: ErrMaintenance}, ErrorCodeMaintenance},
+		{"Challenge", &PageError{Err: ErrChallengeRequired}, ErrorCodeChallengeRequired},
+		{"Other", errors.New("connection reset"), ErrorCodeUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ErrorCodeOf(tt.err); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package xserver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+const (
+	PageExtendIndex = "extend_index"
+)
+
+// PageFingerprint is a structural summary of a panel page that ignores text and values.
+type PageFingerprint struct {
+	Page       string          `json:"page"`
+	FormFields []string        `json:"form_fields"`
+	Selectors  map[string]bool `json:"selectors"`
+	Skeleton   string          `json:"skeleton"`
+}
+
+// PanelBaseline holds the last known fingerprint of each page, keyed by page name.
+type PanelBaseline map[string]PageFingerprint
+
+// DriftReport describes how a page differs from its baseline.
+type DriftReport struct {
+	Page        string          `json:"page"`
+	HasBaseline bool            `json:"has_baseline"`
+	Baseline    PageFingerprint `json:"baseline"`
+	Current     PageFingerprint `json:"current"`
+	Changes     []string        `json:"changes"`
+}
+
+func (r DriftReport) Drifted() bool {
+	return len(r.Changes) != 0
+}
+
+// LoadPanelBaseline reads a JSON baseline written by PanelBaseline.Save.
+func LoadPanelBaseline(path string) (PanelBaseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read panel baseline: %w", err)
+	}
+	var baseline PanelBaseline
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return nil, fmt.Errorf("failed to parse panel baseline %s: %w", path, err)
+	}
+	return baseline, nil
+}
+
+func (b PanelBaseline) Save(path string) error {
+	data, err := json.MarshalIndent(b, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to encode panel baseline: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write panel baseline: %w", err)
+	}
+	return nil
+}
+
+// Compare returns a report of the differences between the baseline for page and current.
+func (b PanelBaseline) Compare(current PageFingerprint) DriftReport {
+	report := DriftReport{
+		Page:    current.Page,
+		Current: current,
+	}
+	baseline, ok := b[current.Page]
+	if !ok {
+		return report
+	}
+	report.HasBaseline = true
+	report.Baseline = baseline
+	report.Changes = diffFingerprints(baseline, current)
+	return report
+}
+
+func diffFingerprints(baseline, current PageFingerprint) []string {
+	var changes []string
+
+	for _, field := range baseline.FormFields {
+		if !slices.Contains(current.FormFields, field) {
+			changes = append(changes, fmt.Sprintf("form field removed: %s", field))
+		}
+	}
+	for _, field := range current.FormFields {
+		if !slices.Contains(baseline.FormFields, field) {
+			changes = append(changes, fmt.Sprintf("form field added: %s", field))
+		}
+	}
+
+	selectors := slices.Sorted(maps.Keys(baseline.Selectors))
+	for _, selector := range selectors {
+		was := baseline.Selectors[selector]
+		now, ok := current.Selectors[selector]
+		if !ok {
+			continue
+		}
+		if was && !now {
+			changes = append(changes, fmt.Sprintf("selector no longer matches: %s", selector))
+		} else if !was && now {
+			changes = append(changes, fmt.Sprintf("selector now matches: %s", selector))
+		}
+	}
+
+	if baseline.Skeleton != current.Skeleton {
+		changes = append(changes, fmt.Sprintf("DOM skeleton changed: %s -> %s", baseline.Skeleton, current.Skeleton))
+	}
+	return changes
+}
+
+func computeFingerprint(page string, schema *PanelSchema, doc *goquery.Document) PageFingerprint {
+	fields := map[string]struct{}{}
+	doc.Find("form input[name], form select[name], form textarea[name]").Each(func(i int, s *goquery.Selection) {
+		name, _ := s.Attr("name")
+		fields[name] = struct{}{}
+	})
+
+	selectors := map[string]bool{}
+	check := func(selector string) {
+		selectors[selector] = doc.Find(selector).Length() > 0
+	}
+	check(schema.Selectors.UniqueID)
+	for _, selector := range schema.Selectors.ErrorMessage {
+		check(selector)
+	}
+
+	return PageFingerprint{
+		Page:       page,
+		FormFields: slices.Sorted(maps.Keys(fields)),
+		Selectors:  selectors,
+		Skeleton:   skeletonHash(doc),
+	}
+}
+
+// skeletonHash hashes the element tree of the body by tag name and depth only,
+// so changing text, values or attributes does not change the hash.
+func skeletonHash(doc *goquery.Document) string {
+	var sb strings.Builder
+	var walk func(n *html.Node, depth int)
+	walk = func(n *html.Node, depth int) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "script", "style", "noscript":
+				return
+			}
+			fmt.Fprintf(&sb, "%d:%s\n", depth, n.Data)
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child, depth+1)
+		}
+	}
+	for _, node := range doc.Find("body").Nodes {
+		walk(node, 0)
+	}
+
+	sum := sha256.Sum256([]byte(sb.String()))
+	return hex.EncodeToString(sum[:8])
+}
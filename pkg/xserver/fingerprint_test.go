@@ -0,0 +1,141 @@
+package xserver
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/h2non/gock"
+)
+
+const extendPageHTML = `<html>
+	<body>
+		<main>
+			<div class="contents">
+				<form method="post" action="/xapanel/xvps/server/freevps/extend/do">
+					<input type="hidden" name="uniqid" value="%s" />
+					<input type="hidden" name="id_vps" value="12345" />
+					<input type="submit" value="更新する" />
+				</form>
+			</div>
+		</main>
+	</body>
+</html>`
+
+func fingerprintOf(t *testing.T, body string) PageFingerprint {
+	t.Helper()
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return computeFingerprint(PageExtendIndex, DefaultPanelSchema(), doc)
+}
+
+func Test_computeFingerprint(t *testing.T) {
+	fp := fingerprintOf(t, strings.Replace(extendPageHTML, "%s", "abc", 1))
+
+	if !reflect.DeepEqual(fp.FormFields, []string{"id_vps", "uniqid"}) {
+		t.Errorf("unexpected form fields: %v", fp.FormFields)
+	}
+	if !fp.Selectors["input[type=hidden][name=uniqid]"] {
+		t.Error("expected unique_id selector to match")
+	}
+	if fp.Skeleton == "" {
+		t.Error("expected skeleton hash to be set")
+	}
+
+	other := fingerprintOf(t, strings.Replace(extendPageHTML, "%s", "xyz", 1))
+	if fp.Skeleton != other.Skeleton {
+		t.Error("expected skeleton hash to ignore attribute values")
+	}
+}
+
+func Test_PanelBaseline_Compare(t *testing.T) {
+	baseline := PanelBaseline{
+		PageExtendIndex: fingerprintOf(t, extendPageHTML),
+	}
+
+	t.Run("No drift", func(t *testing.T) {
+		report := baseline.Compare(fingerprintOf(t, extendPageHTML))
+		if !report.HasBaseline {
+			t.Error("expected baseline to be found")
+		}
+		if report.Drifted() {
+			t.Errorf("expected no drift, got %v", report.Changes)
+		}
+	})
+
+	t.Run("Field renamed", func(t *testing.T) {
+		changed := strings.Replace(extendPageHTML, `name="uniqid"`, `name="token"`, 1)
+		report := baseline.Compare(fingerprintOf(t, changed))
+		expected := []string{
+			"form field removed: uniqid",
+			"form field added: token",
+			"selector no longer matches: input[type=hidden][name=uniqid]",
+		}
+		if !reflect.DeepEqual(report.Changes, expected) {
+			t.Errorf("expected %q, got %q", expected, report.Changes)
+		}
+	})
+
+	t.Run("Missing baseline", func(t *testing.T) {
+		report := PanelBaseline{}.Compare(fingerprintOf(t, extendPageHTML))
+		if report.HasBaseline || report.Drifted() {
+			t.Errorf("expected no baseline and no drift, got %+v", report)
+		}
+	})
+}
+
+func Test_PanelBaseline_SaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	baseline := PanelBaseline{
+		PageExtendIndex: fingerprintOf(t, extendPageHTML),
+	}
+	if err := baseline.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := LoadPanelBaseline(path)
+	if err != nil {
+		t.Fatalf("LoadPanelBaseline failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, baseline) {
+		t.Errorf("expected %+v, got %+v", baseline, loaded)
+	}
+}
+
+func Test_CheckPanel(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://" + XServerHost).
+		Get(FreeVPSExtendPath).
+		Reply(200).
+		BodyString(strings.Replace(extendPageHTML, `<main>`, `<main><div class="banner"></div>`, 1))
+
+	var drifted []DriftReport
+	c := &client{
+		Client: &http.Client{},
+		Logger: slog.Default(),
+		Baseline: PanelBaseline{
+			PageExtendIndex: fingerprintOf(t, extendPageHTML),
+		},
+		OnDrift: func(report DriftReport) {
+			drifted = append(drifted, report)
+		},
+	}
+
+	report, err := c.CheckPanel(context.Background(), VPSID("12345"))
+	if err != nil {
+		t.Fatalf("CheckPanel failed: %v", err)
+	}
+	if !report.Drifted() {
+		t.Fatal("expected drift to be reported")
+	}
+	if len(drifted) != 1 {
+		t.Errorf("expected OnDrift to be called once, got %d", len(drifted))
+	}
+}
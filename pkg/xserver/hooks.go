@@ -0,0 +1,101 @@
+package xserver
+
+import (
+	"context"
+	"net/http"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.39.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Operation names the Client method a request or result belongs to.
+type Operation string
+
+const (
+	OperationGetCSRFToken   Operation = "GetCSRFTokenAsUniqueID"
+	OperationGetRenewalForm Operation = "GetRenewalForm"
+	OperationExtend         Operation = "ExtendFreeVPSExpiration"
+	OperationCheckPanel     Operation = "CheckPanel"
+	OperationGetStatus      Operation = "GetServerStatus"
+	OperationListServers    Operation = "ListServers"
+)
+
+// HookInfo identifies the operation a hook is called for.
+type HookInfo struct {
+	Operation Operation
+	VPSID     VPSID
+}
+
+// ExtendResult is passed to OnParsed after a successful renewal.
+type ExtendResult struct {
+	VPSID VPSID
+}
+
+// Hooks are called around every request the client sends. Hooks in
+// ClientOptions run in order; any field may be nil.
+type Hooks struct {
+	// BeforeRequest may modify req. Returning an error aborts the request.
+	BeforeRequest func(ctx context.Context, info HookInfo, req *http.Request) error
+	// AfterResponse sees the response before it is parsed and must not consume
+	// the body. Returning an error discards the response.
+	AfterResponse func(ctx context.Context, info HookInfo, resp *http.Response) error
+	// OnError is called with the error an operation is about to return.
+	OnError func(ctx context.Context, info HookInfo, err error)
+	// OnParsed is called with the result of a successful operation: a
+	// UniqueID, a RenewalForm, a DriftReport, an ExtendResult, a ServerStatus
+	// or a []Server.
+	OnParsed func(ctx context.Context, info HookInfo, result any)
+}
+
+// startOperation opens the span for an operation. The returned function ends
+// it and runs the OnError hooks.
+func (c *client) startOperation(ctx context.Context, operation Operation, vpsID VPSID) (context.Context, HookInfo, func(error)) {
+	info := HookInfo{Operation: operation, VPSID: vpsID}
+	ctx, span := c.startSpan(ctx, operation, vpsID)
+	return ctx, info, func(err error) {
+		if err != nil {
+			for _, h := range c.Hooks {
+				if h.OnError != nil {
+					h.OnError(ctx, info, err)
+				}
+			}
+		}
+		EndSpan(span, err)
+	}
+}
+
+func (c *client) parsed(ctx context.Context, info HookInfo, result any) {
+	for _, h := range c.Hooks {
+		if h.OnParsed != nil {
+			h.OnParsed(ctx, info, result)
+		}
+	}
+}
+
+// send runs the configured hooks around http.Client.Do.
+func (c *client) send(info HookInfo, req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for _, h := range c.Hooks {
+		if h.BeforeRequest != nil {
+			if err := h.BeforeRequest(ctx, info, req); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	trace.SpanFromContext(ctx).SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+
+	for _, h := range c.Hooks {
+		if h.AfterResponse != nil {
+			if err := h.AfterResponse(ctx, info, resp); err != nil {
+				resp.Body.Close()
+				return nil, err
+			}
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,98 @@
+package xserver
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/h2non/gock"
+)
+
+func Test_Hooks(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://"+XServerHost).
+		Get(FreeVPSExtendPath).
+		MatchHeader("X-Audit", "first").
+		Reply(200).
+		BodyString(`<form><input type="hidden" name="uniqid" value="abc123" /></form>`)
+
+	var calls []string
+	c := &client{
+		Client: &http.Client{},
+		Logger: slog.Default(),
+		Hooks: []Hooks{
+			{
+				BeforeRequest: func(ctx context.Context, info HookInfo, req *http.Request) error {
+					calls = append(calls, "before:"+string(info.Operation)+":"+info.VPSID.String())
+					req.Header.Set("X-Audit", "first")
+					return nil
+				},
+				AfterResponse: func(ctx context.Context, info HookInfo, resp *http.Response) error {
+					calls = append(calls, "after:"+resp.Status)
+					return nil
+				},
+			},
+			{
+				OnParsed: func(ctx context.Context, info HookInfo, result any) {
+					calls = append(calls, "parsed:"+result.(UniqueID).String())
+				},
+				OnError: func(ctx context.Context, info HookInfo, err error) {
+					calls = append(calls, "error")
+				},
+			},
+		},
+	}
+
+	uniqueID, err := c.GetCSRFTokenAsUniqueID(context.Background(), VPSID("12345"))
+	if err != nil {
+		t.Fatalf("GetCSRFTokenAsUniqueID failed: %v", err)
+	}
+	if uniqueID != UniqueID("abc123") {
+		t.Errorf("expected abc123, got %s", uniqueID)
+	}
+
+	expected := []string{
+		"before:GetCSRFTokenAsUniqueID:12345",
+		"after:200 OK",
+		"parsed:abc123",
+	}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected %q, got %q", expected, calls)
+	}
+}
+
+func Test_Hooks_Abort(t *testing.T) {
+	defer gock.Off()
+
+	abort := errors.New("blocked by hook")
+	var gotErr error
+	c := &client{
+		Client: &http.Client{},
+		Logger: slog.Default(),
+		Hooks: []Hooks{
+			{
+				BeforeRequest: func(ctx context.Context, info HookInfo, req *http.Request) error {
+					return abort
+				},
+				OnError: func(ctx context.Context, info HookInfo, err error) {
+					gotErr = err
+				},
+			},
+		},
+	}
+
+	err := c.ExtendFreeVPSExpiration(context.Background(), VPSID("12345"), UniqueID("abc123"))
+	if !errors.Is(err, abort) {
+		t.Fatalf("expected hook error, got %v", err)
+	}
+	if !errors.Is(gotErr, abort) {
+		t.Errorf("expected OnError to receive the hook error, got %v", gotErr)
+	}
+	if !gock.IsDone() {
+		t.Error("expected no request to be sent")
+	}
+}
@@ -1,11 +1,55 @@
 package xserver
 
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
 type VPSID string
 
 func (v VPSID) String() string {
 	return string(v)
 }
 
+// ErrInvalidVPSID is returned by ParseVPSID for input that is not a VPS ID.
+var ErrInvalidVPSID = errors.New("invalid VPS ID")
+
+var vpsIDPattern = regexp.MustCompile(`^[0-9]{1,20}$`)
+
+// ParseVPSID accepts a bare numeric VPS ID or a panel URL carrying it in a
+// vpsid or id_vps query parameter, such as the extend page URL copied from
+// the browser.
+func ParseVPSID(s string) (VPSID, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", fmt.Errorf("%w: empty", ErrInvalidVPSID)
+	}
+
+	id := s
+	if strings.Contains(s, "?") || strings.Contains(s, "://") {
+		u, err := url.Parse(s)
+		if err != nil {
+			return "", fmt.Errorf("%w: %q is not a URL: %v", ErrInvalidVPSID, s, err)
+		}
+		q := u.Query()
+		id = q.Get("vpsid")
+		if id == "" {
+			id = q.Get("id_vps")
+		}
+		if id == "" {
+			return "", fmt.Errorf("%w: URL %q has no vpsid or id_vps parameter", ErrInvalidVPSID, s)
+		}
+	}
+
+	if !vpsIDPattern.MatchString(id) {
+		return "", fmt.Errorf("%w: %q must be digits only", ErrInvalidVPSID, id)
+	}
+	return VPSID(id), nil
+}
+
 type UniqueID string
 
 func (u UniqueID) String() string {
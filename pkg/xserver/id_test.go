@@ -1,6 +1,9 @@
 package xserver
 
-import "testing"
+import (
+	"errors"
+	"testing"
+)
 
 func Test_VPSID_String(t *testing.T) {
 	tests := []struct {
@@ -55,3 +58,41 @@ func Test_UniqueID_String(t *testing.T) {
 		})
 	}
 }
+
+func Test_ParseVPSID(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected VPSID
+		wantErr  bool
+	}{
+		{name: "Bare ID", input: "12345678", expected: "12345678"},
+		{name: "Surrounding whitespace", input: " 12345678\n", expected: "12345678"},
+		{name: "Extend page URL", input: "https://secure.xserver.ne.jp/xapanel/xvps/server/freevps/extend/index?vpsid=12345678", expected: "12345678"},
+		{name: "id_vps parameter", input: "https://secure.xserver.ne.jp/xapanel/xvps/server/detail?id_vps=12345678&tab=info", expected: "12345678"},
+		{name: "Query string only", input: "?vpsid=42", expected: "42"},
+		{name: "Empty", input: "", wantErr: true},
+		{name: "Letters", input: "vps-12345", wantErr: true},
+		{name: "URL without ID", input: "https://secure.xserver.ne.jp/xapanel/xvps/index", wantErr: true},
+		{name: "URL with bad ID", input: "https://secure.xserver.ne.jp/?vpsid=12a", wantErr: true},
+		{name: "Too long", input: "123456789012345678901", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseVPSID(tt.input)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidVPSID) {
+					t.Errorf("expected ErrInvalidVPSID, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
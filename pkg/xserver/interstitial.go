@@ -0,0 +1,91 @@
+package xserver
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var (
+	ErrMaintenance       = errors.New("panel is under maintenance")
+	ErrChallengeRequired = errors.New("panel requires a bot challenge")
+)
+
+// PageError is returned when the panel serves a maintenance notice or a bot
+// challenge instead of the requested page. It unwraps to ErrMaintenance or
+// ErrChallengeRequired.
+type PageError struct {
+	Err        error
+	StatusCode int
+	// Until is the announced end of maintenance, zero when the page does not say.
+	Until time.Time
+	// Page is the raw response body.
+	Page []byte
+	// SavedPath is where Page was written when ClientOptions.PageDumpDir is set.
+	SavedPath string
+}
+
+func (e *PageError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(e.Err.Error())
+	if !e.Until.IsZero() {
+		fmt.Fprintf(&sb, " until %s", e.Until.Format(time.RFC3339))
+	}
+	if e.SavedPath != "" {
+		fmt.Fprintf(&sb, " (page saved to %s)", e.SavedPath)
+	}
+	return sb.String()
+}
+
+func (e *PageError) Unwrap() error {
+	return e.Err
+}
+
+// detectInterstitial recognizes pages served in place of the panel. content is
+// the decoded body that doc was parsed from.
+func (c *client) detectInterstitial(statusCode int, body []byte, content string, doc *goquery.Document) error {
+	schema := c.panelSchema()
+
+	var pageErr *PageError
+	switch {
+	case schema.isChallengePage(doc):
+		pageErr = &PageError{Err: ErrChallengeRequired}
+	case schema.isMaintenancePage(content) && doc.Find(schema.Selectors.UniqueID).Length() == 0:
+		pageErr = &PageError{Err: ErrMaintenance, Until: schema.maintenanceEnd(content)}
+	default:
+		return nil
+	}
+	pageErr.StatusCode = statusCode
+	pageErr.Page = body
+
+	kind := "challenge"
+	if errors.Is(pageErr, ErrMaintenance) {
+		kind = "maintenance"
+	}
+	if path, err := c.savePage(kind, body); err != nil {
+		c.Logger.Warn("Failed to save page", "error", err, "kind", kind)
+	} else {
+		pageErr.SavedPath = path
+	}
+	c.Logger.Warn("Panel served an interstitial page", "kind", kind, "status_code", statusCode, "until", pageErr.Until, "saved_path", pageErr.SavedPath)
+	return pageErr
+}
+
+func (c *client) savePage(kind string, body []byte) (string, error) {
+	if c.PageDumpDir == "" {
+		return "", nil
+	}
+	if err := os.MkdirAll(c.PageDumpDir, 0o755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(c.PageDumpDir, fmt.Sprintf("%s-%s.html", kind, time.Now().Format("20060102-150405.000")))
+	if err := os.WriteFile(path, body, 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,134 @@
+package xserver
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/h2non/gock"
+)
+
+func Test_PanelSchema_maintenanceEnd(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected time.Time
+	}{
+		{
+			name:     "Start and end announced",
+			content:  "2025年7月10日(木) 10:00 ～ 2025年7月10日(木) 15:30 の間、メンテナンスを実施いたします。",
+			expected: time.Date(2025, 7, 10, 15, 30, 0, 0, panelLocation),
+		},
+		{
+			name:     "Japanese hour notation",
+			content:  "2025年12月1日 3時00分まで",
+			expected: time.Date(2025, 12, 1, 3, 0, 0, 0, panelLocation),
+		},
+		{
+			name:     "No time on page",
+			content:  "ただいまメンテナンス中です。",
+			expected: time.Time{},
+		},
+	}
+
+	schema := DefaultPanelSchema()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := schema.maintenanceEnd(tt.content)
+			if !got.Equal(tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func Test_GetCSRFTokenAsUniqueID_Interstitial(t *testing.T) {
+	defer gock.Off()
+
+	t.Run("Maintenance page", func(t *testing.T) {
+		maintenanceHTML, err := encodeToEUCJP(`<html><body><main>
+			<h1>ただいまメンテナンス中です</h1>
+			<p>2025年7月10日(木) 10:00 ～ 2025年7月10日(木) 15:00</p>
+		</main></body></html>`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gock.New("https://" + XServerHost).
+			Get(FreeVPSExtendPath).
+			Reply(503).
+			BodyString(maintenanceHTML)
+
+		dir := t.TempDir()
+		c := &client{Client: &http.Client{}, Logger: slog.Default(), PageDumpDir: dir}
+		_, err = c.GetCSRFTokenAsUniqueID(context.Background(), VPSID("12345"))
+		if !errors.Is(err, ErrMaintenance) {
+			t.Fatalf("expected ErrMaintenance, got %v", err)
+		}
+
+		var pageErr *PageError
+		if !errors.As(err, &pageErr) {
+			t.Fatalf("expected *PageError, got %T", err)
+		}
+		if pageErr.StatusCode != 503 {
+			t.Errorf("expected status 503, got %d", pageErr.StatusCode)
+		}
+		if !pageErr.Until.Equal(time.Date(2025, 7, 10, 15, 0, 0, 0, panelLocation)) {
+			t.Errorf("unexpected maintenance end: %v", pageErr.Until)
+		}
+		saved, err := os.ReadFile(pageErr.SavedPath)
+		if err != nil {
+			t.Fatalf("expected page to be saved: %v", err)
+		}
+		if string(saved) != maintenanceHTML {
+			t.Error("expected saved page to match the response body")
+		}
+	})
+
+	t.Run("Challenge page", func(t *testing.T) {
+		gock.New("https://" + XServerHost).
+			Get(FreeVPSExtendPath).
+			Reply(200).
+			BodyString(`<html><body><form id="challenge-form"><div class="g-recaptcha" data-sitekey="x"></div></form></body></html>`)
+
+		c := &client{Client: &http.Client{}, Logger: slog.Default()}
+		_, err := c.GetCSRFTokenAsUniqueID(context.Background(), VPSID("12345"))
+		if !errors.Is(err, ErrChallengeRequired) {
+			t.Fatalf("expected ErrChallengeRequired, got %v", err)
+		}
+	})
+
+	t.Run("Maintenance notice on a working page", func(t *testing.T) {
+		gock.New("https://" + XServerHost).
+			Get(FreeVPSExtendPath).
+			Reply(200).
+			BodyString(`<html><body><main>
+				<p class="news">7月10日はメンテナンスを実施します</p>
+				<form><input type="hidden" name="uniqid" value="abc123" /></form>
+			</main></body></html>`)
+
+		c := &client{Client: &http.Client{}, Logger: slog.Default()}
+		uniqueID, err := c.GetCSRFTokenAsUniqueID(context.Background(), VPSID("12345"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if uniqueID != UniqueID("abc123") {
+			t.Errorf("expected abc123, got %s", uniqueID)
+		}
+	})
+}
+
+func Test_PageError_Error(t *testing.T) {
+	err := &PageError{
+		Err:       ErrMaintenance,
+		Until:     time.Date(2025, 7, 10, 15, 0, 0, 0, panelLocation),
+		SavedPath: "/tmp/maintenance.html",
+	}
+	expected := "panel is under maintenance until 2025-07-10T15:00:00+09:00 (page saved to /tmp/maintenance.html)"
+	if err.Error() != expected {
+		t.Errorf("expected %s, got %s", expected, err.Error())
+	}
+}
@@ -0,0 +1,143 @@
+package xserver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Navigation makes the client reach the extend page the way a person does:
+// from the panel top page, through the server page, following their links
+// and sending the matching Referer and Sec-Fetch-Site headers.
+type Navigation struct {
+	// HomePath is the first page visited. Defaults to PanelHomePath.
+	HomePath string
+}
+
+func (n *Navigation) homePath() string {
+	if n.HomePath == "" {
+		return PanelHomePath
+	}
+	return n.HomePath
+}
+
+// navigateToExtendPage visits the top page and the server page of vpsID, and
+// returns the extend page URL linked from the server page together with the
+// referer to request it with. When a link cannot be found it falls back to
+// the direct extend page URL.
+func (c *client) navigateToExtendPage(ctx context.Context, info HookInfo) (extend *url.URL, referer *url.URL, err error) {
+	direct := freeVPSExtendURL(c.baseURL(), info.VPSID)
+
+	home, doc, err := c.visit(ctx, info, c.baseURL().JoinPath(c.Navigation.homePath()), nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to visit the panel top page: %w", err)
+	}
+	serverPage := findLink(doc, home, func(u *url.URL) bool {
+		return linksToVPS(u, info.VPSID) && u.Path != FreeVPSExtendPath
+	})
+	if serverPage == nil {
+		c.Logger.Warn("No link to the server page found, going to the extend page directly", "vpsID", info.VPSID, "page", home.Path)
+		return direct, home, nil
+	}
+
+	serverPage, doc, err = c.visit(ctx, info, serverPage, home)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to visit the server page: %w", err)
+	}
+	extend = findLink(doc, serverPage, func(u *url.URL) bool {
+		return u.Path == FreeVPSExtendPath && linksToVPS(u, info.VPSID)
+	})
+	if extend == nil {
+		c.Logger.Warn("No link to the extend page found, going there directly", "vpsID", info.VPSID, "page", serverPage.Path)
+		return direct, serverPage, nil
+	}
+	return extend, serverPage, nil
+}
+
+// visit loads a page of the navigation and returns its final URL after redirects.
+func (c *client) visit(ctx context.Context, info HookInfo, target, referer *url.URL) (*url.URL, *goquery.Document, error) {
+	if err := c.Pacer.wait(ctx, target.Host); err != nil {
+		return nil, nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	c.setHeaders(req, referer)
+
+	c.Logger.Debug("Visiting panel page", "url", req.URL.String())
+	resp, err := c.send(info, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	content := decodeEUCJP(string(body))
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse response body: %w", err)
+	}
+	if err := c.detectInterstitial(resp.StatusCode, body, content, doc); err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	if c.panelSchema().isLoginPage(doc) {
+		return nil, nil, ErrLoginRequired
+	}
+	return resp.Request.URL, doc, nil
+}
+
+// setHeaders sets the configured headers, and the ones a browser sends when
+// following a link from referer. A nil referer is a typed-in URL.
+func (c *client) setHeaders(req *http.Request, referer *url.URL) {
+	for key, value := range c.Headers {
+		req.Header.Set(key, value)
+	}
+	if referer == nil {
+		return
+	}
+	req.Header.Set("Referer", referer.String())
+	site := "cross-site"
+	if referer.Scheme == req.URL.Scheme && referer.Host == req.URL.Host {
+		site = "same-origin"
+	}
+	req.Header.Set("Sec-Fetch-Site", site)
+}
+
+// findLink returns the first link on the page, resolved against its URL,
+// for which match returns true.
+func findLink(doc *goquery.Document, page *url.URL, match func(*url.URL) bool) *url.URL {
+	var found *url.URL
+	doc.Find("a[href]").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		href, _ := s.Attr("href")
+		u, err := page.Parse(strings.TrimSpace(href))
+		if err != nil || u.Host != page.Host {
+			return true
+		}
+		if match(u) {
+			found = u
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+func linksToVPS(u *url.URL, vpsID VPSID) bool {
+	q := u.Query()
+	return q.Get("vpsid") == vpsID.String() || q.Get("id_vps") == vpsID.String()
+}
@@ -0,0 +1,89 @@
+package xserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func Test_findLink(t *testing.T) {
+	page, _ := url.Parse("https://secure.xserver.ne.jp/xapanel/xvps/index")
+	tests := []struct {
+		name     string
+		html     string
+		expected string
+	}{
+		{
+			name:     "Relative link",
+			html:     `<a href="server/detail?id_vps=12345">12345</a>`,
+			expected: "https://secure.xserver.ne.jp/xapanel/xvps/server/detail?id_vps=12345",
+		},
+		{
+			name:     "First matching link",
+			html:     `<a href="/x?id_vps=999">999</a><a href="/a?id_vps=12345">a</a><a href="/b?id_vps=12345">b</a>`,
+			expected: "https://secure.xserver.ne.jp/a?id_vps=12345",
+		},
+		{
+			name: "Other host ignored",
+			html: `<a href="https://example.com/?id_vps=12345">elsewhere</a>`,
+		},
+		{
+			name: "No link",
+			html: `<p>12345</p>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader(tt.html))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := findLink(doc, page, func(u *url.URL) bool { return linksToVPS(u, "12345") })
+			if tt.expected == "" {
+				if got != nil {
+					t.Errorf("expected no link, got %s", got)
+				}
+				return
+			}
+			if got == nil || got.String() != tt.expected {
+				t.Errorf("expected %s, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func Test_Navigation_Fallback(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path+" "+r.Referer())
+		mu.Unlock()
+		if r.URL.Path == FreeVPSExtendPath {
+			sessionEcho(w, r)
+			return
+		}
+		w.Write([]byte(`<html><body>no links</body></html>`))
+	}))
+	defer srv.Close()
+	base, _ := url.Parse(srv.URL)
+
+	xs, err := NewClient(ClientOptions{SessionID: "s", DeviceKey: "d", BaseURL: base, Navigation: &Navigation{HomePath: "/top"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := xs.GetCSRFTokenAsUniqueID(context.Background(), "12345"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{"/top ", FreeVPSExtendPath + " " + srv.URL + "/top"}
+	if strings.Join(paths, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %v, got %v", expected, paths)
+	}
+}
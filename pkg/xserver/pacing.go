@@ -0,0 +1,100 @@
+package xserver
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Pacing spaces out the navigations of a client like a person clicking
+// through the panel instead of firing requests back to back.
+type Pacing struct {
+	// MinGap is the least time between the starts of two navigations of one client.
+	MinGap time.Duration
+	// ThinkTimeMin and ThinkTimeMax bound a random gap between the starts of
+	// two navigations, applied when it is longer than MinGap.
+	ThinkTimeMin time.Duration
+	ThinkTimeMax time.Duration
+	// Limiter caps the request rate per host. Share one between clients to
+	// cap them together. Nil means no limit.
+	Limiter *HostLimiter
+}
+
+// HostLimiter is a token bucket per host, safe for use by many clients.
+type HostLimiter struct {
+	limit rate.Limit
+	burst int
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+// NewHostLimiter allows perSecond requests per host, with bursts of up to burst.
+func NewHostLimiter(perSecond float64, burst int) *HostLimiter {
+	return &HostLimiter{
+		limit:    rate.Limit(perSecond),
+		burst:    max(burst, 1),
+		limiters: map[string]*rate.Limiter{},
+	}
+}
+
+// Wait blocks until a request to host is allowed or ctx is done.
+func (l *HostLimiter) Wait(ctx context.Context, host string) error {
+	l.mu.Lock()
+	limiter, ok := l.limiters[host]
+	if !ok {
+		limiter = rate.NewLimiter(l.limit, l.burst)
+		l.limiters[host] = limiter
+	}
+	l.mu.Unlock()
+	return limiter.Wait(ctx)
+}
+
+// pacer keeps the time of the last navigation of one client.
+type pacer struct {
+	Pacing
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+// wait sleeps until the next navigation to host may start.
+func (p *pacer) wait(ctx context.Context, host string) error {
+	if p == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	var delay time.Duration
+	if !p.last.IsZero() {
+		delay = max(p.MinGap, p.thinkTime()) - time.Since(p.last)
+		delay = max(delay, 0)
+	}
+	// Reserve the slot before sleeping so concurrent callers queue up behind it.
+	p.last = time.Now().Add(delay)
+	p.mu.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	if p.Limiter != nil {
+		return p.Limiter.Wait(ctx, host)
+	}
+	return nil
+}
+
+func (p *pacer) thinkTime() time.Duration {
+	if p.ThinkTimeMax <= p.ThinkTimeMin {
+		return p.ThinkTimeMin
+	}
+	return p.ThinkTimeMin + rand.N(p.ThinkTimeMax-p.ThinkTimeMin)
+}
@@ -0,0 +1,113 @@
+package xserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func Test_pacer_wait(t *testing.T) {
+	tests := []struct {
+		name    string
+		pacing  Pacing
+		atLeast time.Duration
+	}{
+		{name: "No pacing", pacing: Pacing{}, atLeast: 0},
+		{name: "Minimum gap", pacing: Pacing{MinGap: 40 * time.Millisecond}, atLeast: 40 * time.Millisecond},
+		{name: "Think time", pacing: Pacing{ThinkTimeMin: 30 * time.Millisecond, ThinkTimeMax: 50 * time.Millisecond}, atLeast: 30 * time.Millisecond},
+		{name: "Think time longer than gap", pacing: Pacing{MinGap: 10 * time.Millisecond, ThinkTimeMin: 40 * time.Millisecond}, atLeast: 40 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &pacer{Pacing: tt.pacing}
+			ctx := context.Background()
+			start := time.Now()
+			if err := p.wait(ctx, "example.com"); err != nil {
+				t.Fatal(err)
+			}
+			if first := time.Since(start); first > 20*time.Millisecond {
+				t.Errorf("expected the first navigation not to wait, waited %v", first)
+			}
+			if err := p.wait(ctx, "example.com"); err != nil {
+				t.Fatal(err)
+			}
+			if elapsed := time.Since(start); elapsed < tt.atLeast {
+				t.Errorf("expected at least %v between navigations, got %v", tt.atLeast, elapsed)
+			}
+		})
+	}
+}
+
+func Test_pacer_wait_Canceled(t *testing.T) {
+	p := &pacer{Pacing: Pacing{MinGap: time.Hour}}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_ = p.wait(ctx, "example.com")
+	if err := p.wait(ctx, "example.com"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func Test_pacer_thinkTime(t *testing.T) {
+	p := &pacer{Pacing: Pacing{ThinkTimeMin: time.Second, ThinkTimeMax: 3 * time.Second}}
+	for range 100 {
+		if d := p.thinkTime(); d < time.Second || d >= 3*time.Second {
+			t.Fatalf("think time %v out of range", d)
+		}
+	}
+}
+
+func Test_HostLimiter(t *testing.T) {
+	limiter := NewHostLimiter(20, 1)
+	ctx := context.Background()
+
+	start := time.Now()
+	for _, host := range []string{"a.example.com", "b.example.com"} {
+		if err := limiter.Wait(ctx, host); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 25*time.Millisecond {
+		t.Errorf("expected different hosts not to wait for each other, took %v", elapsed)
+	}
+
+	if err := limiter.Wait(ctx, "a.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("expected the second request to a host to wait, took %v", elapsed)
+	}
+}
+
+func Test_Client_Pacing(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(sessionEcho))
+	defer srv.Close()
+	base, _ := url.Parse(srv.URL)
+
+	limiter := NewHostLimiter(1000, 1)
+	pacing := &Pacing{MinGap: 40 * time.Millisecond, Limiter: limiter}
+	xs, err := NewClient(ClientOptions{SessionID: "s", DeviceKey: "d", BaseURL: base, Pacing: pacing})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	start := time.Now()
+	for range 3 {
+		if _, err := xs.GetCSRFTokenAsUniqueID(ctx, "12345"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("expected three navigations to take at least two gaps, took %v", elapsed)
+	}
+	if len(limiter.limiters) != 1 || limiter.limiters[base.Host] == nil {
+		t.Errorf("expected the shared limiter to track %s, got %v", base.Host, limiter.limiters)
+	}
+}
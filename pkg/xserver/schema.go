@@ -0,0 +1,358 @@
+package xserver
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"gopkg.in/yaml.v3"
+)
+
+// PanelSchemaVersion is the schema format version understood by this package.
+// Version 2 keys error_phrases by error code.
+const PanelSchemaVersion = 2
+
+//go:embed panel_schema.yaml
+var defaultPanelSchemaYAML []byte
+
+var defaultPanelSchema = mustParsePanelSchema(defaultPanelSchemaYAML)
+
+// panelLocation is the time zone the panel uses for dates shown on its pages.
+var panelLocation = time.FixedZone("JST", 9*60*60)
+
+var (
+	ErrInvalidPanelSchema = errors.New("invalid panel schema")
+)
+
+// Named groups the time patterns of a schema must have.
+var (
+	expiryGroups          = []string{"year", "month", "day"}
+	maintenanceTimeGroups = []string{"year", "month", "day", "hour", "minute"}
+)
+
+// PanelSchema describes how the control panel pages are scraped.
+type PanelSchema struct {
+	Version                int                    `yaml:"version"`
+	Selectors              PanelSelectors         `yaml:"selectors"`
+	ExpiryPattern          string                 `yaml:"expiry_pattern"`
+	FreePlanMarkers        []string               `yaml:"free_plan_markers"`
+	SuccessMarkers         []string               `yaml:"success_markers"`
+	ErrorPhrases           map[ErrorCode][]string `yaml:"error_phrases"`
+	MaintenanceMarkers     []string               `yaml:"maintenance_markers"`
+	MaintenanceTimePattern string                 `yaml:"maintenance_time_pattern"`
+	ChallengeMarkers       []string               `yaml:"challenge_markers"`
+	LoginMarkers           []string               `yaml:"login_markers"`
+
+	maintenanceTime *regexp.Regexp
+	expiry          *regexp.Regexp
+}
+
+type PanelSelectors struct {
+	UniqueID     string   `yaml:"unique_id"`
+	ErrorMessage []string `yaml:"error_message"`
+	Expiry       string   `yaml:"expiry"`
+	ServerRow    string   `yaml:"server_row"`
+	ServerPlan   string   `yaml:"server_plan"`
+}
+
+// DefaultPanelSchema returns a copy of the schema embedded in the package.
+func DefaultPanelSchema() *PanelSchema {
+	return defaultPanelSchema.clone()
+}
+
+// LoadPanelSchema reads a YAML panel schema and validates it.
+func LoadPanelSchema(r io.Reader) (*PanelSchema, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPanelSchema, err)
+	}
+	// Older versions may not even decode, so their version is checked first.
+	var header struct {
+		Version int `yaml:"version"`
+	}
+	if err := yaml.Unmarshal(data, &header); err == nil && header.Version == 1 {
+		return nil, fmt.Errorf("%w: version 1 is no longer supported, key error_phrases by error code as in the embedded schema and set version: %d", ErrInvalidPanelSchema, PanelSchemaVersion)
+	}
+
+	var schema PanelSchema
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&schema); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPanelSchema, err)
+	}
+	if err := schema.Validate(); err != nil {
+		return nil, err
+	}
+	schema.compile()
+	return &schema, nil
+}
+
+// LoadPanelSchemaFile reads a YAML panel schema from path.
+func LoadPanelSchemaFile(path string) (*PanelSchema, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open panel schema: %w", err)
+	}
+	defer file.Close()
+
+	schema, err := LoadPanelSchema(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return schema, nil
+}
+
+func mustParsePanelSchema(data []byte) *PanelSchema {
+	schema, err := LoadPanelSchema(bytes.NewReader(data))
+	if err != nil {
+		panic(err)
+	}
+	return schema
+}
+
+// Validate checks that the schema version is supported and every selector compiles.
+func (s *PanelSchema) Validate() error {
+	var errs []error
+	if s.Version != PanelSchemaVersion {
+		errs = append(errs, fmt.Errorf("unsupported version %d (want %d)", s.Version, PanelSchemaVersion))
+	}
+	if s.Selectors.UniqueID == "" {
+		errs = append(errs, fmt.Errorf("selectors.unique_id must not be empty"))
+	} else if err := validateSelector(s.Selectors.UniqueID); err != nil {
+		errs = append(errs, fmt.Errorf("selectors.unique_id: %w", err))
+	}
+	if len(s.Selectors.ErrorMessage) == 0 {
+		errs = append(errs, fmt.Errorf("selectors.error_message must not be empty"))
+	}
+	for i, selector := range s.Selectors.ErrorMessage {
+		if err := validateSelector(selector); err != nil {
+			errs = append(errs, fmt.Errorf("selectors.error_message[%d]: %w", i, err))
+		}
+	}
+	for _, optional := range []struct{ name, selector string }{
+		{"expiry", s.Selectors.Expiry},
+		{"server_row", s.Selectors.ServerRow},
+		{"server_plan", s.Selectors.ServerPlan},
+	} {
+		if optional.selector == "" {
+			continue
+		}
+		if err := validateSelector(optional.selector); err != nil {
+			errs = append(errs, fmt.Errorf("selectors.%s: %w", optional.name, err))
+		}
+	}
+	if s.ExpiryPattern != "" {
+		if _, err := compileTimePattern(s.ExpiryPattern, expiryGroups...); err != nil {
+			errs = append(errs, fmt.Errorf("expiry_pattern: %w", err))
+		}
+	}
+	for i, marker := range s.FreePlanMarkers {
+		if strings.TrimSpace(marker) == "" {
+			errs = append(errs, fmt.Errorf("free_plan_markers[%d] must not be empty", i))
+		}
+	}
+	if len(s.SuccessMarkers) == 0 {
+		errs = append(errs, fmt.Errorf("success_markers must not be empty"))
+	}
+	for i, marker := range s.SuccessMarkers {
+		if strings.TrimSpace(marker) == "" {
+			errs = append(errs, fmt.Errorf("success_markers[%d] must not be empty", i))
+		}
+	}
+	for _, code := range slices.Sorted(maps.Keys(s.ErrorPhrases)) {
+		if _, ok := LookupErrorCode(code); !ok {
+			errs = append(errs, fmt.Errorf("error_phrases: unknown error code %q", code))
+		}
+		for i, phrase := range s.ErrorPhrases[code] {
+			if strings.TrimSpace(phrase) == "" {
+				errs = append(errs, fmt.Errorf("error_phrases.%s[%d] must not be empty", code, i))
+			}
+		}
+	}
+	for i, marker := range s.MaintenanceMarkers {
+		if strings.TrimSpace(marker) == "" {
+			errs = append(errs, fmt.Errorf("maintenance_markers[%d] must not be empty", i))
+		}
+	}
+	if s.MaintenanceTimePattern != "" {
+		if _, err := compileTimePattern(s.MaintenanceTimePattern, maintenanceTimeGroups...); err != nil {
+			errs = append(errs, fmt.Errorf("maintenance_time_pattern: %w", err))
+		}
+	}
+	for i, selector := range s.ChallengeMarkers {
+		if err := validateSelector(selector); err != nil {
+			errs = append(errs, fmt.Errorf("challenge_markers[%d]: %w", i, err))
+		}
+	}
+	for i, selector := range s.LoginMarkers {
+		if err := validateSelector(selector); err != nil {
+			errs = append(errs, fmt.Errorf("login_markers[%d]: %w", i, err))
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("%w: %w", ErrInvalidPanelSchema, errors.Join(errs...))
+	}
+	return nil
+}
+
+// compile compiles the time patterns of a schema that passed Validate.
+func (s *PanelSchema) compile() {
+	s.expiry, s.maintenanceTime = nil, nil
+	if s.ExpiryPattern != "" {
+		s.expiry, _ = compileTimePattern(s.ExpiryPattern, expiryGroups...)
+	}
+	if s.MaintenanceTimePattern != "" {
+		s.maintenanceTime, _ = compileTimePattern(s.MaintenanceTimePattern, maintenanceTimeGroups...)
+	}
+}
+
+// compileTimePattern compiles a date pattern and checks it has the named groups.
+func compileTimePattern(pattern string, groups ...string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, group := range groups {
+		if re.SubexpIndex(group) < 0 {
+			errs = append(errs, fmt.Errorf("missing named group %q", group))
+		}
+	}
+	if len(errs) != 0 {
+		return nil, errors.Join(errs...)
+	}
+	return re, nil
+}
+
+// matchedTime converts a match of a time pattern to a time in the panel's
+// time zone. Missing or empty groups count as zero.
+func matchedTime(re *regexp.Regexp, m []string) time.Time {
+	num := func(group string) int {
+		i := re.SubexpIndex(group)
+		if i < 0 {
+			return 0
+		}
+		n, _ := strconv.Atoi(m[i])
+		return n
+	}
+	return time.Date(num("year"), time.Month(num("month")), num("day"), num("hour"), num("minute"), 0, 0, panelLocation)
+}
+
+func validateSelector(selector string) error {
+	if strings.TrimSpace(selector) == "" {
+		return fmt.Errorf("selector must not be empty")
+	}
+	if _, err := cascadia.Compile(selector); err != nil {
+		return fmt.Errorf("invalid selector %q: %w", selector, err)
+	}
+	return nil
+}
+
+func (s *PanelSchema) clone() *PanelSchema {
+	c := *s
+	c.Selectors.ErrorMessage = append([]string(nil), s.Selectors.ErrorMessage...)
+	c.SuccessMarkers = append([]string(nil), s.SuccessMarkers...)
+	c.ErrorPhrases = make(map[ErrorCode][]string, len(s.ErrorPhrases))
+	for code, phrases := range s.ErrorPhrases {
+		c.ErrorPhrases[code] = append([]string(nil), phrases...)
+	}
+	c.MaintenanceMarkers = append([]string(nil), s.MaintenanceMarkers...)
+	c.ChallengeMarkers = append([]string(nil), s.ChallengeMarkers...)
+	c.LoginMarkers = append([]string(nil), s.LoginMarkers...)
+	c.FreePlanMarkers = append([]string(nil), s.FreePlanMarkers...)
+	return &c
+}
+
+func (s *PanelSchema) isSuccess(content string) bool {
+	for _, marker := range s.SuccessMarkers {
+		if strings.Contains(content, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *PanelSchema) findErrorPhrase(content string) (ErrorCode, string, bool) {
+	for _, code := range slices.Sorted(maps.Keys(s.ErrorPhrases)) {
+		for _, phrase := range s.ErrorPhrases[code] {
+			if strings.Contains(content, phrase) {
+				return code, phrase, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+func (s *PanelSchema) isChallengePage(doc *goquery.Document) bool {
+	for _, selector := range s.ChallengeMarkers {
+		if doc.Find(selector).Length() > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *PanelSchema) isMaintenancePage(content string) bool {
+	for _, marker := range s.MaintenanceMarkers {
+		if strings.Contains(content, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// maintenanceEnd returns the latest date and time announced on a maintenance page.
+func (s *PanelSchema) maintenanceEnd(content string) time.Time {
+	if s.maintenanceTime == nil {
+		return time.Time{}
+	}
+	var end time.Time
+	for _, m := range s.maintenanceTime.FindAllStringSubmatch(content, -1) {
+		if t := matchedTime(s.maintenanceTime, m); t.After(end) {
+			end = t
+		}
+	}
+	return end
+}
+
+func (s *PanelSchema) isLoginPage(doc *goquery.Document) bool {
+	for _, selector := range s.LoginMarkers {
+		if doc.Find(selector).Length() > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// parseExpiry finds the first date in text. It reports false when the schema
+// has no expiry pattern or text holds no date.
+func (s *PanelSchema) parseExpiry(text string) (time.Time, bool) {
+	if s.expiry == nil {
+		return time.Time{}, false
+	}
+	m := s.expiry.FindStringSubmatch(text)
+	if m == nil {
+		return time.Time{}, false
+	}
+	return matchedTime(s.expiry, m), true
+}
+
+func (s *PanelSchema) isFreePlan(plan string) bool {
+	for _, marker := range s.FreePlanMarkers {
+		if strings.Contains(plan, marker) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+package xserver
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_DefaultPanelSchema(t *testing.T) {
+	schema := DefaultPanelSchema()
+	if err := schema.Validate(); err != nil {
+		t.Fatalf("default schema is invalid: %v", err)
+	}
+	if schema.Selectors.UniqueID != "input[type=hidden][name=uniqid]" {
+		t.Errorf("unexpected unique_id selector: %s", schema.Selectors.UniqueID)
+	}
+
+	schema.SuccessMarkers[0] = "changed"
+	if DefaultPanelSchema().SuccessMarkers[0] == "changed" {
+		t.Error("expected DefaultPanelSchema to return an independent copy")
+	}
+}
+
+func Test_PanelSchema_Validate_NoSideEffects(t *testing.T) {
+	schema := DefaultPanelSchema()
+	schema.MaintenanceTimePattern = "(?P<year>\\d{4})/(?P<month>\\d+)/(?P<day>\\d+) (?P<hour>\\d+):(?P<minute>\\d+)"
+	if err := schema.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !schema.maintenanceEnd("2025/7/10 15:00").IsZero() {
+		t.Error("expected Validate to leave the compiled patterns alone")
+	}
+
+	c, err := NewClient(ClientOptions{SessionID: "session", DeviceKey: "device", PanelSchema: schema})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := time.Date(2025, 7, 10, 15, 0, 0, 0, panelLocation)
+	if got := c.(*client).panelSchema().maintenanceEnd("2025/7/10 15:00"); !got.Equal(expected) {
+		t.Errorf("expected the client to compile the custom pattern, got %v", got)
+	}
+}
+
+func Test_LoadPanelSchema(t *testing.T) {
+	tests := []struct {
+		name        string
+		yaml        string
+		wantErr     bool
+		errContains string
+	}{
+		{
+			name: "Valid schema",
+			yaml: `version: 2
+selectors:
+  unique_id: "input[name=token]"
+  error_message: ["#error"]
+success_markers: ["done"]
+error_phrases:
+  not_yet_renewable: ["too early"]
+login_markers: ["#login"]
+`,
+			wantErr: false,
+		},
+		{
+			name: "Unsupported version",
+			yaml: `version: 3
+selectors:
+  unique_id: "input[name=token]"
+  error_message: ["#error"]
+success_markers: ["done"]
+`,
+			wantErr: true,
+		},
+		{
+			name: "Version 1 schema",
+			yaml: `version: 1
+selectors:
+  unique_id: "input[name=token]"
+  error_message: ["#error"]
+success_markers: ["done"]
+error_phrases: ["too early"]
+`,
+			wantErr:     true,
+			errContains: "key error_phrases by error code",
+		},
+		{
+			name: "Invalid selector",
+			yaml: `version: 2
+selectors:
+  unique_id: "input[name="
+  error_message: ["#error"]
+success_markers: ["done"]
+`,
+			wantErr: true,
+		},
+		{
+			name: "Missing success markers",
+			yaml: `version: 2
+selectors:
+  unique_id: "input[name=token]"
+  error_message: ["#error"]
+`,
+			wantErr: true,
+		},
+		{
+			name: "Unknown error code",
+			yaml: `version: 2
+selectors:
+  unique_id: "input[name=token]"
+  error_message: ["#error"]
+success_markers: ["done"]
+error_phrases:
+  no_such_code: ["oops"]
+`,
+			wantErr: true,
+		},
+		{
+			name: "Unknown field",
+			yaml: `version: 2
+selector:
+  unique_id: "input[name=token]"
+`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := LoadPanelSchema(strings.NewReader(tt.yaml))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error but got nil")
+				}
+				if !errors.Is(err, ErrInvalidPanelSchema) {
+					t.Errorf("expected ErrInvalidPanelSchema, got %v", err)
+				}
+				if !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("expected the error to mention %q, got %v", tt.errContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if schema.Selectors.UniqueID != "input[name=token]" {
+				t.Errorf("unexpected unique_id selector: %s", schema.Selectors.UniqueID)
+			}
+		})
+	}
+}
+
+func Test_LoadPanelSchemaFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.yaml")
+	if err := os.WriteFile(path, defaultPanelSchemaYAML, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	schema, err := LoadPanelSchemaFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(schema.SuccessMarkers) == 0 {
+		t.Error("expected success markers to be loaded")
+	}
+
+	if _, err := LoadPanelSchemaFile(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func Test_findUniqueIdInResponse_CustomSchema(t *testing.T) {
+	schema := DefaultPanelSchema()
+	schema.Selectors.UniqueID = "input[name=token]"
+
+	result, err := findUniqueIdInResponse(schema, strings.NewReader(`<form><input name="token" value="tok123" /></form>`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != UniqueID("tok123") {
+		t.Errorf("expected tok123, got %s", result)
+	}
+}
+
+func Test_findUniqueIdInResponse_LoginPage(t *testing.T) {
+	loginHTML := `<html><body>
+		<form action="/xapanel/login/xvps/" method="post">
+			<input type="text" name="memberid" />
+			<input type="password" name="user_password" />
+		</form>
+	</body></html>`
+
+	_, err := findUniqueIdInResponse(DefaultPanelSchema(), strings.NewReader(loginHTML))
+	if !errors.Is(err, ErrLoginRequired) {
+		t.Errorf("expected ErrLoginRequired, got %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package xserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var ErrExpiryNotFound = errors.New("expiry not found on the page")
+
+// DefaultRenewalWindow is how long before its expiry a free VPS is assumed to
+// be renewable. XServer does not document the window, so callers let users
+// override it.
+const DefaultRenewalWindow = 24 * time.Hour
+
+// ServerStatus is the state of one VPS as shown on its extend page.
+type ServerStatus struct {
+	VPSID  VPSID     `json:"vps_id"`
+	Expiry time.Time `json:"expiry"`
+}
+
+// Server is one row of the server list on the panel top page.
+type Server struct {
+	VPSID VPSID  `json:"vps_id"`
+	Name  string `json:"name"`
+	Plan  string `json:"plan"`
+	// Free reports whether Plan is the free plan.
+	Free bool `json:"free"`
+	// Expiry is zero when the list does not show it.
+	Expiry time.Time `json:"expiry,omitzero"`
+}
+
+func (c *client) GetServerStatus(ctx context.Context, vpsID VPSID) (_ ServerStatus, err error) {
+	ctx, info, end := c.startOperation(ctx, OperationGetStatus, vpsID)
+	defer func() { end(err) }()
+
+	c.Logger.Info("Reading server status", "vpsID", vpsID)
+	doc, report, err := c.fetchExtendPage(ctx, info, freeVPSExtendURL(c.baseURL(), vpsID), nil)
+	if err != nil {
+		return ServerStatus{}, err
+	}
+	c.reportDrift(report)
+
+	schema := c.panelSchema()
+	if schema.Selectors.Expiry == "" {
+		return ServerStatus{}, ErrExpiryNotFound
+	}
+	expiry, ok := schema.parseExpiry(doc.Find(schema.Selectors.Expiry).First().Text())
+	if !ok {
+		return ServerStatus{}, ErrExpiryNotFound
+	}
+	status := ServerStatus{VPSID: vpsID, Expiry: expiry}
+	c.parsed(ctx, info, status)
+	return status, nil
+}
+
+func (c *client) ListServers(ctx context.Context) (_ []Server, err error) {
+	ctx, info, end := c.startOperation(ctx, OperationListServers, "")
+	defer func() { end(err) }()
+
+	schema := c.panelSchema()
+	if schema.Selectors.ServerRow == "" {
+		return nil, fmt.Errorf("panel schema has no selectors.server_row")
+	}
+
+	c.Logger.Info("Listing servers")
+	home := c.baseURL().JoinPath(PanelHomePath)
+	if c.Navigation != nil {
+		home = c.baseURL().JoinPath(c.Navigation.homePath())
+	}
+	_, doc, err := c.visit(ctx, info, home, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var servers []Server
+	doc.Find(schema.Selectors.ServerRow).Each(func(_ int, row *goquery.Selection) {
+		if server, ok := parseServerRow(schema, row); ok {
+			servers = append(servers, server)
+		}
+	})
+	c.parsed(ctx, info, servers)
+	return servers, nil
+}
+
+// parseServerRow reads a server from a row of the server list. Rows without a
+// link to a server are skipped.
+func parseServerRow(schema *PanelSchema, row *goquery.Selection) (Server, bool) {
+	var server Server
+	row.Find("a[href]").EachWithBreak(func(_ int, link *goquery.Selection) bool {
+		href, _ := link.Attr("href")
+		if !strings.Contains(href, "?") {
+			return true
+		}
+		id, err := ParseVPSID(href)
+		if err != nil {
+			return true
+		}
+		server.VPSID = id
+		server.Name = strings.TrimSpace(link.Text())
+		return false
+	})
+	if server.VPSID == "" {
+		return Server{}, false
+	}
+
+	if schema.Selectors.ServerPlan != "" {
+		server.Plan = strings.TrimSpace(row.Find(schema.Selectors.ServerPlan).First().Text())
+		server.Free = schema.isFreePlan(server.Plan)
+	}
+	if schema.Selectors.Expiry != "" {
+		server.Expiry, _ = schema.parseExpiry(row.Find(schema.Selectors.Expiry).First().Text())
+	}
+	return server, true
+}
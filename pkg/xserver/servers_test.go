@@ -0,0 +1,75 @@
+package xserver
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func Test_PanelSchema_parseExpiry(t *testing.T) {
+	schema := DefaultPanelSchema()
+	tests := []struct {
+		text     string
+		expected time.Time
+		ok       bool
+	}{
+		{text: "2025-07-12 18:30", expected: time.Date(2025, 7, 12, 18, 30, 0, 0, panelLocation), ok: true},
+		{text: "2025/7/12", expected: time.Date(2025, 7, 12, 0, 0, 0, 0, panelLocation), ok: true},
+		{text: "利用期限：2025年7月12日 18時30", expected: time.Date(2025, 7, 12, 18, 30, 0, 0, panelLocation), ok: true},
+		{text: "未設定", ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, ok := schema.parseExpiry(tt.text)
+			if ok != tt.ok || !got.Equal(tt.expected) {
+				t.Errorf("expected %v %v, got %v %v", tt.expected, tt.ok, got, ok)
+			}
+		})
+	}
+}
+
+func Test_parseServerRow(t *testing.T) {
+	schema := DefaultPanelSchema()
+	tests := []struct {
+		name     string
+		html     string
+		expected Server
+		ok       bool
+	}{
+		{
+			name: "Free server",
+			html: `<tr class="server"><td><a href="/xapanel/xvps/server/detail?id_vps=123">my-vps</a></td><td class="plan">無料VPS</td><td class="expiry">2025-07-12 18:30</td></tr>`,
+			expected: Server{VPSID: "123", Name: "my-vps", Plan: "無料VPS", Free: true,
+				Expiry: time.Date(2025, 7, 12, 18, 30, 0, 0, panelLocation)},
+			ok: true,
+		},
+		{
+			name:     "Paid server without expiry",
+			html:     `<tr class="server"><td><a href="/help">help</a><a href="detail?vpsid=456"> big </a></td><td class="plan">2GBプラン</td></tr>`,
+			expected: Server{VPSID: "456", Name: "big", Plan: "2GBプラン"},
+			ok:       true,
+		},
+		{
+			name: "No server link",
+			html: `<tr class="server"><td><a href="/help">help</a></td></tr>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader("<table>" + tt.html + "</table>"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ok := parseServerRow(schema, doc.Find("tr.server"))
+			if ok != tt.ok {
+				t.Fatalf("expected ok %v, got %v", tt.ok, ok)
+			}
+			if got.VPSID != tt.expected.VPSID || got.Name != tt.expected.Name || got.Plan != tt.expected.Plan ||
+				got.Free != tt.expected.Free || !got.Expiry.Equal(tt.expected.Expiry) {
+				t.Errorf("expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package xserver
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.39.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const tracerName = "x-revalidate-bot/pkg/xserver"
+
+var (
+	AttributeVPSID      = attribute.Key("xserver.vps_id")
+	AttributeAccount    = attribute.Key("xserver.account")
+	AttributeErrorCode  = attribute.Key("xserver.error_code")
+	AttributeRetryCount = attribute.Key("xserver.retry_count")
+)
+
+func (c *client) tracer() trace.Tracer {
+	if c.Tracer == nil {
+		return noop.NewTracerProvider().Tracer(tracerName)
+	}
+	return c.Tracer
+}
+
+func (c *client) startSpan(ctx context.Context, operation Operation, vpsID VPSID) (context.Context, trace.Span) {
+	return c.tracer().Start(ctx, "xserver."+string(operation),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(AttributeVPSID.String(vpsID.String())),
+	)
+}
+
+// EndSpan records err on span, including its panel error code, and ends it.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.SetAttributes(AttributeErrorCode.String(string(ErrorCodeOf(err))))
+	}
+	span.End()
+}
+
+// tracingTransport creates a child span for every HTTP request. Only the
+// method, URL and status code are recorded; headers and cookies never are,
+// and no trace context is propagated to the panel.
+type tracingTransport struct {
+	Base   http.RoundTripper
+	Tracer trace.Tracer
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := t.Tracer.Start(req.Context(), "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.URLFull(req.URL.Redacted()),
+			semconv.ServerAddress(req.URL.Hostname()),
+		),
+	)
+	defer span.End()
+
+	resp, err := t.base().RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	return resp, nil
+}
+
+func (t *tracingTransport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
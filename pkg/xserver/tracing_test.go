@@ -0,0 +1,80 @@
+package xserver
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/h2non/gock"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func Test_Tracing(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://" + XServerHost).
+		Get(FreeVPSExtendPath).
+		Reply(200).
+		BodyString(`<form><input type="hidden" name="uniqid" value="abc123" /></form>`)
+	gock.New("https://" + XServerHost).
+		Post(DoFreeVPSExtendPath).
+		Reply(200).
+		BodyString(`<main><div class="contents">契約が終了しています。</div></main>`)
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	xs, err := NewClient(ClientOptions{
+		SessionID:      "secret-session",
+		DeviceKey:      "secret-device",
+		Logger:         slog.Default(),
+		TracerProvider: provider,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	uniqueID, err := xs.GetCSRFTokenAsUniqueID(ctx, VPSID("12345"))
+	if err != nil {
+		t.Fatalf("GetCSRFTokenAsUniqueID failed: %v", err)
+	}
+	if err := xs.ExtendFreeVPSExpiration(ctx, VPSID("12345"), uniqueID); err == nil {
+		t.Fatal("expected renewal to fail")
+	}
+
+	spans := exporter.GetSpans()
+	names := make([]string, 0, len(spans))
+	for _, span := range spans {
+		names = append(names, span.Name)
+	}
+	expected := "HTTP GET,xserver.GetCSRFTokenAsUniqueID,HTTP POST,xserver.ExtendFreeVPSExpiration"
+	if strings.Join(names, ",") != expected {
+		t.Fatalf("expected spans %s, got %s", expected, strings.Join(names, ","))
+	}
+
+	if spans[0].Parent.SpanID() != spans[1].SpanContext.SpanID() {
+		t.Error("expected HTTP span to be a child of the method span")
+	}
+
+	attrs := map[string]string{}
+	for _, span := range spans {
+		for _, kv := range span.Attributes {
+			attrs[string(kv.Key)] = kv.Value.Emit()
+			if strings.Contains(kv.Value.Emit(), "secret") {
+				t.Errorf("span %s leaks a credential in %s", span.Name, kv.Key)
+			}
+		}
+	}
+	if attrs["xserver.vps_id"] != "12345" {
+		t.Errorf("expected vps id attribute, got %q", attrs["xserver.vps_id"])
+	}
+	if attrs["http.response.status_code"] != "200" {
+		t.Errorf("expected status code attribute, got %q", attrs["http.response.status_code"])
+	}
+	if attrs["xserver.error_code"] != string(ErrorCodeContractEnded) {
+		t.Errorf("expected error code attribute, got %q", attrs["xserver.error_code"])
+	}
+}
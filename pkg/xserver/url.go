@@ -6,13 +6,14 @@ import (
 
 const (
 	XServerHost         = "secure.xserver.ne.jp"
+	PanelHomePath       = "/xapanel/xvps/index"
 	FreeVPSExtendPath   = "/xapanel/xvps/server/freevps/extend/index"
 	DoFreeVPSExtendPath = "/xapanel/xvps/server/freevps/extend/do"
 )
 
 var (
-	freeVPSExtendURL   = mustJoinURL("https://", XServerHost, FreeVPSExtendPath)
-	doFreeVPSExtendURL = mustJoinURL("https://", XServerHost, DoFreeVPSExtendPath)
+	// DefaultBaseURL is the panel origin used unless ClientOptions.BaseURL is set.
+	DefaultBaseURL = mustJoinURL("https://" + XServerHost)
 )
 
 func mustJoinURL(base string, elem ...string) *url.URL {
@@ -24,9 +25,17 @@ func mustJoinURL(base string, elem ...string) *url.URL {
 }
 
 func FreeVPSExtendURL(id VPSID) *url.URL {
-	u := freeVPSExtendURL
+	return freeVPSExtendURL(DefaultBaseURL, id)
+}
+
+func freeVPSExtendURL(base *url.URL, id VPSID) *url.URL {
+	u := base.JoinPath(FreeVPSExtendPath)
 	q := u.Query()
 	q.Set("vpsid", id.String())
 	u.RawQuery = q.Encode()
 	return u
 }
+
+func doFreeVPSExtendURL(base *url.URL) *url.URL {
+	return base.JoinPath(DoFreeVPSExtendPath)
+}
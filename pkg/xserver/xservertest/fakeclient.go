@@ -0,0 +1,293 @@
+package xservertest
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+	"x-revalidate-bot/pkg/xserver"
+)
+
+// TB is the part of testing.TB the assertions of FakeClient report to, so
+// this package does not link the testing package into programs using it.
+type TB interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// Call records one invocation of a FakeClient method.
+type Call struct {
+	Operation xserver.Operation
+	VPSID     xserver.VPSID
+	UniqueID  xserver.UniqueID
+}
+
+// FakeClient is an in-memory xserver.Client. It follows the same renewal
+// rules as Panel, and lets tests queue errors and inspect calls. Configure it
+// with the chainable With* methods:
+//
+//	fake := xservertest.NewFakeClient().
+//		WithServer("12345", time.Now().Add(6*time.Hour)).
+//		WithError(xserver.OperationExtend, xserver.ErrMaintenance)
+type FakeClient struct {
+	mu            sync.Mutex
+	now           func() time.Time
+	renewalWindow time.Duration
+	extension     time.Duration
+	servers       map[xserver.VPSID]*server
+	tokens        map[xserver.UniqueID]xserver.VPSID
+	errors        map[xserver.Operation][]error
+	calls         []Call
+	sessionAlive  bool
+	nextToken     int
+}
+
+var _ xserver.Client = (*FakeClient)(nil)
+
+func NewFakeClient() *FakeClient {
+	return &FakeClient{
+		now:           time.Now,
+		renewalWindow: xserver.DefaultRenewalWindow,
+		extension:     48 * time.Hour,
+		servers:       map[xserver.VPSID]*server{},
+		tokens:        map[xserver.UniqueID]xserver.VPSID{},
+		errors:        map[xserver.Operation][]error{},
+		sessionAlive:  true,
+	}
+}
+
+// WithServer registers a free VPS expiring at expiry.
+func (f *FakeClient) WithServer(id xserver.VPSID, expiry time.Time) *FakeClient {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.servers[id] = &server{Expiry: expiry, Plan: PlanFree}
+	return f
+}
+
+// WithPlan changes the plan a registered server is listed with.
+func (f *FakeClient) WithPlan(id xserver.VPSID, plan string) *FakeClient {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if s, ok := f.servers[id]; ok {
+		s.Plan = plan
+	}
+	return f
+}
+
+// WithClock replaces time.Now.
+func (f *FakeClient) WithClock(now func() time.Time) *FakeClient {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = now
+	return f
+}
+
+// WithRenewalWindow sets how long before expiry a server can be renewed.
+func (f *FakeClient) WithRenewalWindow(window time.Duration) *FakeClient {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.renewalWindow = window
+	return f
+}
+
+// WithError queues err to be returned by the next call of operation. Queued
+// errors are returned in order before normal behaviour resumes.
+func (f *FakeClient) WithError(operation xserver.Operation, err error) *FakeClient {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.errors[operation] = append(f.errors[operation], err)
+	return f
+}
+
+// ExpireSession makes every following call fail with xserver.ErrLoginRequired.
+func (f *FakeClient) ExpireSession() *FakeClient {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sessionAlive = false
+	return f
+}
+
+// Expiry returns the current expiry of a server.
+func (f *FakeClient) Expiry(id xserver.VPSID) (time.Time, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, ok := f.servers[id]
+	if !ok {
+		return time.Time{}, false
+	}
+	return s.Expiry, true
+}
+
+// Renewals returns how many times a server was renewed.
+func (f *FakeClient) Renewals(id xserver.VPSID) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if s, ok := f.servers[id]; ok {
+		return s.Renewals
+	}
+	return 0
+}
+
+// Calls returns every call made so far, in order.
+func (f *FakeClient) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.calls)
+}
+
+// AssertCalls fails t unless the operations called so far are exactly operations.
+func (f *FakeClient) AssertCalls(t TB, operations ...xserver.Operation) {
+	t.Helper()
+	var got []xserver.Operation
+	for _, call := range f.Calls() {
+		got = append(got, call.Operation)
+	}
+	if !slices.Equal(got, operations) {
+		t.Errorf("expected calls %v, got %v", operations, got)
+	}
+}
+
+// AssertCalled fails t unless operation was called for id at least once.
+func (f *FakeClient) AssertCalled(t TB, operation xserver.Operation, id xserver.VPSID) {
+	t.Helper()
+	for _, call := range f.Calls() {
+		if call.Operation == operation && call.VPSID == id {
+			return
+		}
+	}
+	t.Errorf("expected %s to be called for %s", operation, id)
+}
+
+// AssertNotCalled fails t if operation was called for any server.
+func (f *FakeClient) AssertNotCalled(t TB, operation xserver.Operation) {
+	t.Helper()
+	for _, call := range f.Calls() {
+		if call.Operation == operation {
+			t.Errorf("expected %s not to be called, but it was for %s", operation, call.VPSID)
+			return
+		}
+	}
+}
+
+// begin records the call and returns a queued or session error, if any.
+// It must be called with f.mu held.
+func (f *FakeClient) begin(ctx context.Context, call Call) error {
+	f.calls = append(f.calls, call)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if queued := f.errors[call.Operation]; len(queued) > 0 {
+		f.errors[call.Operation] = queued[1:]
+		return queued[0]
+	}
+	if !f.sessionAlive {
+		return xserver.ErrLoginRequired
+	}
+	return nil
+}
+
+func (f *FakeClient) GetCSRFTokenAsUniqueID(ctx context.Context, vpsID xserver.VPSID) (xserver.UniqueID, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.begin(ctx, Call{Operation: xserver.OperationGetCSRFToken, VPSID: vpsID}); err != nil {
+		return "", err
+	}
+	if _, ok := f.servers[vpsID]; !ok {
+		return "", fmt.Errorf("CSRF token not found in response")
+	}
+
+	f.nextToken++
+	token := xserver.UniqueID(fmt.Sprintf("fake-uniqid-%d", f.nextToken))
+	f.tokens[token] = vpsID
+	return token, nil
+}
+
+func (f *FakeClient) GetRenewalForm(ctx context.Context, vpsID xserver.VPSID) (xserver.RenewalForm, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.begin(ctx, Call{Operation: xserver.OperationGetRenewalForm, VPSID: vpsID}); err != nil {
+		return xserver.RenewalForm{}, err
+	}
+	s, ok := f.servers[vpsID]
+	if !ok {
+		return xserver.RenewalForm{}, fmt.Errorf("CSRF token not found in response")
+	}
+
+	f.nextToken++
+	token := xserver.UniqueID(fmt.Sprintf("fake-uniqid-%d", f.nextToken))
+	f.tokens[token] = vpsID
+	return xserver.RenewalForm{VPSID: vpsID, UniqueID: token, Expiry: s.Expiry}, nil
+}
+
+func (f *FakeClient) ExtendFreeVPSExpiration(ctx context.Context, vpsID xserver.VPSID, uniqueID xserver.UniqueID) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.begin(ctx, Call{Operation: xserver.OperationExtend, VPSID: vpsID, UniqueID: uniqueID}); err != nil {
+		return err
+	}
+
+	owner, ok := f.tokens[uniqueID]
+	delete(f.tokens, uniqueID)
+	if !ok || owner != vpsID {
+		return xserver.NewPanelError(xserver.ErrorCodeInvalidRequest, "不正なリクエストです。")
+	}
+	s, ok := f.servers[vpsID]
+	if !ok {
+		return xserver.NewPanelError(xserver.ErrorCodeServerNotFound, "対象のサーバーが見つかりません。")
+	}
+	now := f.now()
+	if now.After(s.Expiry) {
+		return xserver.NewPanelError(xserver.ErrorCodeContractEnded, "契約が終了しています。")
+	}
+	if s.Expiry.Sub(now) > f.renewalWindow {
+		return xserver.NewPanelError(xserver.ErrorCodeNotYetRenewable, "利用期限の更新はまだできません。")
+	}
+
+	s.Expiry = s.Expiry.Add(f.extension)
+	s.Renewals++
+	return nil
+}
+
+func (f *FakeClient) CheckPanel(ctx context.Context, vpsID xserver.VPSID) (xserver.DriftReport, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.begin(ctx, Call{Operation: xserver.OperationCheckPanel, VPSID: vpsID}); err != nil {
+		return xserver.DriftReport{}, err
+	}
+	return xserver.DriftReport{Page: xserver.PageExtendIndex}, nil
+}
+
+func (f *FakeClient) GetServerStatus(ctx context.Context, vpsID xserver.VPSID) (xserver.ServerStatus, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.begin(ctx, Call{Operation: xserver.OperationGetStatus, VPSID: vpsID}); err != nil {
+		return xserver.ServerStatus{}, err
+	}
+	s, ok := f.servers[vpsID]
+	if !ok {
+		return xserver.ServerStatus{}, xserver.ErrExpiryNotFound
+	}
+	return xserver.ServerStatus{VPSID: vpsID, Expiry: s.Expiry}, nil
+}
+
+func (f *FakeClient) ListServers(ctx context.Context) ([]xserver.Server, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.begin(ctx, Call{Operation: xserver.OperationListServers}); err != nil {
+		return nil, err
+	}
+	var servers []xserver.Server
+	for _, id := range slices.Sorted(maps.Keys(f.servers)) {
+		s := f.servers[id]
+		servers = append(servers, xserver.Server{
+			VPSID:  id,
+			Name:   "vps-" + id.String(),
+			Plan:   s.Plan,
+			Free:   s.Plan == PlanFree,
+			Expiry: s.Expiry,
+		})
+	}
+	return servers, nil
+}
@@ -0,0 +1,108 @@
+package xservertest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"x-revalidate-bot/pkg/xserver"
+)
+
+func Test_FakeClient(t *testing.T) {
+	now := time.Date(2025, 7, 10, 12, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	t.Run("Renews inside the window", func(t *testing.T) {
+		fake := NewFakeClient().
+			WithClock(func() time.Time { return now }).
+			WithServer("12345", now.Add(6*time.Hour))
+
+		if err := renew(ctx, fake, "12345"); err != nil {
+			t.Fatalf("renewal failed: %v", err)
+		}
+		expiry, _ := fake.Expiry("12345")
+		if !expiry.Equal(now.Add(54 * time.Hour)) {
+			t.Errorf("unexpected expiry %v", expiry)
+		}
+		fake.AssertCalls(t, xserver.OperationGetCSRFToken, xserver.OperationExtend)
+		fake.AssertCalled(t, xserver.OperationExtend, "12345")
+	})
+
+	t.Run("Outside the window", func(t *testing.T) {
+		fake := NewFakeClient().
+			WithClock(func() time.Time { return now }).
+			WithServer("12345", now.Add(40*time.Hour))
+
+		err := renew(ctx, fake, "12345")
+		if xserver.ErrorCodeOf(err) != xserver.ErrorCodeNotYetRenewable {
+			t.Errorf("expected not_yet_renewable, got %v", err)
+		}
+		if fake.Renewals("12345") != 0 {
+			t.Error("expected no renewal")
+		}
+	})
+
+	t.Run("Queued errors are returned in order", func(t *testing.T) {
+		fake := NewFakeClient().
+			WithServer("12345", time.Now().Add(time.Hour)).
+			WithError(xserver.OperationGetCSRFToken, xserver.ErrMaintenance).
+			WithError(xserver.OperationGetCSRFToken, xserver.ErrChallengeRequired)
+
+		if err := renew(ctx, fake, "12345"); !errors.Is(err, xserver.ErrMaintenance) {
+			t.Errorf("expected ErrMaintenance, got %v", err)
+		}
+		if err := renew(ctx, fake, "12345"); !errors.Is(err, xserver.ErrChallengeRequired) {
+			t.Errorf("expected ErrChallengeRequired, got %v", err)
+		}
+		if err := renew(ctx, fake, "12345"); err != nil {
+			t.Errorf("expected queue to be drained, got %v", err)
+		}
+	})
+
+	t.Run("Session expiry", func(t *testing.T) {
+		fake := NewFakeClient().
+			WithServer("12345", time.Now().Add(time.Hour)).
+			ExpireSession()
+
+		if err := renew(ctx, fake, "12345"); !errors.Is(err, xserver.ErrLoginRequired) {
+			t.Errorf("expected ErrLoginRequired, got %v", err)
+		}
+		fake.AssertNotCalled(t, xserver.OperationExtend)
+	})
+
+	t.Run("Status and list", func(t *testing.T) {
+		fake := NewFakeClient().
+			WithServer("222", now.Add(time.Hour)).
+			WithServer("111", now.Add(2*time.Hour)).
+			WithPlan("222", PlanPaid)
+
+		status, err := fake.GetServerStatus(ctx, "111")
+		if err != nil || !status.Expiry.Equal(now.Add(2*time.Hour)) {
+			t.Errorf("unexpected status %+v, %v", status, err)
+		}
+		servers, err := fake.ListServers(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(servers) != 2 || servers[0].VPSID != "111" || !servers[0].Free || servers[1].Free {
+			t.Errorf("unexpected servers %+v", servers)
+		}
+		fake.AssertCalls(t, xserver.OperationGetStatus, xserver.OperationListServers)
+	})
+
+	t.Run("Reused token is rejected", func(t *testing.T) {
+		fake := NewFakeClient().WithServer("12345", time.Now().Add(time.Hour))
+
+		uniqueID, err := fake.GetCSRFTokenAsUniqueID(ctx, "12345")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := fake.ExtendFreeVPSExpiration(ctx, "12345", uniqueID); err != nil {
+			t.Fatalf("first renewal failed: %v", err)
+		}
+		err = fake.ExtendFreeVPSExpiration(ctx, "12345", uniqueID)
+		if xserver.ErrorCodeOf(err) != xserver.ErrorCodeInvalidRequest {
+			t.Errorf("expected invalid_request, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,434 @@
+// Package xservertest provides fakes of the XServer control panel for tests.
+package xservertest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"x-revalidate-bot/pkg/xserver"
+
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/transform"
+)
+
+const (
+	LoginPath        = "/xapanel/login/xvps/"
+	ServerDetailPath = "/xapanel/xvps/server/detail"
+)
+
+// Plan names shown in the server list.
+const (
+	PlanFree = "無料VPS"
+	PlanPaid = "2GBプラン"
+)
+
+// FailureMode makes the panel misbehave in a specific way.
+type FailureMode string
+
+const (
+	FailureNone           FailureMode = ""
+	FailureMaintenance    FailureMode = "maintenance"
+	FailureChallenge      FailureMode = "challenge"
+	FailureServerError    FailureMode = "server_error"
+	FailureSessionExpired FailureMode = "session_expired"
+	FailureInvalidToken   FailureMode = "invalid_token"
+)
+
+var FailureModes = []FailureMode{
+	FailureMaintenance,
+	FailureChallenge,
+	FailureServerError,
+	FailureSessionExpired,
+	FailureInvalidToken,
+}
+
+type PanelOptions struct {
+	SessionID string
+	DeviceKey string
+	// RenewalWindow is how long before expiry a server can be renewed. Defaults to 24 hours.
+	RenewalWindow time.Duration
+	// Extension is added to the expiry on renewal. Defaults to 48 hours.
+	Extension time.Duration
+	// Now returns the panel's current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// Panel emulates the free VPS extend pages of the control panel. It is an
+// http.Handler, so it can be served with httptest.NewServer.
+type Panel struct {
+	options PanelOptions
+
+	mu       sync.Mutex
+	servers  map[xserver.VPSID]*server
+	tokens   map[string]xserver.VPSID
+	failure  FailureMode
+	requests int
+	visits   []Visit
+}
+
+// Visit records how a request reached the panel.
+type Visit struct {
+	Method    string
+	Path      string
+	Referer   string
+	FetchSite string
+}
+
+type server struct {
+	Expiry   time.Time
+	Renewals int
+	Plan     string
+}
+
+func NewPanel(options PanelOptions) *Panel {
+	if options.RenewalWindow == 0 {
+		options.RenewalWindow = xserver.DefaultRenewalWindow
+	}
+	if options.Extension == 0 {
+		options.Extension = 48 * time.Hour
+	}
+	if options.Now == nil {
+		options.Now = time.Now
+	}
+	return &Panel{
+		options: options,
+		servers: map[xserver.VPSID]*server{},
+		tokens:  map[string]xserver.VPSID{},
+	}
+}
+
+// AddServer registers a free VPS expiring at expiry.
+func (p *Panel) AddServer(id xserver.VPSID, expiry time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.servers[id] = &server{Expiry: expiry, Plan: PlanFree}
+}
+
+// SetPlan changes the plan a server is listed with.
+func (p *Panel) SetPlan(id xserver.VPSID, plan string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if s, ok := p.servers[id]; ok {
+		s.Plan = plan
+	}
+}
+
+// Expiry returns the current expiry of a server.
+func (p *Panel) Expiry(id xserver.VPSID) (time.Time, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s, ok := p.servers[id]
+	if !ok {
+		return time.Time{}, false
+	}
+	return s.Expiry, true
+}
+
+// Servers returns the expiry of every registered server.
+func (p *Panel) Servers() map[xserver.VPSID]time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	servers := make(map[xserver.VPSID]time.Time, len(p.servers))
+	for id, s := range p.servers {
+		servers[id] = s.Expiry
+	}
+	return servers
+}
+
+// Renewals returns how many times a server was renewed.
+func (p *Panel) Renewals(id xserver.VPSID) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if s, ok := p.servers[id]; ok {
+		return s.Renewals
+	}
+	return 0
+}
+
+// SetFailure makes every following request fail with mode until it is reset with FailureNone.
+func (p *Panel) SetFailure(mode FailureMode) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failure = mode
+}
+
+// Visits returns every request served so far, in order.
+func (p *Panel) Visits() []Visit {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.visits)
+}
+
+// Requests returns the number of requests served so far.
+func (p *Panel) Requests() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.requests
+}
+
+func (p *Panel) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests++
+	p.visits = append(p.visits, Visit{
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		Referer:   r.Referer(),
+		FetchSite: r.Header.Get("Sec-Fetch-Site"),
+	})
+
+	if r.URL.Path == LoginPath {
+		p.render(w, http.StatusOK, loginPage)
+		return
+	}
+
+	switch p.failure {
+	case FailureMaintenance:
+		p.render(w, http.StatusServiceUnavailable, fmt.Sprintf(maintenancePage, p.options.Now().Add(time.Hour).In(jst).Format("2006年1月2日 15:04")))
+		return
+	case FailureChallenge:
+		p.render(w, http.StatusOK, challengePage)
+		return
+	case FailureServerError:
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if p.failure == FailureSessionExpired || !p.authenticated(r) {
+		http.Redirect(w, r, LoginPath, http.StatusFound)
+		return
+	}
+
+	switch {
+	case r.URL.Path == xserver.PanelHomePath && r.Method == http.MethodGet:
+		p.handleHome(w)
+	case r.URL.Path == ServerDetailPath && r.Method == http.MethodGet:
+		p.handleServerDetail(w, r)
+	case r.URL.Path == xserver.FreeVPSExtendPath && r.Method == http.MethodGet:
+		p.handleExtendIndex(w, r)
+	case r.URL.Path == xserver.DoFreeVPSExtendPath && r.Method == http.MethodPost:
+		p.handleExtendDo(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (p *Panel) authenticated(r *http.Request) bool {
+	session, err := r.Cookie("X2SESSID")
+	if err != nil || session.Value != p.options.SessionID {
+		return false
+	}
+	device, err := r.Cookie("XSERVER_DEVICEKEY")
+	if err != nil || device.Value != p.options.DeviceKey {
+		return false
+	}
+	return true
+}
+
+func (p *Panel) handleHome(w http.ResponseWriter) {
+	ids := slices.Sorted(maps.Keys(p.servers))
+	var rows strings.Builder
+	for _, id := range ids {
+		s := p.servers[id]
+		fmt.Fprintf(&rows, serverRow,
+			ServerDetailPath, url.QueryEscape(id.String()), html.EscapeString(id.String()),
+			html.EscapeString(s.Plan),
+			s.Expiry.In(jst).Format("2006-01-02 15:04"),
+		)
+	}
+	p.render(w, http.StatusOK, fmt.Sprintf(homePage, rows.String()))
+}
+
+func (p *Panel) handleServerDetail(w http.ResponseWriter, r *http.Request) {
+	id := xserver.VPSID(r.URL.Query().Get("id_vps"))
+	s, ok := p.servers[id]
+	if !ok {
+		p.render(w, http.StatusOK, fmt.Sprintf(messagePage, "対象のサーバーが見つかりません。"))
+		return
+	}
+	p.render(w, http.StatusOK, fmt.Sprintf(serverDetailPage,
+		html.EscapeString(id.String()),
+		s.Expiry.In(jst).Format("2006-01-02 15:04"),
+		xserver.FreeVPSExtendPath,
+		url.QueryEscape(id.String()),
+	))
+}
+
+func (p *Panel) handleExtendIndex(w http.ResponseWriter, r *http.Request) {
+	id := xserver.VPSID(r.URL.Query().Get("vpsid"))
+	s, ok := p.servers[id]
+	if !ok {
+		p.render(w, http.StatusOK, fmt.Sprintf(messagePage, "対象のサーバーが見つかりません。"))
+		return
+	}
+
+	token := newToken()
+	p.tokens[token] = id
+	p.render(w, http.StatusOK, fmt.Sprintf(extendPage,
+		html.EscapeString(id.String()),
+		s.Expiry.In(jst).Format("2006-01-02 15:04"),
+		token,
+		html.EscapeString(id.String()),
+	))
+}
+
+func (p *Panel) handleExtendDo(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	id := xserver.VPSID(r.PostForm.Get("id_vps"))
+	token := r.PostForm.Get("uniqid")
+
+	owner, ok := p.tokens[token]
+	delete(p.tokens, token)
+	if !ok || owner != id || p.failure == FailureInvalidToken {
+		p.render(w, http.StatusOK, fmt.Sprintf(messagePage, "不正なリクエストです。"))
+		return
+	}
+	s, ok := p.servers[id]
+	if !ok {
+		p.render(w, http.StatusOK, fmt.Sprintf(messagePage, "対象のサーバーが見つかりません。"))
+		return
+	}
+
+	now := p.options.Now()
+	if now.After(s.Expiry) {
+		p.render(w, http.StatusOK, fmt.Sprintf(messagePage, "契約が終了しています。"))
+		return
+	}
+	if s.Expiry.Sub(now) > p.options.RenewalWindow {
+		p.render(w, http.StatusOK, fmt.Sprintf(messagePage, "利用期限の更新はまだできません。"))
+		return
+	}
+
+	s.Expiry = s.Expiry.Add(p.options.Extension)
+	s.Renewals++
+	p.render(w, http.StatusOK, fmt.Sprintf(messagePage, "利用期限の更新手続きが完了しました。"))
+}
+
+// render writes body encoded in EUC-JP, like the real panel.
+func (p *Panel) render(w http.ResponseWriter, status int, body string) {
+	encoded, _, err := transform.String(japanese.EUCJP.NewEncoder(), body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=EUC-JP")
+	w.WriteHeader(status)
+	fmt.Fprint(w, encoded)
+}
+
+var jst = time.FixedZone("JST", 9*60*60)
+
+func newToken() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+const extendPage = `<!DOCTYPE html>
+<html lang="ja">
+<head><meta charset="EUC-JP"><title>無料VPSの利用期限延長 | XServer VPS</title></head>
+<body>
+<main>
+	<div class="contents">
+		<h2>無料VPSの利用期限延長</h2>
+		<table>
+			<tr><th>サーバー</th><td>%s</td></tr>
+			<tr><th>利用期限</th><td class="expiry">%s</td></tr>
+		</table>
+		<form method="post" action="/xapanel/xvps/server/freevps/extend/do">
+			<input type="hidden" name="uniqid" value="%s" />
+			<input type="hidden" name="ethna_csrf" value="" />
+			<input type="hidden" name="id_vps" value="%s" />
+			<input type="submit" value="期限を延長する" />
+		</form>
+	</div>
+</main>
+</body>
+</html>`
+
+const homePage = `<!DOCTYPE html>
+<html lang="ja">
+<head><meta charset="EUC-JP"><title>VPS管理 | XServer VPS</title></head>
+<body>
+<main>
+	<div class="contents">
+		<h2>サーバー一覧</h2>
+		<table class="servers">
+			<tr><th>サーバー</th><th>プラン</th><th>利用期限</th></tr>
+%s		</table>
+	</div>
+</main>
+</body>
+</html>`
+
+const serverRow = "\t\t\t<tr class=\"server\"><td class=\"name\"><a href=\"%s?id_vps=%s\">vps-%s</a></td><td class=\"plan\">%s</td><td class=\"expiry\">%s</td></tr>\n"
+
+const serverDetailPage = `<!DOCTYPE html>
+<html lang="ja">
+<head><meta charset="EUC-JP"><title>サーバー情報 | XServer VPS</title></head>
+<body>
+<main>
+	<div class="contents">
+		<h2>%s</h2>
+		<table>
+			<tr><th>利用期限</th><td class="expiry">%s</td></tr>
+		</table>
+		<a href="%s?vpsid=%s">期限を延長する</a>
+	</div>
+</main>
+</body>
+</html>`
+
+const messagePage = `<!DOCTYPE html>
+<html lang="ja">
+<head><meta charset="EUC-JP"><title>XServer VPS</title></head>
+<body>
+<main>
+	<div class="contents">%s</div>
+</main>
+</body>
+</html>`
+
+const loginPage = `<!DOCTYPE html>
+<html lang="ja">
+<head><meta charset="EUC-JP"><title>ログイン | XServer VPS</title></head>
+<body>
+<form action="/xapanel/login/xvps/" method="post">
+	<input type="text" name="memberid" />
+	<input type="password" name="user_password" />
+	<input type="submit" value="ログイン" />
+</form>
+</body>
+</html>`
+
+const maintenancePage = `<!DOCTYPE html>
+<html lang="ja">
+<head><meta charset="EUC-JP"><title>メンテナンス中</title></head>
+<body>
+<main>
+	<h1>ただいまメンテナンス中です</h1>
+	<p>%s まで</p>
+</main>
+</body>
+</html>`
+
+const challengePage = `<!DOCTYPE html>
+<html lang="ja">
+<head><meta charset="EUC-JP"><title>確認</title></head>
+<body>
+<form id="challenge-form" method="post">
+	<div class="g-recaptcha" data-sitekey="fake"></div>
+</form>
+</body>
+</html>`
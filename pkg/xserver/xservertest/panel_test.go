@@ -0,0 +1,185 @@
+package xservertest
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+	"x-revalidate-bot/pkg/xserver"
+)
+
+func newTestClient(t *testing.T, srv *httptest.Server, sessionID string) xserver.Client {
+	t.Helper()
+	base, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xs, err := xserver.NewClient(xserver.ClientOptions{
+		SessionID: sessionID,
+		DeviceKey: "device",
+		Logger:    slog.Default(),
+		BaseURL:   base,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return xs
+}
+
+func renew(ctx context.Context, xs xserver.Client, id xserver.VPSID) error {
+	uniqueID, err := xs.GetCSRFTokenAsUniqueID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return xs.ExtendFreeVPSExpiration(ctx, id, uniqueID)
+}
+
+func Test_Panel(t *testing.T) {
+	now := time.Date(2025, 7, 10, 12, 0, 0, 0, time.UTC)
+	panel := NewPanel(PanelOptions{
+		SessionID: "session",
+		DeviceKey: "device",
+		Now:       func() time.Time { return now },
+	})
+	panel.AddServer("due", now.Add(6*time.Hour))
+	panel.AddServer("early", now.Add(40*time.Hour))
+	panel.AddServer("ended", now.Add(-time.Hour))
+
+	srv := httptest.NewServer(panel)
+	defer srv.Close()
+	ctx := context.Background()
+
+	t.Run("Renews a server inside the window", func(t *testing.T) {
+		if err := renew(ctx, newTestClient(t, srv, "session"), "due"); err != nil {
+			t.Fatalf("renewal failed: %v", err)
+		}
+		expiry, _ := panel.Expiry("due")
+		if !expiry.Equal(now.Add(54 * time.Hour)) {
+			t.Errorf("unexpected expiry %v", expiry)
+		}
+		if panel.Renewals("due") != 1 {
+			t.Errorf("expected one renewal, got %d", panel.Renewals("due"))
+		}
+	})
+
+	tests := []struct {
+		name     string
+		id       xserver.VPSID
+		session  string
+		failure  FailureMode
+		expected error
+		code     xserver.ErrorCode
+	}{
+		{name: "Outside the window", id: "early", session: "session", code: xserver.ErrorCodeNotYetRenewable},
+		{name: "Contract ended", id: "ended", session: "session", code: xserver.ErrorCodeContractEnded},
+		{name: "Unknown server", id: "missing", session: "session", expected: errors.New("CSRF token not found in response")},
+		{name: "Wrong session", id: "due", session: "other", expected: xserver.ErrLoginRequired},
+		{name: "Session expired", id: "due", session: "session", failure: FailureSessionExpired, expected: xserver.ErrLoginRequired},
+		{name: "Maintenance", id: "due", session: "session", failure: FailureMaintenance, expected: xserver.ErrMaintenance},
+		{name: "Challenge", id: "due", session: "session", failure: FailureChallenge, expected: xserver.ErrChallengeRequired},
+		{name: "Invalid token", id: "due", session: "session", failure: FailureInvalidToken, code: xserver.ErrorCodeInvalidRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			panel.SetFailure(tt.failure)
+			defer panel.SetFailure(FailureNone)
+
+			err := renew(ctx, newTestClient(t, srv, tt.session), tt.id)
+			if err == nil {
+				t.Fatal("expected error but got nil")
+			}
+			if tt.code != "" && xserver.ErrorCodeOf(err) != tt.code {
+				t.Errorf("expected code %s, got %s (%v)", tt.code, xserver.ErrorCodeOf(err), err)
+			}
+			if tt.expected != nil && !errors.Is(err, tt.expected) && err.Error() != tt.expected.Error() {
+				t.Errorf("expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func Test_Panel_Navigation(t *testing.T) {
+	panel := NewPanel(PanelOptions{SessionID: "session", DeviceKey: "device"})
+	panel.AddServer("12345", time.Now().Add(6*time.Hour))
+	panel.AddServer("67890", time.Now().Add(6*time.Hour))
+	srv := httptest.NewServer(panel)
+	defer srv.Close()
+
+	base, _ := url.Parse(srv.URL)
+	xs, err := xserver.NewClient(xserver.ClientOptions{
+		SessionID:  "session",
+		DeviceKey:  "device",
+		Headers:    map[string]string{"Sec-Fetch-Site": "none"},
+		BaseURL:    base,
+		Navigation: &xserver.Navigation{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := renew(context.Background(), xs, "12345"); err != nil {
+		t.Fatalf("renewal failed: %v", err)
+	}
+
+	extendPage := srv.URL + xserver.FreeVPSExtendPath + "?vpsid=12345"
+	expected := []Visit{
+		{Method: "GET", Path: xserver.PanelHomePath, FetchSite: "none"},
+		{Method: "GET", Path: ServerDetailPath, Referer: srv.URL + xserver.PanelHomePath, FetchSite: "same-origin"},
+		{Method: "GET", Path: xserver.FreeVPSExtendPath, Referer: srv.URL + ServerDetailPath + "?id_vps=12345", FetchSite: "same-origin"},
+		{Method: "POST", Path: xserver.DoFreeVPSExtendPath, Referer: extendPage, FetchSite: "same-origin"},
+	}
+	if visits := panel.Visits(); !slices.Equal(visits, expected) {
+		t.Errorf("unexpected visits:\n got %+v\nwant %+v", visits, expected)
+	}
+	if panel.Renewals("12345") != 1 || panel.Renewals("67890") != 0 {
+		t.Errorf("expected only 12345 to be renewed")
+	}
+}
+
+func Test_Panel_StatusAndList(t *testing.T) {
+	expiry := time.Date(2025, 7, 12, 18, 30, 0, 0, jst)
+	panel := NewPanel(PanelOptions{SessionID: "session", DeviceKey: "device"})
+	panel.AddServer("12345", expiry)
+	panel.AddServer("67890", expiry.Add(24*time.Hour))
+	panel.SetPlan("67890", PlanPaid)
+	srv := httptest.NewServer(panel)
+	defer srv.Close()
+
+	xs := newTestClient(t, srv, "session")
+	ctx := context.Background()
+
+	status, err := xs.GetServerStatus(ctx, "12345")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !status.Expiry.Equal(expiry) {
+		t.Errorf("expected expiry %v, got %v", expiry, status.Expiry)
+	}
+
+	servers, err := xs.ListServers(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []xserver.Server{
+		{VPSID: "12345", Name: "vps-12345", Plan: PlanFree, Free: true, Expiry: expiry},
+		{VPSID: "67890", Name: "vps-67890", Plan: PlanPaid, Free: false, Expiry: expiry.Add(24 * time.Hour)},
+	}
+	if len(servers) != len(expected) {
+		t.Fatalf("expected %d servers, got %+v", len(expected), servers)
+	}
+	for i := range expected {
+		if servers[i].VPSID != expected[i].VPSID || servers[i].Name != expected[i].Name ||
+			servers[i].Plan != expected[i].Plan || servers[i].Free != expected[i].Free ||
+			!servers[i].Expiry.Equal(expected[i].Expiry) {
+			t.Errorf("server %d: expected %+v, got %+v", i, expected[i], servers[i])
+		}
+	}
+
+	if _, err := newTestClient(t, srv, "other").ListServers(ctx); !errors.Is(err, xserver.ErrLoginRequired) {
+		t.Errorf("expected ErrLoginRequired, got %v", err)
+	}
+}